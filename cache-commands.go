@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mkbworks/ask-athena/lib/config"
+	"github.com/mkbworks/ask-athena/lib/dns"
+)
+
+//JSON representation of a cached resource record used by 'cache export'.
+type cacheEntry struct {
+	Name string `json:"name"`
+	TTL uint32 `json:"ttl"`
+	RemainingTTL uint32 `json:"remainingTtl"`
+	Class string `json:"class"`
+	Type string `json:"type"`
+	Data string `json:"data"`
+	LastModified string `json:"lastModified"`
+	Expired bool `json:"expired"`
+}
+
+//Prints the usage of the 'cache' subcommand.
+func cacheUsage() {
+	fmt.Println("Usage: ./ask-athena cache <command> [options]")
+	fmt.Println("Commands available:")
+	fmt.Println("  list [--name name] [--type type] [--expired]   List the cached records")
+	fmt.Println("  flush [--type type] <name>                     Remove the cached records of a domain name")
+	fmt.Println("  flush --all                                    Remove every cached record")
+	fmt.Println("  stats                                          Show statistics about the cache")
+	fmt.Println("  export [--format json|bind]                    Print every cached record in the given format")
+	fmt.Println("Every command also accepts --cache-store (bind, binary or memory) to pick the cache to operate on.")
+}
+
+//Runs the 'cache' subcommand with the given arguments and returns the exit status.
+func runCacheCommand(args []string) int {
+	if len(args) == 0 || args[0] == "-help" || args[0] == "--help" {
+		cacheUsage()
+		return 0
+	}
+
+	command := args[0]
+	flags := flag.NewFlagSet("cache "+command, flag.ContinueOnError)
+	storeName := flags.String("cache-store", "bind", "storage backend for the resolver cache (bind, binary or memory)")
+	nameFilter := flags.String("name", "", "only include records of this domain name")
+	typeFilter := flags.String("type", "", "only include records of this record type")
+	showExpired := flags.Bool("expired", false, "include records whose TTL has elapsed")
+	flushAll := flags.Bool("all", false, "remove every cached record")
+	format := flags.String("format", "json", "output format of the exported records (json or bind)")
+	names, err := parseInterspersed(flags, args[1:])
+	if err != nil {
+		return 1
+	}
+	if len(names) > 0 && command != "flush" {
+		fmt.Printf("Unexpected argument %q for cache %s.\n", names[0], command)
+		return 1
+	}
+
+	err = config.SetupConfig()
+	if err != nil {
+		fmt.Println("Error occurred while setting up DNS resolver configuration:", err.Error())
+		return 1
+	}
+
+	store, err := newCacheStore(*storeName)
+	if err != nil {
+		fmt.Printf("Error occurred while loading the resolver cache: %s\n", err.Error())
+		return 1
+	}
+
+	var recType dns.RecordType
+	if *typeFilter != "" {
+		typeKey := strings.ToUpper(*typeFilter)
+		if _, ok := dns.AllowedRRTypes[typeKey]; !ok {
+			fmt.Printf("Given record type is not supported by the DNS resolver.\n")
+			return 1
+		}
+		recType = dns.AllowedRRTypes.GetRecordType(typeKey)
+	}
+
+	switch command {
+	case "list":
+		return cacheList(store, *nameFilter, recType, *showExpired)
+	case "flush":
+		return cacheFlush(store, names, recType, *flushAll)
+	case "stats":
+		return cacheStats(store)
+	case "export":
+		return cacheExport(store, *format)
+	default:
+		fmt.Printf("Unknown cache command %q.\n\n", command)
+		cacheUsage()
+		return 1
+	}
+}
+
+//Parses the options of the flag set found anywhere among the arguments and returns the remaining arguments. The flag package stops at
+//the first argument that is not an option, so parsing resumes after each one, and options given after a domain name are still applied
+//instead of being taken as domain names. Every argument following "--" is returned as is.
+func parseInterspersed(flags *flag.FlagSet, args []string) ([]string, error) {
+	positional := make([]string, 0)
+	for {
+		err := flags.Parse(args)
+		if err != nil {
+			return nil, err
+		}
+		rest := flags.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		} else if len(rest) < len(args) && args[len(args) - len(rest) - 1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+//Returns the records in the cache store matching the given domain name and record type. Empty filters match every record.
+func filterCache(store dns.CacheStore, name string, recType dns.RecordType, includeExpired bool) []dns.LocalResource {
+	records := make([]dns.LocalResource, 0)
+	if name != "" {
+		name = dns.Canonicalize(name)
+	}
+	store.Iterate(func(record dns.LocalResource) bool {
+		resource := record.GetResource()
+		if name != "" && !strings.EqualFold(name, resource.Name.Value) {
+			return true
+		}
+		if recType != 0 && resource.Type != recType {
+			return true
+		}
+		if !includeExpired && record.HasExpired() {
+			return true
+		}
+		records = append(records, record)
+		return true
+	})
+	return records
+}
+
+//Prints the cached records matching the filters along with their remaining TTL.
+func cacheList(store dns.CacheStore, name string, recType dns.RecordType, includeExpired bool) int {
+	records := filterCache(store, name, recType, includeExpired)
+	for _, record := range records {
+		resource := record.GetResource()
+		fmt.Printf("%s \t %d \t %s \t %s \t %s \t (remaining %ds, cached at %s)\n", resource.Name.String(), int(resource.TTL), resource.Class.String(), resource.Type.String(), resource.GetData(), record.RemainingTTL(), record.LastModified.Format(time.RFC3339))
+	}
+	fmt.Printf("\n%d record(s) listed.\n", len(records))
+	return 0
+}
+
+//Removes the cached records of the given domain names, or of every domain name if 'all' is set, and persists the cache.
+func cacheFlush(store dns.CacheStore, names []string, recType dns.RecordType, all bool) int {
+	if all {
+		names = make([]string, 0)
+		store.Iterate(func(record dns.LocalResource) bool {
+			names = append(names, record.GetResource().Name.Value)
+			return true
+		})
+		recType = 0
+	} else if len(names) == 0 {
+		fmt.Println("Not enough arguments, must pass in a domain name or --all")
+		return 1
+	}
+
+	removed := 0
+	for _, name := range names {
+		before := len(filterCache(store, name, recType, true))
+		store.Delete(name, recType)
+		removed += before
+	}
+
+	err := store.Flush()
+	if err != nil {
+		fmt.Printf("Error occurred while saving the resolver cache: %s\n", err.Error())
+		return 1
+	}
+	fmt.Printf("%d record(s) removed from the cache.\n", removed)
+	return 0
+}
+
+//Prints the number of live and expired records in the cache, broken down by record type.
+func cacheStats(store dns.CacheStore) int {
+	total, expired := 0, 0
+	byType := make(map[string]int)
+	names := make(map[string]bool)
+	var oldest, newest time.Time
+	store.Iterate(func(record dns.LocalResource) bool {
+		resource := record.GetResource()
+		total++
+		if record.HasExpired() {
+			expired++
+		}
+		byType[resource.Type.String()]++
+		names[resource.Name.Value] = true
+		if oldest.IsZero() || record.LastModified.Before(oldest) {
+			oldest = record.LastModified
+		}
+		if newest.IsZero() || record.LastModified.After(newest) {
+			newest = record.LastModified
+		}
+		return true
+	})
+
+	fmt.Printf("Total records   : %d\n", total)
+	fmt.Printf("Live records    : %d\n", total - expired)
+	fmt.Printf("Expired records : %d\n", expired)
+	fmt.Printf("Domain names    : %d\n", len(names))
+	if total > 0 {
+		fmt.Printf("Oldest entry    : %s\n", oldest.Format(time.RFC3339))
+		fmt.Printf("Newest entry    : %s\n", newest.Format(time.RFC3339))
+	}
+
+	types := make([]string, 0, len(byType))
+	for recType := range byType {
+		types = append(types, recType)
+	}
+	sort.Strings(types)
+	if len(types) > 0 {
+		fmt.Println("Records by type :")
+		for _, recType := range types {
+			fmt.Printf("  %-6s %d\n", recType, byType[recType])
+		}
+	}
+	return 0
+}
+
+//Prints every cached record, including expired ones, in the given format.
+func cacheExport(store dns.CacheStore, format string) int {
+	records := filterCache(store, "", 0, true)
+	switch format {
+	case "json":
+		entries := make([]cacheEntry, 0, len(records))
+		for _, record := range records {
+			resource := record.GetResource()
+			entry := cacheEntry{}
+			entry.Name = resource.Name.Value
+			entry.TTL = resource.TTL
+			entry.RemainingTTL = record.RemainingTTL()
+			entry.Class = resource.Class.String()
+			entry.Type = resource.Type.String()
+			entry.Data = resource.GetData()
+			entry.LastModified = record.LastModified.Format(time.RFC3339)
+			entry.Expired = record.HasExpired()
+			entries = append(entries, entry)
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(entries)
+		if err != nil {
+			fmt.Printf("Error occurred while exporting the resolver cache: %s\n", err.Error())
+			return 1
+		}
+	case "bind":
+		for _, record := range records {
+			fmt.Print(record.String())
+		}
+	default:
+		fmt.Printf("Unknown export format %q.\n", format)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkbworks/ask-athena/lib/dns"
+)
+
+//Runs the command and returns what it printed to the standard output, along with its exit status.
+func captureOutput(t *testing.T, run func() int) (string, int) {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	status := run()
+	os.Stdout = stdout
+	writer.Close()
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(output), status
+}
+
+//Returns a BIND file cache holding two live records of www.example.com. and an expired record of old.example.com.
+func newTestCache(t *testing.T) *dns.BindFile {
+	t.Helper()
+	now := time.Now().UTC().Format(time.RFC3339)
+	longAgo := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	records := "www.example.com. 300 IN A 192.0.2.1 " + now + "\n" +
+		"www.example.com. 300 IN AAAA 2001:db8::1 " + now + "\n" +
+		"old.example.com. 60 IN A 192.0.2.2 " + longAgo + "\n"
+	filePath := filepath.Join(t.TempDir(), "resolver-cache.conf")
+	err := os.WriteFile(filePath, []byte(records), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := &dns.BindFile{}
+	err = store.Initialize(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestParseInterspersed(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+		names []string
+		recType string
+		err bool
+	}{
+		{name: "options before the names", args: []string{"--type", "A", "example.com"}, names: []string{"example.com"}, recType: "A"},
+		{name: "options after a name", args: []string{"example.com", "--type", "A"}, names: []string{"example.com"}, recType: "A"},
+		{name: "options between names", args: []string{"example.com", "-type=AAAA", "www.example.com"}, names: []string{"example.com", "www.example.com"}, recType: "AAAA"},
+		{name: "names after the end of the options", args: []string{"example.com", "--", "--type"}, names: []string{"example.com", "--type"}},
+		{name: "unknown option after a name", args: []string{"example.com", "--kind", "A"}, err: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("cache flush", flag.ContinueOnError)
+			flags.SetOutput(io.Discard)
+			recType := flags.String("type", "", "")
+			names, err := parseInterspersed(flags, testCase.args)
+			if (err != nil) != testCase.err {
+				t.Fatalf("parsing returned %v", err)
+			}
+			if testCase.err {
+				return
+			}
+			if strings.Join(names, ",") != strings.Join(testCase.names, ",") || *recType != testCase.recType {
+				t.Errorf("names %v with type %q, expected %v with type %q", names, *recType, testCase.names, testCase.recType)
+			}
+		})
+	}
+}
+
+func TestCacheList(t *testing.T) {
+	testCases := []struct {
+		name string
+		filter string
+		recType dns.RecordType
+		expired bool
+		listed string
+	}{
+		{name: "live records", listed: "2 record(s) listed."},
+		{name: "records including expired ones", expired: true, listed: "3 record(s) listed."},
+		{name: "records of a domain name", filter: "WWW.example.com", listed: "2 record(s) listed."},
+		{name: "records of a type", recType: dns.TYPE_AAAA, listed: "1 record(s) listed."},
+		{name: "expired records of a domain name", filter: "old.example.com.", listed: "0 record(s) listed."},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			store := newTestCache(t)
+			output, status := captureOutput(t, func() int { return cacheList(store, testCase.filter, testCase.recType, testCase.expired) })
+			if status != 0 || !strings.Contains(output, testCase.listed) {
+				t.Errorf("exit status %d with output %q, expected %q", status, output, testCase.listed)
+			}
+		})
+	}
+}
+
+func TestCacheFlush(t *testing.T) {
+	testCases := []struct {
+		name string
+		names []string
+		recType dns.RecordType
+		all bool
+		status int
+		removed string
+		//Records left in the cache file afterwards, as "name TYPE". Saving the cache drops its expired records.
+		left []string
+	}{
+		{name: "records of a domain name", names: []string{"www.example.com"}, removed: "2 record(s)"},
+		{name: "records of a type", names: []string{"www.example.com."}, recType: dns.TYPE_A, removed: "1 record(s)", left: []string{"www.example.com. AAAA"}},
+		{name: "every record", all: true, removed: "3 record(s)"},
+		{name: "without a domain name", status: 1, left: []string{"old.example.com. A", "www.example.com. A", "www.example.com. AAAA"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			store := newTestCache(t)
+			output, status := captureOutput(t, func() int { return cacheFlush(store, testCase.names, testCase.recType, testCase.all) })
+			if status != testCase.status || !strings.Contains(output, testCase.removed) {
+				t.Errorf("exit status %d with output %q, expected %d with %q removed", status, output, testCase.status, testCase.removed)
+			}
+
+			reloaded := &dns.BindFile{}
+			err := reloaded.Initialize(store.LocalFilePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			left := make([]string, 0)
+			for _, record := range reloaded.Records() {
+				left = append(left, record.GetResource().Name.Value + " " + record.GetResource().Type.String())
+			}
+			slices.Sort(left)
+			if strings.Join(left, ",") != strings.Join(testCase.left, ",") {
+				t.Errorf("cache file holds %v, expected %v", left, testCase.left)
+			}
+		})
+	}
+}
+
+func TestCacheStats(t *testing.T) {
+	store := newTestCache(t)
+	output, status := captureOutput(t, func() int { return cacheStats(store) })
+	for _, line := range []string{"Total records   : 3", "Live records    : 2", "Expired records : 1", "Domain names    : 2", "  A      2", "  AAAA   1"} {
+		if !strings.Contains(output, line) {
+			t.Errorf("output %q does not contain %q", output, line)
+		}
+	}
+	if status != 0 {
+		t.Errorf("exit status %d, expected 0", status)
+	}
+}
+
+func TestCacheExport(t *testing.T) {
+	store := newTestCache(t)
+	output, status := captureOutput(t, func() int { return cacheExport(store, "json") })
+	entries := make([]cacheEntry, 0)
+	err := json.Unmarshal([]byte(output), &entries)
+	if status != 0 || err != nil {
+		t.Fatalf("exit status %d with output %q, expected JSON records: %v", status, output, err)
+	}
+	expired := 0
+	for _, entry := range entries {
+		if entry.Expired {
+			expired++
+			if entry.Name != "old.example.com." || entry.RemainingTTL != 0 {
+				t.Errorf("entry %+v exported as expired", entry)
+			}
+		}
+	}
+	if len(entries) != 3 || expired != 1 {
+		t.Errorf("exported %+v, expected 3 records of which 1 expired", entries)
+	}
+
+	output, status = captureOutput(t, func() int { return cacheExport(store, "bind") })
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if status != 0 || len(lines) != 3 || !strings.Contains(output, "www.example.com. 300 IN A 192.0.2.1 ") {
+		t.Errorf("exit status %d with BIND records %q, expected the 3 cached records", status, lines)
+	}
+
+	_, status = captureOutput(t, func() int { return cacheExport(store, "yaml") })
+	if status != 1 {
+		t.Errorf("exit status %d for an unknown format, expected 1", status)
+	}
+}
@@ -4,12 +4,18 @@ import (
 	"errors"
 	"path/filepath"
 	"runtime"
+
+	"github.com/mkbworks/ask-athena/lib/dns"
 )
 
 // Absolute path of the BIND file that contains the root DNS server details.
 var RootServerFilePath string
 // Absolute path of the BIND file that contains all the cached RRs.
 var CacheFilePath string
+// Absolute path of the binary snapshot file that contains all the cached RRs.
+var CacheSnapshotPath string
+// Absolute path of the file that contains the DNSSEC trust anchors of the root zone.
+var TrustAnchorFilePath string
 
 //Sets up the inital configuration required to create a resolver instance.
 func SetupConfig() error {
@@ -25,6 +31,15 @@ func SetupConfig() error {
 
 	CurrentDirectory := filepath.Dir(completeFilePath)
 	CacheFilePath = filepath.Join(CurrentDirectory, "resolver-cache.conf")
+	CacheSnapshotPath = filepath.Join(CurrentDirectory, "resolver-cache.snap")
 	RootServerFilePath = filepath.Join(CurrentDirectory, "root-servers.conf")
+	TrustAnchorFilePath = filepath.Join(CurrentDirectory, "root-anchors.conf")
 	return nil
+}
+
+//Loads the DNSSEC trust anchors from the trust anchor file. SetupConfig must have been called beforehand.
+func LoadTrustAnchors() (*dns.TrustAnchorFile, error) {
+	anchors := dns.TrustAnchorFile{}
+	err := anchors.Initialize(TrustAnchorFilePath)
+	return &anchors, err
 }
\ No newline at end of file
@@ -0,0 +1,149 @@
+package dns
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+//Cache store persisted to a local file.
+type fileStore interface {
+	CacheStore
+	Initialize(filePath string) error
+	Records() []LocalResource
+}
+
+func TestCacheFilesSync(t *testing.T) {
+	testCases := []struct {
+		name string
+		newStore func() fileStore
+		//Contents of a cache file without any records.
+		empty []byte
+	}{
+		{name: "BIND file", newStore: func() fileStore { return &BindFile{} }},
+		{name: "snapshot file", newStore: func() fileStore { return &SnapshotFile{} }, empty: append([]byte(SNAPSHOT_MAGIC), SNAPSHOT_VERSION, 0, 0, 0, 0)},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			directory := filepath.Join(t.TempDir(), "cache")
+			filePath := filepath.Join(directory, "records")
+			err := os.Mkdir(directory, 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = os.WriteFile(filePath, testCase.empty, 0640)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			store := testCase.newStore()
+			err = store.Initialize(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			store.Put([]Resource{
+				*NewResourceRecord("www.example.com.", 300, "IN", "A", "192.0.2.1"),
+				*NewResourceRecord("example.com.", 300, "IN", "SOA", "ns1.example.com. hostmaster.example.com. 1 3600 900 604800 300"),
+			})
+			err = store.Flush()
+			if err != nil {
+				t.Fatalf("sync failed: %s", err.Error())
+			}
+
+			entries, err := os.ReadDir(directory)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 1 {
+				t.Errorf("directory holds %d files after a sync, expected the temporary file to be renamed over the cache file", len(entries))
+			}
+			info, err := os.Stat(filePath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != 0640 {
+				t.Errorf("cache file has permissions %v after a sync, expected them to be kept", info.Mode().Perm())
+			}
+
+			reloaded := testCase.newStore()
+			err = reloaded.Initialize(filePath)
+			if err != nil {
+				t.Fatalf("loading the synced file failed: %s", err.Error())
+			}
+			records := strings.Join(storedRecords(reloaded), ",")
+			if records != "example.com. SOA ns1.example.com. hostmaster.example.com. 1 3600 900 604800 300,www.example.com. A 192.0.2.1" {
+				t.Errorf("synced file holds %s", records)
+			}
+
+			err = os.RemoveAll(directory)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Flush(); err == nil {
+				t.Error("sync into a removed directory succeeded, expected it to report the error")
+			}
+		})
+	}
+}
+
+func TestSnapshotFileRejectsCorruptFiles(t *testing.T) {
+	//Returns the snapshot header followed by the given bytes.
+	header := func(rest ...byte) []byte {
+		return append([]byte(SNAPSHOT_MAGIC), rest...)
+	}
+	testCases := []struct {
+		name string
+		contents []byte
+		reason string
+	}{
+		{name: "file shorter than the header", contents: header()[:2], reason: "reading the header"},
+		{name: "file of another format", contents: []byte("$ORIGIN example.com."), reason: "is not a cache snapshot"},
+		{name: "file ending before the version", contents: header(), reason: "reading the version"},
+		{name: "unsupported version", contents: header(SNAPSHOT_VERSION + 1, 0, 0, 0, 0), reason: "has version 2"},
+		{name: "file ending before the record count", contents: header(SNAPSHOT_VERSION, 0), reason: "reading the record count"},
+		{name: "fewer records than counted", contents: header(SNAPSHOT_VERSION, 0, 0, 0, 1), reason: "reading the name of record 1 of 1"},
+		{name: "record count larger than the file", contents: header(SNAPSHOT_VERSION, 0xFF, 0xFF, 0xFF, 0xFF), reason: "reading the name of record 1 of 4294967295"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), "snapshot")
+			err := os.WriteFile(filePath, testCase.contents, 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			snapshot := SnapshotFile{}
+			err = snapshot.Initialize(filePath)
+			if !errors.Is(err, ErrInvalidSnapshot) {
+				t.Fatalf("loading returned %v, expected %v", err, ErrInvalidSnapshot)
+			}
+			if !strings.Contains(err.Error(), testCase.reason) {
+				t.Errorf("loading returned %q, expected it to say %q", err.Error(), testCase.reason)
+			}
+		})
+	}
+}
+
+func TestBindFileReturnsRemainingTTL(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "resolver-cache.conf")
+	cachedAt := time.Now().Add(-100 * time.Second).UTC().Format(time.RFC3339)
+	err := os.WriteFile(filePath, []byte("www.example.com. 300 IN A 192.0.2.1 " + cachedAt + "\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bindFile := BindFile{}
+	err = bindFile.Initialize(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	RRs, ok := bindFile.Get("www.example.com.", TYPE_A)
+	if !ok || RRs[0].TTL < 199 || RRs[0].TTL > 200 {
+		t.Errorf("found %v, expected the record with 200 seconds left", RRs)
+	}
+}
@@ -0,0 +1,72 @@
+package dns
+
+//Feature(s) to be implemented by a storage backend for the resolver cache.
+type CacheStore interface {
+	//Returns the unexpired RRset matching the given domain name and record type.
+	Get(name string, recType RecordType) ([]Resource, bool)
+	//Replaces the RRsets in the store with the given resource records.
+	Put(resources []Resource)
+	//Removes the RRset matching the given domain name and record type. Record type 0 removes all the RRsets of the domain name.
+	Delete(name string, recType RecordType)
+	//Invokes the callback for every record in the store until the callback returns false.
+	Iterate(callback func(record LocalResource) bool)
+	//Persists the in-memory changes to the backing storage.
+	Flush() error
+}
+
+// Resolves the given domain name and record type using data available in the cache store.
+func resolveFromCache(store CacheStore, name string, recType RecordType) ([]Resource, bool) {
+	return cacheResolve(store, name, recType, 0)
+}
+
+// Determines the records of the given type for the domain name from the cache store. CNAME records cached for the domain name,
+// or synthesized from a DNAME record cached for one of its ancestors, are followed unless CNAME records are themselves being resolved.
+// 'depth' is the number of CNAME records followed so far, so that a cycle of cached CNAME records is not followed forever.
+func cacheResolve(store CacheStore, name string, recType RecordType, depth int) ([]Resource, bool) {
+	resources := make([]Resource, 0)
+	if recType != TYPE_CNAME && depth < MAX_CNAME_CHAIN {
+		CNAME_RRs, ok := store.Get(name, TYPE_CNAME)
+		if !ok {
+			CNAME_RRs, ok = cacheSynthesizeCNAME(store, name)
+		}
+		if ok {
+			RRs, ok := cacheResolve(store, CNAME_RRs[len(CNAME_RRs) - 1].GetData(), recType, depth + 1)
+			if ok {
+				resources = append(resources, CNAME_RRs...)
+				resources = append(resources, RRs...)
+			}
+		}
+	}
+
+	RRs, ok := store.Get(name, recType)
+	if !ok && recType == TYPE_CNAME {
+		RRs, ok = cacheSynthesizeCNAME(store, name)
+	}
+	if ok {
+		resources = append(resources, RRs...)
+	}
+
+	if len(resources) > 0 {
+		return resources, true
+	} else {
+		return nil, false
+	}
+}
+
+// Looks for a DNAME record cached for the closest ancestor of the domain name and returns it, along with the CNAME record synthesized from it.
+func cacheSynthesizeCNAME(store CacheStore, name string) ([]Resource, bool) {
+	for labels := CountLabels(name) - 1; labels > 0; labels-- {
+		DNAME_RRs, ok := store.Get(LastLabels(name, labels), TYPE_DNAME)
+		if !ok {
+			continue
+		}
+
+		CNAME_RR, err := SynthesizeCNAME(name, DNAME_RRs[0])
+		if err != nil {
+			return nil, false
+		}
+		return []Resource{DNAME_RRs[0], CNAME_RR}, true
+	}
+
+	return nil, false
+}
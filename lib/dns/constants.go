@@ -1,5 +1,9 @@
 package dns
 
+import (
+	"time"
+)
+
 const (
 	DNS_PORT_NUMBER = 53
 	MESSAGE_PROTOCOL = "udp"
@@ -10,14 +14,110 @@ const (
 	NEWLINE_SEPERATOR = "\n"
 	ADDRESS_IPv4 = "IPv4"
 	ADDRESS_IPv6 = "IPv6"
+	SNAPSHOT_MAGIC = "ATHS"
+	SNAPSHOT_VERSION = uint8(1)
+	CACHE_SWEEP_INTERVAL = time.Minute
+	SOURCE_PORT_ATTEMPTS = 10
+	MIN_SOURCE_PORT = 1024
+	UDP_RESPONSE_TIMEOUT = 5 * time.Second
+	UNKNOWN_SERVER_SRTT = 50 * time.Millisecond
+	SRTT_WEIGHT = 0.3
+	SERVER_FAILURE_LIMIT = 3
+	SERVER_EXPLORATION_RATE = 0.05
+	MAX_SERVER_ATTEMPTS = 3
+	MAX_PARALLEL_NS_LOOKUPS = 4
+	HAPPY_EYEBALLS_DELAY = 50 * time.Millisecond
+	TRANSPORT_IPv4 = "ipv4"
+	TRANSPORT_IPv6 = "ipv6"
+	TRANSPORT_BOTH = "both"
+	MAX_REFERRALS = 20
+	MAX_CNAME_CHAIN = 12
+	MAX_UPSTREAM_QUERIES = 100
+	MAX_RESOLUTION_TIME = 30 * time.Second
+	MAX_NS_LOOKUP_DEPTH = 4
+	MAX_DOMAIN_NAME_LENGTH = 255
+	MAX_COMPRESSION_POINTERS = 127
+	CAA_CRITICAL_FLAG = uint8(128)
+	DNSKEY_ZONE_KEY_FLAG = uint16(256)
+	DNSKEY_SEP_FLAG = uint16(1)
+	NSEC3_OPT_OUT_FLAG = uint8(1)
+	RRSIG_TIME_FORMAT = "20060102150405"
+	DNSKEY_PROTOCOL = uint8(3)
+	EDNS_DO_BIT = uint32(1 << 15)
+	SECURE_ZONE_KEY_TTL_LIMIT = 24 * time.Hour
+	INSECURE_ZONE_KEY_TTL = 15 * time.Minute
+	BOGUS_ZONE_KEY_TTL = time.Minute
+	DNSKEY_REVOKE_FLAG = uint16(128)
+	ANCHOR_ADD_HOLD_DOWN = 30 * 24 * time.Hour
+	ANCHOR_REMOVE_HOLD_DOWN = 30 * 24 * time.Hour
+	ANCHOR_COMMENT = ";"
+	NSEC3_MAX_ITERATIONS = 150
+	WILDCARD_LABEL = "*"
+	DOT_PORT_NUMBER = 853
+	UPSTREAM_RESPONSE_TIMEOUT = 5 * time.Second
+	UPSTREAM_FAILURE_HOLD = 30 * time.Second
+	DOH_MEDIA_TYPE = "application/dns-message"
+	STREAM_MESSAGE_SIZE_LIMIT = 65535
+	EDNS_PADDING_OPTION = uint16(12)
+	RESPONSE_PADDING_BLOCK_SIZE = 468
+	DOH_PATH = "/dns-query"
+	SERVER_IDLE_TIMEOUT = 30 * time.Second
+	SERVER_QUERY_TIMEOUT = 10 * time.Second
+	SERVER_CONNECTION_QUERY_LIMIT = 16
+	UDP_DEFAULT_PAYLOAD_SIZE = 512
+)
+
+const (
+	ALGORITHM_RSASHA256 uint8 = 8
+	ALGORITHM_RSASHA512 uint8 = 10
+	ALGORITHM_ECDSAP256SHA256 uint8 = 13
+	ALGORITHM_ECDSAP384SHA384 uint8 = 14
+	ALGORITHM_ED25519 uint8 = 15
+
+	DIGEST_SHA1 uint8 = 1
+	DIGEST_SHA256 uint8 = 2
+	DIGEST_SHA384 uint8 = 4
+
+	NSEC3_HASH_SHA1 uint8 = 1
+
+	SECURITY_INSECURE SecurityStatus = 0
+	SECURITY_SECURE SecurityStatus = 1
+	SECURITY_BOGUS SecurityStatus = 2
+
+	ANCHOR_ADD_PENDING AnchorState = 1
+	ANCHOR_VALID AnchorState = 2
+	ANCHOR_MISSING AnchorState = 3
+	ANCHOR_REVOKED AnchorState = 4
+)
+
+const (
+	SVC_PARAM_MANDATORY uint16 = 0
+	SVC_PARAM_ALPN uint16 = 1
+	SVC_PARAM_NO_DEFAULT_ALPN uint16 = 2
+	SVC_PARAM_PORT uint16 = 3
+	SVC_PARAM_IPV4HINT uint16 = 4
+	SVC_PARAM_ECH uint16 = 5
+	SVC_PARAM_IPV6HINT uint16 = 6
 )
 
 const (
 	TYPE_A     RecordType = 1
 	TYPE_NS    RecordType = 2
 	TYPE_CNAME RecordType = 5
+	TYPE_SOA   RecordType = 6
 	TYPE_TXT   RecordType = 16
 	TYPE_AAAA  RecordType = 28
+	TYPE_DNAME RecordType = 39
+	TYPE_OPT   RecordType = 41
+	TYPE_DS    RecordType = 43
+	TYPE_RRSIG RecordType = 46
+	TYPE_NSEC  RecordType = 47
+	TYPE_DNSKEY RecordType = 48
+	TYPE_NSEC3 RecordType = 50
+	TYPE_NSEC3PARAM RecordType = 51
+	TYPE_SVCB  RecordType = 64
+	TYPE_HTTPS RecordType = 65
+	TYPE_CAA   RecordType = 257
 
 	OPCODE_QUERY Flag = 0
 	OPCODE_IQUERY Flag = 1
@@ -68,8 +168,35 @@ var AllowedRRTypes RecordTypes = RecordTypes{
 	"A":     TYPE_A,
 	"NS":    TYPE_NS,
 	"CNAME": TYPE_CNAME,
+	"SOA":   TYPE_SOA,
 	"TXT":   TYPE_TXT,
 	"AAAA":  TYPE_AAAA,
+	"DNAME": TYPE_DNAME,
+	"CAA":   TYPE_CAA,
+	"SVCB":  TYPE_SVCB,
+	"HTTPS": TYPE_HTTPS,
+	"DS":    TYPE_DS,
+	"RRSIG": TYPE_RRSIG,
+	"NSEC":  TYPE_NSEC,
+	"DNSKEY": TYPE_DNSKEY,
+	"NSEC3": TYPE_NSEC3,
+	"NSEC3PARAM": TYPE_NSEC3PARAM,
+}
+
+var SvcParamKeys = map[string]uint16{
+	"mandatory":       SVC_PARAM_MANDATORY,
+	"alpn":            SVC_PARAM_ALPN,
+	"no-default-alpn": SVC_PARAM_NO_DEFAULT_ALPN,
+	"port":            SVC_PARAM_PORT,
+	"ipv4hint":        SVC_PARAM_IPV4HINT,
+	"ech":             SVC_PARAM_ECH,
+	"ipv6hint":        SVC_PARAM_IPV6HINT,
+}
+
+//DS records of the root zone keys (KSK-2017 and KSK-2024) published by IANA, used as the default trust anchors for DNSSEC validation.
+var RootTrustAnchors = []string{
+	"20326 8 2 E06D44B80B8F1D39A95C0B0D7C65D08458E880409BBC683457104237C7F8EC8D",
+	"38696 8 2 683D2D0ACB8C9B712A1948B27F741219298D0A450D612C483AF444A4C0FB2B16",
 }
 
 var AllowedClassTypes ClassTypes = ClassTypes{
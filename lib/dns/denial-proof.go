@@ -0,0 +1,467 @@
+package dns
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha1"
+	"encoding/base32"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+//Outcome of checking NSEC or NSEC3 records against a domain name and record type.
+type denialProof struct {
+	//RC_NXDOMAIN if the domain name is proven not to exist, or RC_NOERROR if it is proven to have no records of the type.
+	rcode ResponseCode
+	//Security status of the proof. A proof relying on an opt-out NSEC3 record, or on NSEC3 records the resolver does not check,
+	//is insecure and is not matched against the response code.
+	status SecurityStatus
+	//NSEC or NSEC3 records the proof is made of.
+	records []Resource
+}
+
+//Validates the denial of existence carried by the authority section of a negative response received from a name server of 'zone'
+//for the given domain name and record type (RFC 4035 - Section 5.4 and RFC 5155 - Section 8). Returns the records to be added to the
+//authority section of the response being formed, which are the SOA record and, when the DO bit was set upstream, the NSEC or NSEC3
+//records along with the RRSIG records covering them, and the security status of the denial. A denial received from a signed zone
+//is secure only if its NSEC or NSEC3 records prove it, and bogus otherwise. Records of a secure denial are kept for aggressive negative caching.
+func (resolver *Resolver) secureDenial(response *Message, name string, recType RecordType, zone string) ([]Resource, SecurityStatus, error) {
+	SOA_RRs, _ := response.FindAuthorityRecords(TYPE_SOA)
+	if !resolver.dnssec {
+		return SOA_RRs, SECURITY_INSECURE, nil
+	}
+
+	records, denials := make([]Resource, 0), make([]Resource, 0)
+	status, signer := SECURITY_SECURE, ""
+	for _, denialType := range []RecordType{TYPE_SOA, TYPE_NSEC, TYPE_NSEC3} {
+		RRs, _ := response.FindAuthorityRecords(denialType)
+		for _, rr := range RRs {
+			if !IsSubDomain(rr.Name.Value, zone) {
+				continue
+			}
+
+			signatures, _ := response.FindAuthoritySignaturesFor(rr.Name.Value, rr.Type, zone)
+			if resolver.isValidating() {
+				rrStatus, wildcard, err := resolver.validateRRSet([]Resource{rr}, signatures, zone)
+				if rrStatus == SECURITY_SECURE && wildcard != "" {
+					//NSEC and NSEC3 records are never synthesized from a wildcard, such a record is a wildcard RRset replayed under another name.
+					rrStatus, err = SECURITY_BOGUS, fmt.Errorf("signature covers %s instead", wildcard)
+				}
+				if rrStatus == SECURITY_BOGUS {
+					return nil, SECURITY_BOGUS, fmt.Errorf("%s type record of %s: %s", rr.Type.String(), rr.Name.Value, err.Error())
+				} else if rrStatus == SECURITY_INSECURE {
+					status = SECURITY_INSECURE
+				} else if rr.Type != TYPE_SOA && signer == "" {
+					signer = signatures[0].Rdata.(*RRSIGResource).SignerName.Value
+				}
+			}
+
+			records = append(records, rr)
+			records = append(records, signatures...)
+			if rr.Type != TYPE_SOA {
+				denials = append(denials, rr)
+			}
+		}
+	}
+
+	if !resolver.isValidating() {
+		return records, SECURITY_INSECURE, nil
+	} else if len(denials) == 0 {
+		entry := resolver.zoneKeys(zone)
+		if entry.status == SECURITY_SECURE {
+			return nil, SECURITY_BOGUS, fmt.Errorf("no NSEC or NSEC3 records found in the signed zone %s", Canonicalize(zone))
+		}
+		return records, entry.status, entry.err
+	} else if status == SECURITY_INSECURE {
+		return records, SECURITY_INSECURE, nil
+	}
+
+	proof, ok := proveDenial(name, recType, denials)
+	if !ok || (proof.status == SECURITY_SECURE && proof.rcode != response.Header.Rcode) {
+		return nil, SECURITY_BOGUS, fmt.Errorf("NSEC or NSEC3 records do not prove the %s response for %s type records of %s", response.Header.Rcode.String(), recType.String(), name)
+	}
+
+	if proof.status == SECURITY_SECURE {
+		resolver.cacheDenial(signer, records)
+	}
+	return records, proof.status, nil
+}
+
+//Validates the proof that must go along with an answer for 'name' expanded from 'wildcard', received from a name server of 'zone':
+//the NSEC or NSEC3 records of the authority section must prove that no name closer to 'name' than the wildcard exists, as the answer
+//would not have been synthesized otherwise (RFC 4035 - Section 5.3.4 and RFC 5155 - Section 8.8). Returns the validated NSEC or NSEC3
+//records, each followed by the RRSIG records covering it, and the security status of the answer, which is bogus without a proof.
+//The records are kept in the negative cache, so that the answer can be trusted again when it is served from the cache.
+func (resolver *Resolver) secureWildcardAnswer(response *Message, name string, wildcard string, zone string) ([]Resource, SecurityStatus, error) {
+	records, denials := make([]Resource, 0), make([]Resource, 0)
+	signer := ""
+	for _, denialType := range []RecordType{TYPE_NSEC, TYPE_NSEC3} {
+		RRs, _ := response.FindAuthorityRecords(denialType)
+		for _, rr := range RRs {
+			if !IsSubDomain(rr.Name.Value, zone) {
+				continue
+			}
+
+			signatures, _ := response.FindAuthoritySignaturesFor(rr.Name.Value, rr.Type, zone)
+			status, expanded, _ := resolver.validateRRSet([]Resource{rr}, signatures, zone)
+			if status != SECURITY_SECURE || expanded != "" {
+				continue
+			}
+			if signer == "" {
+				signer = signatures[0].Rdata.(*RRSIGResource).SignerName.Value
+			}
+			records = append(records, rr)
+			records = append(records, signatures...)
+			denials = append(denials, rr)
+		}
+	}
+
+	proof, ok := proveWildcardExpansion(name, wildcard, denials)
+	if !ok {
+		return nil, SECURITY_BOGUS, fmt.Errorf("no NSEC or NSEC3 record proves that %s does not exist, which its answer expanded from %s relies on", name, wildcard)
+	}
+	resolver.cacheDenial(signer, records)
+	return records, proof.status, nil
+}
+
+//Checks whether the given NSEC or NSEC3 records, which must have been validated already, prove that 'name' does not exist or has
+//no records of 'recType'. NSEC3 records are only looked at if there are no NSEC records.
+func proveDenial(name string, recType RecordType, records []Resource) (denialProof, bool) {
+	NSEC_RRs, NSEC3_RRs := make([]Resource, 0), make([]Resource, 0)
+	for _, rr := range records {
+		if rr.Type == TYPE_NSEC {
+			NSEC_RRs = append(NSEC_RRs, rr)
+		} else if rr.Type == TYPE_NSEC3 {
+			NSEC3_RRs = append(NSEC3_RRs, rr)
+		}
+	}
+
+	if len(NSEC_RRs) > 0 {
+		return proveNSECDenial(Canonicalize(name), recType, NSEC_RRs)
+	} else if len(NSEC3_RRs) > 0 {
+		return proveNSEC3Denial(Canonicalize(name), recType, NSEC3_RRs)
+	}
+	return denialProof{}, false
+}
+
+//Checks the NSEC records against the domain name and record type, as per RFC 4035 - Section 5.4. The domain name has no records
+//of the type if the NSEC record it owns does not list the type, or if it is an empty non-terminal. It does not exist if an NSEC
+//record covers it and another one covers the wildcard at its closest encloser, while a wildcard that exists without records of
+//the type proves that there are no records of the type instead.
+func proveNSECDenial(name string, recType RecordType, NSEC_RRs []Resource) (denialProof, bool) {
+	match, ok := findMatchingNSEC(name, NSEC_RRs)
+	if ok {
+		if !deniesType(recType, match.Rdata.(*NSECResource).Types) {
+			return denialProof{}, false
+		}
+		return denialProof{rcode: RC_NOERROR, status: SECURITY_SECURE, records: []Resource{match}}, true
+	}
+
+	cover, ok := findCoveringNSEC(name, NSEC_RRs)
+	if !ok {
+		return denialProof{}, false
+	}
+
+	next := cover.Rdata.(*NSECResource).NextDomain.Value
+	if IsSubDomain(next, name) {
+		//The domain name is an empty non-terminal, which exists without any records of its own.
+		return denialProof{rcode: RC_NOERROR, status: SECURITY_SECURE, records: []Resource{cover}}, true
+	}
+
+	encloser := commonAncestor(name, cover.Name.Value)
+	if other := commonAncestor(name, next); CountLabels(other) > CountLabels(encloser) {
+		encloser = other
+	}
+
+	wildcard := wildcardOf(encloser)
+	match, ok = findMatchingNSEC(wildcard, NSEC_RRs)
+	if ok {
+		if !deniesType(recType, match.Rdata.(*NSECResource).Types) {
+			return denialProof{}, false
+		}
+		return denialProof{rcode: RC_NOERROR, status: SECURITY_SECURE, records: []Resource{cover, match}}, true
+	}
+
+	wildcardCover, ok := findCoveringNSEC(wildcard, NSEC_RRs)
+	if !ok {
+		return denialProof{}, false
+	}
+
+	return denialProof{rcode: RC_NXDOMAIN, status: SECURITY_SECURE, records: appendProof([]Resource{cover}, wildcardCover)}, true
+}
+
+//Checks the NSEC3 records against the domain name and record type, as per RFC 5155 - Section 8. The domain name has no records of
+//the type if the NSEC3 record matching its hash does not list the type. Otherwise the closest encloser proof must hold: an NSEC3
+//record matches the closest existing ancestor of the domain name and another one covers the next closer name. The domain name then
+//does not exist if an NSEC3 record covers the wildcard at the closest encloser, or has no records of the type if the wildcard exists
+//without them. The proof is insecure if the next closer name is covered by an opt-out NSEC3 record, and NSEC3 records using an
+//unknown hash algorithm or too many iterations (RFC 9276 - Section 3.2) are not checked at all.
+func proveNSEC3Denial(name string, recType RecordType, NSEC3_RRs []Resource) (denialProof, bool) {
+	zone := parentOf(NSEC3_RRs[0].Name.Value)
+	for _, rr := range NSEC3_RRs {
+		nsec3 := rr.Rdata.(*NSEC3Resource)
+		if !strings.EqualFold(parentOf(rr.Name.Value), zone) {
+			return denialProof{}, false
+		} else if nsec3.HashAlgorithm != NSEC3_HASH_SHA1 || nsec3.Iterations > NSEC3_MAX_ITERATIONS {
+			return denialProof{status: SECURITY_INSECURE, records: NSEC3_RRs}, true
+		}
+	}
+	if !IsSubDomain(name, zone) {
+		return denialProof{}, false
+	}
+
+	match, ok := findMatchingNSEC3(name, NSEC3_RRs)
+	if ok {
+		if !deniesType(recType, match.Rdata.(*NSEC3Resource).Types) {
+			return denialProof{}, false
+		}
+		return denialProof{rcode: RC_NOERROR, status: SECURITY_SECURE, records: []Resource{match}}, true
+	}
+
+	encloser, nextCloser := "", ""
+	for labels := CountLabels(name) - 1; labels >= CountLabels(zone); labels-- {
+		match, ok = findMatchingNSEC3(LastLabels(name, labels), NSEC3_RRs)
+		if ok {
+			encloser, nextCloser = LastLabels(name, labels), LastLabels(name, labels + 1)
+			break
+		}
+	}
+
+	if encloser == "" {
+		return denialProof{}, false
+	}
+	types := match.Rdata.(*NSEC3Resource).Types
+	if slices.Contains(types, TYPE_DNAME) || (slices.Contains(types, TYPE_NS) && !slices.Contains(types, TYPE_SOA)) {
+		//Names below a delegation point or a DNAME record are not part of the zone, so their non-existence cannot be proven here.
+		return denialProof{}, false
+	}
+
+	cover, ok := findCoveringNSEC3(nextCloser, NSEC3_RRs)
+	if !ok {
+		return denialProof{}, false
+	}
+
+	proof := denialProof{status: SECURITY_SECURE, records: appendProof([]Resource{match}, cover)}
+	if cover.Rdata.(*NSEC3Resource).IsOptOut() {
+		proof.status = SECURITY_INSECURE
+		if recType == TYPE_DS {
+			//The domain name may be an unsigned delegation left out of the NSEC3 chain (RFC 5155 - Section 8.6).
+			proof.rcode = RC_NOERROR
+			return proof, true
+		}
+	}
+
+	wildcard := wildcardOf(encloser)
+	wildcardMatch, ok := findMatchingNSEC3(wildcard, NSEC3_RRs)
+	if ok {
+		if !deniesType(recType, wildcardMatch.Rdata.(*NSEC3Resource).Types) {
+			return denialProof{}, false
+		}
+		proof.rcode, proof.records = RC_NOERROR, appendProof(proof.records, wildcardMatch)
+		return proof, true
+	}
+
+	wildcardCover, ok := findCoveringNSEC3(wildcard, NSEC3_RRs)
+	if !ok {
+		return denialProof{}, false
+	}
+	proof.rcode, proof.records = RC_NXDOMAIN, appendProof(proof.records, wildcardCover)
+	return proof, true
+}
+
+//Checks whether the given NSEC or NSEC3 records, which must have been validated already, prove that an answer for 'name' could be
+//expanded from 'wildcard': the next closer name, which lies directly below the closest encloser on the way to 'name', must not exist.
+//An NSEC record must cover the next closer name without pointing to a name below it, or an NSEC3 record must cover its hash. The proof
+//is insecure if it relies on an opt-out NSEC3 record, or on NSEC3 records the resolver does not check.
+func proveWildcardExpansion(name string, wildcard string, records []Resource) (denialProof, bool) {
+	encloser := parentOf(wildcard)
+	if !IsSubDomain(name, encloser) || CountLabels(name) <= CountLabels(encloser) {
+		return denialProof{}, false
+	}
+	nextCloser := LastLabels(name, CountLabels(encloser) + 1)
+
+	NSEC_RRs, NSEC3_RRs := make([]Resource, 0), make([]Resource, 0)
+	for _, rr := range records {
+		if rr.Type == TYPE_NSEC {
+			NSEC_RRs = append(NSEC_RRs, rr)
+		} else if rr.Type == TYPE_NSEC3 {
+			NSEC3_RRs = append(NSEC3_RRs, rr)
+		}
+	}
+
+	if len(NSEC_RRs) > 0 {
+		cover, ok := findCoveringNSEC(nextCloser, NSEC_RRs)
+		if !ok || IsSubDomain(cover.Rdata.(*NSECResource).NextDomain.Value, nextCloser) {
+			return denialProof{}, false
+		}
+		return denialProof{rcode: RC_NOERROR, status: SECURITY_SECURE, records: []Resource{cover}}, true
+	} else if len(NSEC3_RRs) == 0 {
+		return denialProof{}, false
+	}
+
+	for _, rr := range NSEC3_RRs {
+		nsec3 := rr.Rdata.(*NSEC3Resource)
+		if nsec3.HashAlgorithm != NSEC3_HASH_SHA1 || nsec3.Iterations > NSEC3_MAX_ITERATIONS {
+			return denialProof{rcode: RC_NOERROR, status: SECURITY_INSECURE, records: NSEC3_RRs}, true
+		}
+	}
+	cover, ok := findCoveringNSEC3(nextCloser, NSEC3_RRs)
+	if !ok {
+		return denialProof{}, false
+	}
+	proof := denialProof{rcode: RC_NOERROR, status: SECURITY_SECURE, records: []Resource{cover}}
+	if cover.Rdata.(*NSEC3Resource).IsOptOut() {
+		proof.status = SECURITY_INSECURE
+	}
+	return proof, true
+}
+
+//Adds the NSEC or NSEC3 record to the records of a proof, unless the proof already holds it.
+func appendProof(records []Resource, rr Resource) []Resource {
+	for _, existing := range records {
+		if strings.EqualFold(existing.Name.Value, rr.Name.Value) {
+			return records
+		}
+	}
+	return append(records, rr)
+}
+
+//Returns true if the type bitmap of the NSEC or NSEC3 record owned by a domain name proves that it has no records of 'recType'.
+//A CNAME record would answer queries of any type, and the records found at a delegation point belong to the child zone, except
+//for DS records, which can only be denied by the parent zone at a delegation point, whose record has the NS bit set and the SOA
+//bit clear (RFC 6840 - Section 4.4).
+func deniesType(recType RecordType, types []RecordType) bool {
+	if slices.Contains(types, recType) || slices.Contains(types, TYPE_CNAME) {
+		return false
+	} else if recType == TYPE_DS {
+		return slices.Contains(types, TYPE_NS) && !slices.Contains(types, TYPE_SOA)
+	}
+	return !slices.Contains(types, TYPE_NS) || slices.Contains(types, TYPE_SOA)
+}
+
+//Returns the NSEC record owned by the given domain name.
+func findMatchingNSEC(name string, NSEC_RRs []Resource) (Resource, bool) {
+	for _, rr := range NSEC_RRs {
+		if strings.EqualFold(rr.Name.Value, name) {
+			return rr, true
+		}
+	}
+	return Resource{}, false
+}
+
+//Returns the NSEC record whose owner comes before the given domain name in canonical order and whose next domain name comes after it.
+//The last NSEC record of a zone points back at the apex, so it covers every name of the zone that comes after its owner. Names below
+//a delegation point or a DNAME record are not part of the zone and are never covered.
+func findCoveringNSEC(name string, NSEC_RRs []Resource) (Resource, bool) {
+	for _, rr := range NSEC_RRs {
+		owner, nsec := rr.Name.Value, rr.Rdata.(*NSECResource)
+		if IsSubDomain(name, owner) && (nsec.HasType(TYPE_DNAME) || (nsec.HasType(TYPE_NS) && !nsec.HasType(TYPE_SOA))) {
+			continue
+		}
+
+		next := nsec.NextDomain.Value
+		if CompareNames(owner, name) >= 0 {
+			continue
+		} else if CompareNames(owner, next) < 0 && CompareNames(name, next) < 0 {
+			return rr, true
+		} else if CompareNames(owner, next) >= 0 && IsSubDomain(name, next) {
+			return rr, true
+		}
+	}
+	return Resource{}, false
+}
+
+//Returns the NSEC3 record whose owner name holds the hash of the given domain name.
+func findMatchingNSEC3(name string, NSEC3_RRs []Resource) (Resource, bool) {
+	for _, rr := range NSEC3_RRs {
+		nsec3 := rr.Rdata.(*NSEC3Resource)
+		if bytes.Equal(nsec3OwnerHash(rr), HashNSEC3Name(name, nsec3.Iterations, nsec3.Salt)) {
+			return rr, true
+		}
+	}
+	return Resource{}, false
+}
+
+//Returns the NSEC3 record whose owner hash comes before the hash of the given domain name and whose next hashed owner comes after
+//it. The last NSEC3 record of the hash order points back at the first one, so it covers the hashes after its owner hash as well as
+//those before the first one.
+func findCoveringNSEC3(name string, NSEC3_RRs []Resource) (Resource, bool) {
+	for _, rr := range NSEC3_RRs {
+		nsec3 := rr.Rdata.(*NSEC3Resource)
+		hash, owner, next := HashNSEC3Name(name, nsec3.Iterations, nsec3.Salt), nsec3OwnerHash(rr), nsec3.NextHashedOwner
+		if owner == nil {
+			continue
+		} else if bytes.Compare(owner, next) < 0 && bytes.Compare(owner, hash) < 0 && bytes.Compare(hash, next) < 0 {
+			return rr, true
+		} else if bytes.Compare(owner, next) >= 0 && (bytes.Compare(owner, hash) < 0 || bytes.Compare(hash, next) < 0) {
+			return rr, true
+		}
+	}
+	return Resource{}, false
+}
+
+//Computes the NSEC3 hash of the domain name (RFC 5155 - Section 5), which is the SHA-1 digest of its canonical wire form followed
+//by the salt, hashed again along with the salt for the given number of additional iterations.
+func HashNSEC3Name(name string, iterations uint16, salt []byte) []byte {
+	digest := sha1.Sum(append(packCanonicalName(name), salt...))
+	for range iterations {
+		digest = sha1.Sum(append(digest[:], salt...))
+	}
+	return digest[:]
+}
+
+//Returns the hash held by the first label of the owner name of the NSEC3 record, or nil if the label is not valid base32hex.
+func nsec3OwnerHash(NSEC3_RR Resource) []byte {
+	label, _, _ := strings.Cut(NSEC3_RR.Name.Value, DOMAIN_LABEL_SEPERATOR)
+	hash, err := base32.HexEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(label))
+	if err != nil {
+		return nil
+	}
+	return hash
+}
+
+//Compares two domain names in the canonical order of RFC 4034 - Section 6.1, in which names are sorted by their labels from the
+//rightmost one, each compared as lowercase octets, and a name comes before its subdomains. Returns -1, 0 or +1.
+func CompareNames(first string, second string) int {
+	firstLabels, secondLabels := splitLabels(first), splitLabels(second)
+	for index := 1; index <= min(len(firstLabels), len(secondLabels)); index++ {
+		result := strings.Compare(firstLabels[len(firstLabels) - index], secondLabels[len(secondLabels) - index])
+		if result != 0 {
+			return result
+		}
+	}
+	return cmp.Compare(len(firstLabels), len(secondLabels))
+}
+
+//Returns the lowercase labels of the given domain name. The root domain has no labels.
+func splitLabels(domainName string) []string {
+	domainName = strings.Trim(Canonicalize(domainName), DOMAIN_LABEL_SEPERATOR)
+	if domainName == "" {
+		return make([]string, 0)
+	}
+	return strings.Split(domainName, DOMAIN_LABEL_SEPERATOR)
+}
+
+//Returns the longest domain name that both given domain names are equal to or subdomains of.
+func commonAncestor(first string, second string) string {
+	firstLabels, secondLabels := splitLabels(first), splitLabels(second)
+	common := 0
+	for common < min(len(firstLabels), len(secondLabels)) && firstLabels[len(firstLabels) - 1 - common] == secondLabels[len(secondLabels) - 1 - common] {
+		common++
+	}
+	return LastLabels(first, common)
+}
+
+//Returns the wildcard domain name directly below the given domain name.
+func wildcardOf(domainName string) string {
+	if CountLabels(domainName) == 0 {
+		return WILDCARD_LABEL + DOMAIN_LABEL_SEPERATOR
+	}
+	return WILDCARD_LABEL + DOMAIN_LABEL_SEPERATOR + Canonicalize(domainName)
+}
+
+//Returns the domain name of the parent of the given domain name.
+func parentOf(domainName string) string {
+	return LastLabels(domainName, CountLabels(domainName) - 1)
+}
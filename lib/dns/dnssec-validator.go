@@ -0,0 +1,588 @@
+package dns
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+//Represents the outcome of validating DNSSEC data, as defined in RFC 4035 - Section 4.3.
+type SecurityStatus uint8
+
+//Returns the string representation of the security status.
+func (status SecurityStatus) String() string {
+	switch status {
+	case SECURITY_SECURE:
+		return "secure"
+	case SECURITY_INSECURE:
+		return "insecure"
+	case SECURITY_BOGUS:
+		return "bogus"
+	default:
+		return ""
+	}
+}
+
+//Outcome of authenticating the DNSKEY RRset of a zone, kept by the resolver until it expires.
+type zoneKeyEntry struct {
+	//Security status of the zone. Only the keys of a secure zone can be used to validate its records.
+	status SecurityStatus
+	//Authenticated zone keys of the zone, if the zone is secure.
+	keys []Resource
+	//Reason the zone is bogus, if it is.
+	err error
+	//Time after which the zone needs to be authenticated again.
+	expires time.Time
+}
+
+//Returns true if the resolver validates the answers it receives, which is the case when DNSSEC validation is enabled and
+//checking has not been disabled for the query being answered.
+func (resolver *Resolver) isValidating() bool {
+	return resolver.dnssec && !resolver.checkingDisabled
+}
+
+//Marks the response being formed as authenticated if its answers are to be validated. The flag is cleared as soon as
+//any data that is not secure is added to the response.
+func (resolver *Resolver) resetSecurity() {
+	resolver.response.Header.Authenticated = resolver.isValidating()
+	resolver.response.Header.CheckingDisabled = resolver.dnssec && resolver.checkingDisabled
+}
+
+//Takes the security status of data added to the response being formed into account. Only a response made up of secure data
+//keeps the AD flag set.
+func (resolver *Resolver) recordSecurity(status SecurityStatus) {
+	if status != SECURITY_SECURE {
+		resolver.response.Header.Authenticated = false
+	}
+}
+
+//Validates the RRset of the given domain name and record type found in the answer section of a response received from a name
+//server of 'zone', and returns the RRset along with the RRSIG records covering it. A bogus RRset ends the resolution with ErrBogus,
+//so that it is neither cached nor returned to the client. The RRSIG records are returned whenever the DO bit was set upstream.
+//An RRset expanded from a wildcard is only secure along with the NSEC or NSEC3 records proving that no closer name exists, which
+//are added to the authority section of the response being formed. The RRset is then cached no longer than those records.
+func (resolver *Resolver) secureRRSet(response *Message, name string, recType RecordType, zone string, RRs []Resource) ([]Resource, error) {
+	if !resolver.dnssec || recType == TYPE_RRSIG {
+		return RRs, nil
+	}
+
+	signatures, _ := response.FindSignaturesFor(name, recType, zone)
+	if resolver.isValidating() {
+		status, wildcard, err := resolver.validateRRSet(RRs, signatures, zone)
+		if status == SECURITY_SECURE && wildcard != "" {
+			var proof []Resource
+			proof, status, err = resolver.secureWildcardAnswer(response, name, wildcard, zone)
+			if status != SECURITY_BOGUS {
+				resolver.response.AddAuthorities(proof)
+				RRs, signatures = limitTTL(RRs, proof), limitTTL(signatures, proof)
+			}
+		}
+		if status == SECURITY_BOGUS {
+			return nil, fmt.Errorf("%w: %s type records of %s: %s", ErrBogus, recType.String(), name, err.Error())
+		}
+		resolver.Log(fmt.Sprintf("%s type records of %s are %s.", recType.String(), name, status.String()))
+		resolver.recordSecurity(status)
+	}
+
+	records := make([]Resource, 0, len(RRs) + len(signatures))
+	records = append(records, RRs...)
+	records = append(records, signatures...)
+	return records, nil
+}
+
+//Validates the RRsets served from the cache, using the RRSIG records cached along with them, and returns them along with those RRSIG records.
+//An RRset cached without RRSIG records is only insecure if the closest zone known to enclose it is not secure, and bogus otherwise, except for
+//CNAME records synthesized from a DNAME record.
+//An RRset expanded from a wildcard must still be backed by the NSEC or NSEC3 records kept in the negative cache when it was received.
+func (resolver *Resolver) secureCachedRecords(records []Resource) ([]Resource, error) {
+	if !resolver.dnssec {
+		return records, nil
+	}
+
+	secured := make([]Resource, 0, len(records))
+	for start := 0; start < len(records); {
+		end := start + 1
+		for end < len(records) && records[end].Type == records[start].Type && strings.EqualFold(records[end].Name.Value, records[start].Name.Value) {
+			end++
+		}
+
+		RRs := records[start: end]
+		owner, recType := RRs[0].Name.Value, RRs[0].Type
+		signatures := make([]Resource, 0)
+		cachedSignatures, _ := resolver.Cache.Get(owner, TYPE_RRSIG)
+		for _, sig := range cachedSignatures {
+			if sig.Rdata.(*RRSIGResource).TypeCovered == recType {
+				signatures = append(signatures, sig)
+			}
+		}
+
+		synthesized := recType == TYPE_CNAME && start > 0 && records[start - 1].Type == TYPE_DNAME
+		if resolver.isValidating() && recType != TYPE_RRSIG && !(synthesized && len(signatures) == 0) {
+			status, wildcard, err := resolver.validateRRSet(RRs, signatures, "")
+			if status == SECURITY_SECURE && wildcard != "" {
+				var proof []Resource
+				proof, status, err = resolver.cachedWildcardProof(owner, wildcard)
+				resolver.response.AddAuthorities(proof)
+			}
+			if status == SECURITY_BOGUS {
+				return nil, fmt.Errorf("%w: cached %s type records of %s: %s", ErrBogus, recType.String(), owner, err.Error())
+			}
+			resolver.recordSecurity(status)
+		}
+
+		secured = append(secured, RRs...)
+		secured = append(secured, signatures...)
+		start = end
+	}
+	return secured, nil
+}
+
+//Determines the security status of the RRset using the RRSIG records covering it (RFC 4035 - Section 5.3). The RRset is secure if
+//one of the signatures verifies with an authenticated key of the signing zone, insecure if the signing zone is provably unsigned,
+//and bogus otherwise. A signing zone is only taken to be unsigned if it is known to be a zone, so that a signer name made up below
+//a signed zone cannot pass forged records off as insecure. An RRset without signatures is insecure only if 'zone', the zone it was
+//received from, is insecure. An empty zone stands for an unknown zone, in which case an RRset without signatures is treated as insecure.
+//When a secure RRset was expanded from a wildcard, the wildcard is returned as well, as the RRset only holds along with a proof that
+//its owner name does not exist.
+func (resolver *Resolver) validateRRSet(RRs []Resource, signatures []Resource, zone string) (SecurityStatus, string, error) {
+	if len(RRs) == 0 {
+		return SECURITY_INSECURE, "", nil
+	}
+
+	owner := RRs[0].Name.Value
+	if len(signatures) == 0 {
+		if zone == "" {
+			//Records served from the cache do not come with the zone they were received from.
+			zone = resolver.enclosingZone(owner, RRs[0].Type)
+		}
+
+		entry := resolver.zoneKeys(zone)
+		if entry.status == SECURITY_SECURE {
+			return SECURITY_BOGUS, "", fmt.Errorf("no RRSIG records found in the signed zone %s", Canonicalize(zone))
+		}
+		return entry.status, "", entry.err
+	}
+
+	lastErr := fmt.Errorf("no RRSIG record signed by a key of the zone")
+	for _, sig := range signatures {
+		rrsig := sig.Rdata.(*RRSIGResource)
+		signer := rrsig.SignerName.Value
+		if !IsSubDomain(owner, signer) || (zone != "" && !IsSubDomain(signer, zone)) {
+			lastErr = fmt.Errorf("signer %s is outside the zone of %s", signer, owner)
+			continue
+		}
+
+		if RRs[0].Type == TYPE_DS && strings.EqualFold(signer, owner) {
+			//DS records are signed by the parent zone, so a child signing its own DS records would authenticate itself.
+			lastErr = fmt.Errorf("DS records of %s are signed by the zone itself", owner)
+			continue
+		}
+
+		entry := resolver.zoneKeys(signer)
+		if entry.status == SECURITY_INSECURE && resolver.isZoneApex(signer, zone) {
+			return SECURITY_INSECURE, "", nil
+		} else if entry.status == SECURITY_INSECURE {
+			lastErr = fmt.Errorf("signer %s of %s is not known to be a zone", signer, owner)
+			continue
+		} else if entry.status == SECURITY_BOGUS {
+			lastErr = entry.err
+			continue
+		}
+
+		for _, key := range entry.keys {
+			wildcard, err := verifyRRSIG(RRs, sig, key, time.Now())
+			if err == nil {
+				return SECURITY_SECURE, wildcard, nil
+			}
+			if !errors.Is(err, ErrKeyMismatch) {
+				lastErr = err
+			}
+		}
+	}
+
+	return SECURITY_BOGUS, "", lastErr
+}
+
+//Returns true if the domain name is known to be the apex of a zone: 'zone', the zone an RRset was received from, the root zone, a zone
+//with trust anchors, or a zone delegated to by a referral, whose NS records are cached.
+func (resolver *Resolver) isZoneApex(name string, zone string) bool {
+	name = Canonicalize(name)
+	if name == DOMAIN_LABEL_SEPERATOR || (zone != "" && name == Canonicalize(zone)) || len(resolver.anchorsFor(name)) > 0 {
+		return true
+	}
+	_, ok := resolver.getDelegationRecords(name, TYPE_NS)
+	return ok
+}
+
+//Returns the closest zone known to enclose the given domain name: the closest ancestor that is the apex of a zone, starting with the
+//domain name itself, or with its parent for DS records, which belong to the parent zone.
+func (resolver *Resolver) enclosingZone(name string, recType RecordType) string {
+	name = Canonicalize(name)
+	labels := CountLabels(name)
+	if recType == TYPE_DS && labels > 0 {
+		labels--
+	}
+	for ; labels > 0; labels-- {
+		zone := LastLabels(name, labels)
+		if resolver.isZoneApex(zone, "") {
+			return zone
+		}
+	}
+	return DOMAIN_LABEL_SEPERATOR
+}
+
+//Returns the authenticated keys of the given zone, along with its security status. The outcome is cached until the keys expire.
+func (resolver *Resolver) zoneKeys(zone string) zoneKeyEntry {
+	zone = Canonicalize(zone)
+	cached, ok := resolver.zoneKeyCache.Load(zone)
+	if ok && time.Now().Before(cached.(zoneKeyEntry).expires) {
+		return cached.(zoneKeyEntry)
+	}
+
+	entry := resolver.authenticateZone(zone)
+	if entry.status == SECURITY_BOGUS {
+		resolver.Log(fmt.Sprintf("Zone %s is bogus: %s", zone, entry.err.Error()))
+	} else {
+		resolver.Log(fmt.Sprintf("Zone %s is %s.", zone, entry.status.String()))
+	}
+	resolver.zoneKeyCache.Store(zone, entry)
+	return entry
+}
+
+//Authenticates the DNSKEY RRset of the given zone by following the chain of trust down from a trust anchor (RFC 4035 - Section 5).
+//The DS records of the zone are taken from the trust anchors if there are any for the zone, or else resolved and validated against
+//the keys of the parent zone. A zone whose parent denies having DS records for it is insecure. Otherwise, the DNSKEY RRset of the zone must be signed by
+//one of the keys the DS records point at.
+func (resolver *Resolver) authenticateZone(zone string) zoneKeyEntry {
+	DS_RRs := resolver.anchorsFor(zone)
+	anchored := len(DS_RRs) > 0
+	if !anchored {
+		if resolver.anchorFile != nil && resolver.anchorFile.HasAnchors(zone) {
+			//The keys of the zone are all pending, revoked or removed, so the zone cannot be taken as unsigned either.
+			return bogusZone(fmt.Errorf("no trust anchor of %s is trusted", zone))
+		} else if zone == DOMAIN_LABEL_SEPERATOR {
+			return insecureZone()
+		}
+
+		RRs, _, status, err := resolver.fetchRRSet(zone, TYPE_DS, true)
+		if errors.Is(err, ErrNoData) || errors.Is(err, ErrNXDomain) {
+			return insecureZone()
+		} else if err != nil {
+			return bogusZone(fmt.Errorf("DS records of %s could not be resolved: %w", zone, err))
+		} else if status != SECURITY_SECURE {
+			return insecureZone()
+		}
+		DS_RRs = RRs
+	}
+
+	supported := make([]Resource, 0)
+	for _, rr := range DS_RRs {
+		ds := rr.Rdata.(*DSResource)
+		if isSupportedAlgorithm(ds.Algorithm) && isSupportedDigest(ds.DigestType) {
+			supported = append(supported, rr)
+		}
+	}
+	if len(supported) == 0 {
+		//A zone signed only with algorithms the resolver does not implement is treated as insecure (RFC 4035 - Section 5.2).
+		return insecureZone()
+	}
+
+	DNSKEY_RRs, signatures, _, err := resolver.fetchRRSet(zone, TYPE_DNSKEY, false)
+	if err != nil {
+		return bogusZone(fmt.Errorf("DNSKEY records of %s could not be resolved: %w", zone, err))
+	}
+
+	keys := make([]Resource, 0)
+	for _, rr := range DNSKEY_RRs {
+		if rr.Rdata.(*DNSKEYResource).IsZoneKey() {
+			keys = append(keys, rr)
+		}
+	}
+
+	lastErr := fmt.Errorf("no DNSKEY record of %s matches its DS records", zone)
+	for _, rr := range DNSKEY_RRs {
+		if !matchesDS(rr, supported) {
+			continue
+		}
+
+		for _, sig := range signatures {
+			_, err := verifyRRSIG(DNSKEY_RRs, sig, rr, time.Now())
+			if err == nil {
+				if anchored {
+					resolver.trackKeyRollover(zone, DNSKEY_RRs, signatures)
+				}
+				return secureZone(keys)
+			}
+			if !errors.Is(err, ErrKeyMismatch) {
+				lastErr = err
+			}
+		}
+	}
+	return bogusZone(lastErr)
+}
+
+//Resolves the RRset of the given domain name and record type on behalf of DNSSEC validation, in a forked resolver whose response
+//is kept apart from the one being formed. Returns the RRset and the RRSIG records covering it, along with the security status of
+//the RRset if 'validate' is true. Otherwise the records are fetched without being validated.
+func (resolver *Resolver) fetchRRSet(name string, recType RecordType, validate bool) ([]Resource, []Resource, SecurityStatus, error) {
+	forked := resolver.fork(name, recType)
+	forked.checkingDisabled = !validate
+	forked.resetSecurity()
+	records, err := forked.resolve(name, recType)
+	if err != nil {
+		return nil, nil, SECURITY_INSECURE, err
+	}
+
+	status := SECURITY_INSECURE
+	if forked.response.Header.Authenticated {
+		status = SECURITY_SECURE
+	}
+	RRs, signatures := splitSignatures(records, recType)
+	return RRs, signatures, status, nil
+}
+
+//Returns the trust anchors configured for the given zone, as DS records.
+func (resolver *Resolver) anchorsFor(zone string) []Resource {
+	if resolver.anchorFile != nil {
+		return resolver.anchorFile.Trusted(zone)
+	}
+
+	anchors := make([]Resource, 0)
+	for _, anchor := range resolver.trustAnchors {
+		if strings.EqualFold(anchor.Name.Value, Canonicalize(zone)) {
+			anchors = append(anchors, anchor)
+		}
+	}
+	return anchors
+}
+
+//Returns the trust anchor as a DS record. DNSKEY records are turned into the DS record holding their SHA-256 digest.
+func trustAnchorDS(anchor Resource) (Resource, error) {
+	if anchor.Type == TYPE_DS {
+		return anchor, nil
+	}
+
+	dnskey, ok := anchor.Rdata.(*DNSKEYResource)
+	if !ok {
+		return Resource{}, ErrInvalidTrustAnchor
+	}
+	digest, _ := DigestDNSKEY(anchor, DIGEST_SHA256)
+	ds := DSResource{KeyTag: dnskey.KeyTag(), Algorithm: dnskey.Algorithm, DigestType: DIGEST_SHA256, Digest: digest}
+	return *NewResourceRecord(anchor.Name.Value, anchor.TTL, anchor.Class.String(), TYPE_DS.String(), ds.String()), nil
+}
+
+//Returns the key entry of a secure zone with the given keys, kept until the first of the keys expires.
+func secureZone(keys []Resource) zoneKeyEntry {
+	ttl := SECURE_ZONE_KEY_TTL_LIMIT
+	for _, key := range keys {
+		ttl = min(ttl, time.Duration(key.TTL) * time.Second)
+	}
+	return zoneKeyEntry{status: SECURITY_SECURE, keys: keys, expires: time.Now().Add(ttl)}
+}
+
+//Returns the key entry of an insecure zone.
+func insecureZone() zoneKeyEntry {
+	return zoneKeyEntry{status: SECURITY_INSECURE, expires: time.Now().Add(INSECURE_ZONE_KEY_TTL)}
+}
+
+//Returns the key entry of a bogus zone, along with the reason it is bogus.
+func bogusZone(err error) zoneKeyEntry {
+	return zoneKeyEntry{status: SECURITY_BOGUS, err: err, expires: time.Now().Add(BOGUS_ZONE_KEY_TTL)}
+}
+
+//Splits the given records into the records of the given type and the RRSIG records covering them.
+func splitSignatures(records []Resource, recType RecordType) ([]Resource, []Resource) {
+	RRs, signatures := make([]Resource, 0), make([]Resource, 0)
+	for _, rr := range records {
+		if rr.Type == recType {
+			RRs = append(RRs, rr)
+		} else if rrsig, ok := rr.Rdata.(*RRSIGResource); ok && rrsig.TypeCovered == recType {
+			signatures = append(signatures, rr)
+		}
+	}
+	return RRs, signatures
+}
+
+//Returns copies of the records with their TTLs lowered to the lowest TTL of the records of the proof they rely on, so that they are
+//not cached for longer than the proof.
+func limitTTL(records []Resource, proof []Resource) []Resource {
+	limited := make([]Resource, 0, len(records))
+	for _, rr := range records {
+		for _, proofRR := range proof {
+			rr.TTL = min(rr.TTL, proofRR.TTL)
+		}
+		limited = append(limited, rr)
+	}
+	return limited
+}
+
+//Returns true if one of the given DS records holds the digest of the DNSKEY record.
+func matchesDS(DNSKEY_RR Resource, DS_RRs []Resource) bool {
+	dnskey := DNSKEY_RR.Rdata.(*DNSKEYResource)
+	for _, rr := range DS_RRs {
+		ds := rr.Rdata.(*DSResource)
+		if ds.KeyTag != dnskey.KeyTag() || ds.Algorithm != dnskey.Algorithm {
+			continue
+		}
+
+		digest, err := DigestDNSKEY(DNSKEY_RR, ds.DigestType)
+		if err == nil && bytes.Equal(digest, ds.Digest) {
+			return true
+		}
+	}
+	return false
+}
+
+//Computes the digest of the DNSKEY record used in DS records (RFC 4034 - Section 5.1.4), which covers its owner name and record data.
+func DigestDNSKEY(DNSKEY_RR Resource, digestType uint8) ([]byte, error) {
+	data := append(packCanonicalName(DNSKEY_RR.Name.Value), DNSKEY_RR.Rdata.(*DNSKEYResource).Pack()...)
+	if digestType == DIGEST_SHA1 {
+		digest := sha1.Sum(data)
+		return digest[:], nil
+	} else if digestType == DIGEST_SHA256 {
+		digest := sha256.Sum256(data)
+		return digest[:], nil
+	} else if digestType == DIGEST_SHA384 {
+		digest := sha512.Sum384(data)
+		return digest[:], nil
+	}
+	return nil, fmt.Errorf("%w: digest type %d", ErrUnsupportedAlgorithm, digestType)
+}
+
+//Verifies the RRSIG record over the RRset with the given DNSKEY record, as per RFC 4035 - Section 5.3. Returns ErrKeyMismatch if
+//the signature was not made with the key, or another error if the signature does not hold. If the signature covers a wildcard
+//that the RRset was expanded from, the wildcard is returned along with the outcome.
+func verifyRRSIG(RRs []Resource, RRSIG_RR Resource, DNSKEY_RR Resource, now time.Time) (string, error) {
+	rrsig := RRSIG_RR.Rdata.(*RRSIGResource)
+	dnskey := DNSKEY_RR.Rdata.(*DNSKEYResource)
+	if dnskey.Protocol != DNSKEY_PROTOCOL || !dnskey.IsZoneKey() || dnskey.Algorithm != rrsig.Algorithm || dnskey.KeyTag() != rrsig.KeyTag ||
+		!strings.EqualFold(DNSKEY_RR.Name.Value, rrsig.SignerName.Value) {
+		return "", ErrKeyMismatch
+	}
+
+	current := uint32(now.Unix())
+	if int32(current - rrsig.Inception) < 0 {
+		return "", fmt.Errorf("%w: signature by key %d is not valid before %s", ErrInvalidSignature, rrsig.KeyTag, formatSignatureTime(rrsig.Inception))
+	} else if int32(rrsig.Expiration - current) < 0 {
+		return "", fmt.Errorf("%w: signature by key %d expired at %s", ErrInvalidSignature, rrsig.KeyTag, formatSignatureTime(rrsig.Expiration))
+	}
+
+	owner, wildcard := RRs[0].Name.Value, ""
+	labels := CountLabels(owner)
+	if int(rrsig.Labels) > labels {
+		return "", fmt.Errorf("%w: signature claims more labels than %s has", ErrInvalidSignature, owner)
+	} else if int(rrsig.Labels) < labels {
+		//The RRset was expanded from a wildcard, which is what the signature covers (RFC 4035 - Section 5.3.2).
+		owner = wildcardOf(LastLabels(owner, int(rrsig.Labels)))
+		if !strings.EqualFold(owner, RRs[0].Name.Value) {
+			wildcard = owner
+		}
+	}
+
+	signed := make([]Resource, 0, len(RRs))
+	for _, rr := range RRs {
+		if rr.Type != rrsig.TypeCovered || rr.Class != RRSIG_RR.Class {
+			return "", fmt.Errorf("%w: signature does not cover %s type records", ErrInvalidSignature, rr.Type.String())
+		}
+		rr.Name = DomainName{}
+		rr.Name.Initialize(owner)
+		signed = append(signed, rr)
+	}
+
+	data := append(rrsig.PackHeader(), CanonicalRRSet(signed, rrsig.OriginalTTL)...)
+	return wildcard, verifySignature(dnskey, data, rrsig.Signature)
+}
+
+//Verifies the signature over the data with the public key, using the algorithm of the key.
+func verifySignature(dnskey *DNSKEYResource, data []byte, signature []byte) error {
+	if dnskey.Algorithm == ALGORITHM_RSASHA256 || dnskey.Algorithm == ALGORITHM_RSASHA512 {
+		publicKey, err := parseRSAKey(dnskey.PublicKey)
+		if err != nil {
+			return err
+		}
+
+		hash, digest := crypto.SHA256, make([]byte, 0)
+		if dnskey.Algorithm == ALGORITHM_RSASHA256 {
+			sum := sha256.Sum256(data)
+			digest = sum[:]
+		} else {
+			sum := sha512.Sum512(data)
+			hash, digest = crypto.SHA512, sum[:]
+		}
+
+		err = rsa.VerifyPKCS1v15(publicKey, hash, digest, signature)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidSignature, err.Error())
+		}
+		return nil
+	} else if dnskey.Algorithm == ALGORITHM_ECDSAP256SHA256 || dnskey.Algorithm == ALGORITHM_ECDSAP384SHA384 {
+		curve, digest := elliptic.P256(), make([]byte, 0)
+		if dnskey.Algorithm == ALGORITHM_ECDSAP256SHA256 {
+			sum := sha256.Sum256(data)
+			digest = sum[:]
+		} else {
+			sum := sha512.Sum384(data)
+			curve, digest = elliptic.P384(), sum[:]
+		}
+
+		size := curve.Params().BitSize / 8
+		if len(dnskey.PublicKey) != 2 * size || len(signature) != 2 * size {
+			return fmt.Errorf("%w: ECDSA key or signature has the wrong length", ErrInvalidSignature)
+		}
+
+		publicKey := ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(dnskey.PublicKey[:size]), Y: new(big.Int).SetBytes(dnskey.PublicKey[size:])}
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(&publicKey, digest, r, s) {
+			return ErrInvalidSignature
+		}
+		return nil
+	} else if dnskey.Algorithm == ALGORITHM_ED25519 {
+		if len(dnskey.PublicKey) != ed25519.PublicKeySize || !ed25519.Verify(ed25519.PublicKey(dnskey.PublicKey), data, signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: algorithm %d", ErrUnsupportedAlgorithm, dnskey.Algorithm)
+}
+
+//Parses an RSA public key from the format of RFC 3110 - Section 2: the length of the exponent in one octet, or in three octets
+//starting with a zero octet, followed by the exponent and the modulus.
+func parseRSAKey(key []byte) (*rsa.PublicKey, error) {
+	if len(key) < 3 {
+		return nil, fmt.Errorf("%w: RSA key is too short", ErrInvalidSignature)
+	}
+
+	exponentLength, offset := int(key[0]), 1
+	if exponentLength == 0 {
+		exponentLength, offset = int(UnpackUInt16(key[1:3])), 3
+	}
+	if len(key) <= offset + exponentLength || exponentLength > 4 {
+		return nil, fmt.Errorf("%w: RSA key is malformed", ErrInvalidSignature)
+	}
+
+	exponent := new(big.Int).SetBytes(key[offset: offset + exponentLength])
+	modulus := new(big.Int).SetBytes(key[offset + exponentLength:])
+	return &rsa.PublicKey{N: modulus, E: int(exponent.Int64())}, nil
+}
+
+//Returns true if the resolver can verify signatures made with the given algorithm.
+func isSupportedAlgorithm(algorithm uint8) bool {
+	return algorithm == ALGORITHM_RSASHA256 || algorithm == ALGORITHM_RSASHA512 || algorithm == ALGORITHM_ECDSAP256SHA256 ||
+		algorithm == ALGORITHM_ECDSAP384SHA384 || algorithm == ALGORITHM_ED25519
+}
+
+//Returns true if the resolver can compute DS digests of the given type.
+func isSupportedDigest(digestType uint8) bool {
+	return digestType == DIGEST_SHA1 || digestType == DIGEST_SHA256 || digestType == DIGEST_SHA384
+}
@@ -0,0 +1,263 @@
+package dns
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//Replaces the A records of www.example.com. with another address, leaving their signatures as they were.
+func forgeAddress(t *testing.T, zone *simulatedZone) {
+	for index, rr := range zone.records {
+		if rr.Type == TYPE_A && rr.Name.Value == "www.example.com." {
+			zone.records[index] = *NewResourceRecord(rr.Name.Value, rr.TTL, CLASS_IN.String(), TYPE_A.String(), "203.0.113.99")
+		}
+	}
+}
+
+//Removes the RRSIG records covering the A records of www.example.com.
+func stripSignatures(t *testing.T, zone *simulatedZone) {
+	records := make([]Resource, 0, len(zone.records))
+	for _, rr := range zone.records {
+		if rr.Type != TYPE_RRSIG || rr.Name.Value != "www.example.com." || rr.Rdata.(*RRSIGResource).TypeCovered != TYPE_A {
+			records = append(records, rr)
+		}
+	}
+	zone.records = records
+}
+
+//Replaces the signatures of the A records of www.example.com. with signatures made with a key of its own, as if www.example.com. were
+//a zone, and serves the DNSKEY record of that key, signed with it, from www.example.com.
+func signWithOwnKey(t *testing.T, zone *simulatedZone) {
+	stripSignatures(t, zone)
+	key, err := GenerateZoneKey("www.example.com.", ALGORITHM_ECDSAP256SHA256, true, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := []Resource{*NewResourceRecord("www.example.com.", 300, CLASS_IN.String(), TYPE_SOA.String(), "ns1.example.com. hostmaster.example.com. 1 3600 900 604800 300")}
+	records = append(records, zone.find("www.example.com.", TYPE_A)...)
+	signer := ZoneSigner{Zone: "www.example.com.", Keys: []*ZoneKey{key}, Inception: time.Now().Add(-time.Hour), Expiration: time.Now().Add(time.Hour)}
+	signed, err := signer.Sign(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rr := range signed {
+		if rr.Type == TYPE_DNSKEY || (rr.Type == TYPE_RRSIG && (rr.Rdata.(*RRSIGResource).TypeCovered == TYPE_A || rr.Rdata.(*RRSIGResource).TypeCovered == TYPE_DNSKEY)) {
+			zone.records = append(zone.records, rr)
+		}
+	}
+}
+
+//Replaces the A records of real.wild.example.com. and their signatures with those of *.wild.example.com., as if the signed wildcard
+//RRset were replayed as the answer for a name that exists with other data.
+func replayWildcard(t *testing.T, zone *simulatedZone) {
+	records := make([]Resource, 0, len(zone.records))
+	for _, rr := range zone.records {
+		if rr.Name.Value == "real.wild.example.com." && (rr.Type == TYPE_A || rr.Type == TYPE_RRSIG && rr.Rdata.(*RRSIGResource).TypeCovered == TYPE_A) {
+			continue
+		}
+		records = append(records, rr)
+	}
+	for _, rr := range append(zone.find("*.wild.example.com.", TYPE_A), zone.signatures("*.wild.example.com.", TYPE_A)...) {
+		rr.Name = DomainName{}
+		rr.Name.Initialize("real.wild.example.com.")
+		records = append(records, rr)
+	}
+	zone.records = records
+}
+
+func TestResolverValidatesSignedZones(t *testing.T) {
+	testCases := []struct {
+		name string
+		qname string
+		qtype RecordType
+		//Names queried for records of the same type before the name being tested, whose answers are cached.
+		primers []string
+		//Records placed in the cache without their signatures before the name is queried, as "name ttl class type data".
+		cached []string
+		//Forgets the delegations followed while querying the primers, as a resolver started afresh on the same cache would.
+		restarted bool
+		//Alters the signed example.com. zone before it is queried.
+		tamper func(*testing.T, *simulatedZone)
+		//Signs example.com. with signatures that have already expired.
+		expired bool
+		//Signs example.com. with an NSEC3 chain of the given parameters instead of an NSEC chain.
+		NSEC3 *NSEC3Resource
+		//Expects the name to be answered from the denials cached while querying the primers, without asking the name servers again.
+		synthesized bool
+		rcode ResponseCode
+		authenticated bool
+		answers []string
+	}{
+		{
+			name: "signed answer",
+			qname: "www.example.com.", qtype: TYPE_A, rcode: RC_NOERROR, authenticated: true,
+			answers: []string{"www.example.com. A 203.0.113.10"},
+		},
+		{
+			name: "signed CNAME chain",
+			qname: "alias.example.com.", qtype: TYPE_A, rcode: RC_NOERROR, authenticated: true,
+			answers: []string{"alias.example.com. CNAME www.example.com.", "www.example.com. A 203.0.113.10"},
+		},
+		{
+			name: "name denied by NSEC records",
+			qname: "missing.example.com.", qtype: TYPE_A, rcode: RC_NXDOMAIN, authenticated: true,
+		},
+		{
+			name: "type denied by an NSEC record",
+			qname: "ipv4.example.com.", qtype: TYPE_TXT, rcode: RC_NOERROR, authenticated: true,
+		},
+		{
+			name: "name denied by a cached NSEC record",
+			qname: "nonexistent.example.com.", qtype: TYPE_A, primers: []string{"missing.example.com."}, synthesized: true, rcode: RC_NXDOMAIN,
+			authenticated: true,
+		},
+		{
+			name: "name denied by the NSEC3 closest encloser proof",
+			qname: "missing.example.com.", qtype: TYPE_A, NSEC3: &NSEC3Resource{HashAlgorithm: NSEC3_HASH_SHA1, Salt: []byte{0xAB, 0xCD}},
+			rcode: RC_NXDOMAIN, authenticated: true,
+		},
+		{
+			name: "name below an existing name denied by NSEC3 records",
+			qname: "sub.www.example.com.", qtype: TYPE_A, NSEC3: &NSEC3Resource{HashAlgorithm: NSEC3_HASH_SHA1, Salt: []byte{}},
+			rcode: RC_NXDOMAIN, authenticated: true,
+		},
+		{
+			name: "type denied by an NSEC3 record",
+			qname: "ipv4.example.com.", qtype: TYPE_TXT, NSEC3: &NSEC3Resource{HashAlgorithm: NSEC3_HASH_SHA1, Salt: []byte{}},
+			rcode: RC_NOERROR, authenticated: true,
+		},
+		{
+			name: "name denied by an opt-out NSEC3 record",
+			qname: "missing.example.com.", qtype: TYPE_A, NSEC3: &NSEC3Resource{HashAlgorithm: NSEC3_HASH_SHA1, Flags: NSEC3_OPT_OUT_FLAG, Salt: []byte{}},
+			rcode: RC_NXDOMAIN, authenticated: false,
+		},
+		{
+			name: "type denied by an NSEC3 record of an opt-out chain",
+			qname: "ipv4.example.com.", qtype: TYPE_TXT, NSEC3: &NSEC3Resource{HashAlgorithm: NSEC3_HASH_SHA1, Flags: NSEC3_OPT_OUT_FLAG, Salt: []byte{}},
+			rcode: RC_NOERROR, authenticated: true,
+		},
+		{
+			name: "unsigned delegation left out of an opt-out NSEC3 chain",
+			qname: "host.dept.example.com.", qtype: TYPE_A, NSEC3: &NSEC3Resource{HashAlgorithm: NSEC3_HASH_SHA1, Flags: NSEC3_OPT_OUT_FLAG, Salt: []byte{}},
+			rcode: RC_NOERROR, authenticated: false, answers: []string{"host.dept.example.com. A 203.0.113.30"},
+		},
+		{
+			name: "answer expanded from a wildcard",
+			qname: "host.wild.example.com.", qtype: TYPE_A, rcode: RC_NOERROR, authenticated: true,
+			answers: []string{"host.wild.example.com. A 203.0.113.40"},
+		},
+		{
+			name: "answer expanded from a wildcard served from the cache",
+			qname: "host.wild.example.com.", qtype: TYPE_A, primers: []string{"host.wild.example.com."}, rcode: RC_NOERROR, authenticated: true,
+			answers: []string{"host.wild.example.com. A 203.0.113.40"},
+		},
+		{
+			name: "unsigned zone below an insecure delegation",
+			qname: "host.dept.example.com.", qtype: TYPE_A, rcode: RC_NOERROR, authenticated: false,
+			answers: []string{"host.dept.example.com. A 203.0.113.30"},
+		},
+		{
+			name: "unsigned zone answer served from the cache",
+			qname: "host.dept.example.com.", qtype: TYPE_A, primers: []string{"host.dept.example.com."}, rcode: RC_NOERROR, authenticated: false,
+			answers: []string{"host.dept.example.com. A 203.0.113.30"},
+		},
+		{
+			name: "unsigned zone answer cached by an earlier run",
+			qname: "host.dept.example.com.", qtype: TYPE_A, primers: []string{"host.dept.example.com."}, restarted: true, rcode: RC_NOERROR, authenticated: false,
+			answers: []string{"host.dept.example.com. A 203.0.113.30"},
+		},
+		{
+			name: "records cached without signatures in a signed zone",
+			qname: "www.example.com.", qtype: TYPE_A, cached: []string{"www.example.com. 300 IN A 203.0.113.99"}, rcode: RC_NOERROR, authenticated: true,
+			answers: []string{"www.example.com. A 203.0.113.10"},
+		},
+		{
+			name: "answer that does not match its signature",
+			qname: "www.example.com.", qtype: TYPE_A, tamper: forgeAddress, rcode: RC_SERVFAIL,
+		},
+		{
+			name: "expired signatures",
+			qname: "www.example.com.", qtype: TYPE_A, expired: true, rcode: RC_SERVFAIL,
+		},
+		{
+			name: "signatures stripped from a signed zone",
+			qname: "www.example.com.", qtype: TYPE_A, tamper: stripSignatures, rcode: RC_SERVFAIL,
+		},
+		{
+			name: "wildcard answer replayed for a name that exists",
+			qname: "real.wild.example.com.", qtype: TYPE_A, tamper: replayWildcard, rcode: RC_SERVFAIL,
+		},
+		{
+			name: "signer that is not a zone",
+			qname: "www.example.com.", qtype: TYPE_A, tamper: signWithOwnKey, rcode: RC_SERVFAIL,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			hierarchy := newSimulatedHierarchy(t, hierarchyFixtures()...)
+			expiration := time.Now().Add(24 * time.Hour)
+			if testCase.expired {
+				expiration = time.Now().Add(-time.Hour)
+			}
+			exampleKey := hierarchy.SignZoneWith(t, "example.com.", ALGORITHM_ECDSAP256SHA256, testCase.NSEC3, expiration)
+			comKey := hierarchy.SignZone(t, "com.", time.Now().Add(24 * time.Hour), exampleKey)
+			rootKey := hierarchy.SignZone(t, ".", time.Now().Add(24 * time.Hour), comKey)
+			if testCase.tamper != nil {
+				testCase.tamper(t, hierarchy.zone("example.com."))
+			}
+
+			resolver := hierarchy.newResolver(t)
+			resolver.SetDNSSECValidation(true)
+			err := resolver.SetTrustAnchors([]Resource{rootKey.DS()})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, primer := range testCase.primers {
+				resolver.Query(primer, testCase.qtype)
+			}
+			if testCase.restarted {
+				resolver.Delegations = NewMemoryStore()
+				resolver.zoneKeyCache = &sync.Map{}
+			}
+			for _, record := range testCase.cached {
+				fields := strings.SplitN(record, WHITESPACE, 5)
+				ttl, _ := strconv.ParseUint(fields[1], 10, 32)
+				resolver.Cache.Put([]Resource{*NewResourceRecord(fields[0], uint32(ttl), fields[2], fields[3], fields[4])})
+			}
+			response := resolver.Query(testCase.qname, testCase.qtype)
+			if response.Header.Rcode != testCase.rcode {
+				t.Fatalf("response code %s, expected %s", response.Header.Rcode.String(), testCase.rcode.String())
+			}
+			if response.Header.Authenticated != testCase.authenticated {
+				t.Errorf("AD bit %t, expected %t", response.Header.Authenticated, testCase.authenticated)
+			}
+			if queried := hierarchy.Received(testCase.qname + WHITESPACE + testCase.qtype.String()); queried == testCase.synthesized {
+				t.Errorf("name servers asked for %s %s: %t, expected %t", testCase.qname, testCase.qtype.String(), queried, !testCase.synthesized)
+			}
+			if testCase.rcode == RC_SERVFAIL {
+				return
+			}
+
+			answers := make([]string, 0)
+			for _, answer := range response.Answers {
+				if answer.Type != TYPE_RRSIG {
+					answers = append(answers, answer.Name.Value + WHITESPACE + answer.Type.String() + WHITESPACE + answer.GetData())
+				}
+			}
+			if len(answers) != len(testCase.answers) {
+				t.Fatalf("answers %v, expected %v", answers, testCase.answers)
+			}
+			for index := range answers {
+				if answers[index] != testCase.answers[index] {
+					t.Errorf("answers %v, expected %v", answers, testCase.answers)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"fmt"
 	"strings"
 )
 
@@ -10,6 +11,8 @@ type DomainName struct {
 	Data []byte
 	//Contains the string value representation of the domain name
 	Value string
+	//Contains the domain name with its letter case preserved as it is sent or received on the wire.
+	RawValue string
 	//Contains the number of labels (domains & subdomains) in the domain name.
 	Length uint8
 }
@@ -21,12 +24,20 @@ func (name *DomainName) Initialize(dName string) {
 	name.Length = uint8(len(strings.Split(dName, DOMAIN_LABEL_SEPERATOR)))
 	dName = Canonicalize(dName)
 	name.Value = dName
+	name.RawValue = dName
 }
 
 //Parse the given domain name string and pack it as sequence of octets.
 func (name *DomainName) Pack(compressionMap CompressionMap, offset int) []byte {
 	encodedBytes := make([]byte, 0)
-	dName := name.Value
+	dName := name.RawValue
+	if dName == "" {
+		dName = name.Value
+	}
+	if dName == DOMAIN_LABEL_SEPERATOR {
+		//The root domain name is a single zero byte.
+		dName = ""
+	}
 	isPtrAvailable := false
 
 	for {
@@ -55,14 +66,17 @@ func (name *DomainName) Pack(compressionMap CompressionMap, offset int) []byte {
 		compressionMap[dName] = offset
 	}
 
-	name.Data = encodedBytes
+	//The packed bytes are not kept in the name, as names shared with the cache may be packed by several messages at once.
 	return encodedBytes
 }
 
 //Gets the byte length of the given domain name.
 func (name *DomainName) GetLength() int {
 	length := 0
-	dName := name.Value
+	dName := strings.Trim(name.Value, DOMAIN_LABEL_SEPERATOR)
+	if dName == "" {
+		return 1
+	}
 	for _, dLabel := range strings.Split(dName, DOMAIN_LABEL_SEPERATOR) {
 		length +=1 //for the byte representing the length of the label or subdomain
 		length += len([]byte(dLabel))
@@ -71,45 +85,71 @@ func (name *DomainName) GetLength() int {
 	return length
 }
 
-//Unpack the given byte stream and extract the domain name.
-func (name *DomainName) Unpack(buffer []byte, offset int) int {
-	completeDomainName, offset := name.getDomainName(buffer, offset)
-	name.Value = completeDomainName
+//Unpack the given byte stream and extract the domain name. Returns ErrMalformedMessage if the domain name is not encoded correctly.
+func (name *DomainName) Unpack(buffer []byte, offset int) (int, error) {
+	completeDomainName, offset, err := name.getDomainName(buffer, offset)
+	if err != nil {
+		return offset, err
+	}
+	name.RawValue = completeDomainName
+	name.Value = Canonicalize(completeDomainName)
 	name.Length = uint8(len(strings.Split(name.Value, DOMAIN_LABEL_SEPERATOR)))
-	return offset
+	return offset, nil
 }
 
-//Parses the given byte stream and fetches the domain name. Domain name can be represented directly
-//or can be compressed and represented through a pointer as per RFC 1035 - Section 4.1.4
-func (name *DomainName) getDomainName(buffer []byte, offset int) (string, int) {
-	completeDomainName := ""
-	LastIndexRead := offset
-	labelByteCount := buffer[LastIndexRead]
-	for iterate := true; iterate; {
-		PtrBytesCheck := buffer[LastIndexRead: LastIndexRead + 2]
-		PtrBytesValue := UnpackUInt16(PtrBytesCheck)
-		if PtrBytesValue & PTR_DETECT_VALUE == PTR_DETECT_VALUE {
-			ptr_offset_value := PtrBytesValue & PTR_OFFSET_FETCH
-			subdomain, _ := name.getDomainName(buffer, int(ptr_offset_value))
-			completeDomainName = completeDomainName + DOMAIN_LABEL_SEPERATOR + subdomain
-			LastIndexRead = LastIndexRead + 2
-			iterate = false
-		} else if int(labelByteCount) != 0 {
-			labelBytes := buffer[LastIndexRead + 1: LastIndexRead + int(labelByteCount) + 1]
-			name.Data = append(name.Data, labelByteCount)
+//Parses the given byte stream and fetches the domain name with its letter case preserved, along with the offset following it. Domain name
+//can be represented directly or can be compressed and represented through a pointer as per RFC 1035 - Section 4.1.4. A pointer is only
+//followed if it points before the labels it ends, so that no pointer can lead back to itself, and at most MAX_COMPRESSION_POINTERS are
+//followed. Domain names longer than MAX_DOMAIN_NAME_LENGTH octets are rejected.
+func (name *DomainName) getDomainName(buffer []byte, offset int) (string, int, error) {
+	labels := make([]string, 0)
+	nameLength, pointers, endOffset, labelsOffset := 0, 0, -1, offset
+	for {
+		if offset >= len(buffer) {
+			return "", offset, fmt.Errorf("%w: domain name runs past the end of the message", ErrMalformedMessage)
+		}
+
+		labelByteCount := int(buffer[offset])
+		if uint16(labelByteCount) << 8 & PTR_DETECT_VALUE == PTR_DETECT_VALUE {
+			if offset + 2 > len(buffer) {
+				return "", offset, fmt.Errorf("%w: compression pointer runs past the end of the message", ErrMalformedMessage)
+			}
+			pointer := int(UnpackUInt16(buffer[offset: offset + 2]) & PTR_OFFSET_FETCH)
+			pointers++
+			if pointer >= labelsOffset {
+				return "", offset, fmt.Errorf("%w: compression pointer at offset %d does not point backwards", ErrMalformedMessage, offset)
+			} else if pointers > MAX_COMPRESSION_POINTERS {
+				return "", offset, fmt.Errorf("%w: domain name follows more than %d compression pointers", ErrMalformedMessage, MAX_COMPRESSION_POINTERS)
+			}
+			if endOffset < 0 {
+				endOffset = offset + 2
+			}
+			offset, labelsOffset = pointer, pointer
+		} else if uint16(labelByteCount) << 8 & PTR_DETECT_VALUE != 0 {
+			return "", offset, fmt.Errorf("%w: unsupported label type at offset %d", ErrMalformedMessage, offset)
+		} else if labelByteCount != 0 {
+			//Each label takes its length octet and its characters, and the name ends with the zero octet of the root.
+			nameLength += labelByteCount + 1
+			if nameLength + 1 > MAX_DOMAIN_NAME_LENGTH {
+				return "", offset, fmt.Errorf("%w: domain name is longer than %d octets", ErrMalformedMessage, MAX_DOMAIN_NAME_LENGTH)
+			} else if offset + labelByteCount + 1 > len(buffer) {
+				return "", offset, fmt.Errorf("%w: label runs past the end of the message", ErrMalformedMessage)
+			}
+			labelBytes := buffer[offset + 1: offset + labelByteCount + 1]
+			name.Data = append(name.Data, byte(labelByteCount))
 			name.Data = append(name.Data, labelBytes...)
-			completeDomainName = completeDomainName + DOMAIN_LABEL_SEPERATOR + string(labelBytes)
-			LastIndexRead = LastIndexRead + int(labelByteCount) + 1
-			labelByteCount = buffer[LastIndexRead]
+			labels = append(labels, string(labelBytes))
+			offset = offset + labelByteCount + 1
 		} else {
 			name.Data = append(name.Data, byte(0))
-			LastIndexRead = LastIndexRead + 1
-			iterate = false
+			if endOffset < 0 {
+				endOffset = offset + 1
+			}
+			break
 		}
 	}
 
-	completeDomainName = Canonicalize(completeDomainName)
-	return completeDomainName, LastIndexRead
+	return strings.Join(labels, DOMAIN_LABEL_SEPERATOR) + DOMAIN_LABEL_SEPERATOR, endOffset, nil
 }
 
 //Returns the domain name as a string.
@@ -0,0 +1,95 @@
+package dns
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestDomainNameUnpack(t *testing.T) {
+	header := make([]byte, MESSAGE_HEADER_LENGTH)
+	//Name made of 63 octet labels, which is 256 octets long once its fourth label is added.
+	longName := make([]byte, 0)
+	for index := 0; index < 4; index++ {
+		longName = append(longName, 63)
+		longName = append(longName, bytes.Repeat([]byte{'a'}, 63)...)
+	}
+
+	testCases := []struct {
+		name string
+		buffer []byte
+		offset int
+		value string
+		endOffset int
+	}{
+		{
+			name: "uncompressed name",
+			buffer: append(append([]byte{}, header...), 3, 'w', 'w', 'w', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0),
+			offset: 12, value: "www.example.com.", endOffset: 29,
+		},
+		{
+			name: "root name",
+			buffer: append(append([]byte{}, header...), 0),
+			offset: 12, value: ".", endOffset: 13,
+		},
+		{
+			name: "name compressed with a pointer to an earlier name",
+			buffer: append(append([]byte{}, header...), 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 3, 'c', 'o', 'm', 0, 3, 'w', 'w', 'w', 0xC0, 12),
+			offset: 25, value: "www.example.com.", endOffset: 31,
+		},
+		{
+			name: "name compressed with a chain of pointers",
+			buffer: append(append([]byte{}, header...), 3, 'c', 'o', 'm', 0, 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0xC0, 12, 3, 'w', 'w', 'w', 0xC0, 17),
+			offset: 27, value: "www.example.com.", endOffset: 33,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			name := DomainName{}
+			offset, err := name.Unpack(testCase.buffer, testCase.offset)
+			if err != nil {
+				t.Fatalf("unpacking failed: %s", err.Error())
+			}
+			if name.RawValue != testCase.value || offset != testCase.endOffset {
+				t.Errorf("unpacked %q ending at offset %d, expected %q ending at offset %d", name.RawValue, offset, testCase.value, testCase.endOffset)
+			}
+		})
+	}
+
+	malformedCases := []struct {
+		name string
+		buffer []byte
+		offset int
+	}{
+		{name: "pointer to itself", buffer: append(append([]byte{}, header...), 0xC0, 12), offset: 12},
+		{name: "pointer to a later offset", buffer: append(append([]byte{}, header...), 0xC0, 14, 0), offset: 12},
+		{name: "pointers pointing at each other", buffer: append(append([]byte{}, header...), 0xC0, 14, 0xC0, 12), offset: 14},
+		{name: "label pointing back to its own start", buffer: append(append([]byte{}, header...), 1, 'a', 0xC0, 12), offset: 12},
+		{name: "pointer past the end of the message", buffer: append(append([]byte{}, header...), 0xC0), offset: 12},
+		{name: "label past the end of the message", buffer: append(append([]byte{}, header...), 5, 'a', 'b'), offset: 12},
+		{name: "name without its terminating octet", buffer: append(append([]byte{}, header...), 1, 'a'), offset: 12},
+		{name: "reserved label type", buffer: append(append([]byte{}, header...), 0x41, 0), offset: 12},
+		{name: "name longer than 255 octets", buffer: append(append(append([]byte{}, header...), longName...), 0), offset: 12},
+	}
+
+	for _, testCase := range malformedCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			name := DomainName{}
+			_, err := name.Unpack(testCase.buffer, testCase.offset)
+			if !errors.Is(err, ErrMalformedMessage) {
+				t.Errorf("unpacking returned %v, expected %v", err, ErrMalformedMessage)
+			}
+		})
+	}
+}
+
+func TestMessageUnpackRejectsPointerLoops(t *testing.T) {
+	//Header of a query with one question, whose name is a pointer to itself.
+	buffer := []byte{0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x0C, 0x00, 0x01, 0x00, 0x01}
+	message := NewMessage(MSG_REQUEST, 0)
+	err := message.Unpack(buffer)
+	if !errors.Is(err, ErrMalformedMessage) {
+		t.Errorf("unpacking returned %v, expected %v", err, ErrMalformedMessage)
+	}
+}
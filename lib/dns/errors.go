@@ -11,4 +11,38 @@ var ErrMessageTooLong error = errors.New("udp message size is too long")
 var ErrNotAbsolutePath error = errors.New("file path must be an absolute")
 var ErrParametersMissing error = errors.New("parameters are missing")
 var ErrBitCount error = errors.New("bit count for the given number is larger than the required bit count")
-var ErrInvalidClassType = errors.New("class type not available")
\ No newline at end of file
+var ErrInvalidClassType = errors.New("class type not available")
+var ErrInvalidSnapshot = errors.New("cache snapshot file is corrupt or has an unsupported version")
+var ErrUnexpectedSource = errors.New("datagram received from an unexpected address or port")
+var ErrNoResponse = errors.New("no valid response received from the name server")
+var ErrCaseMismatch = errors.New("response did not preserve the letter case of the question")
+var ErrInvalidTransport = errors.New("transport preference must be one of ipv4, ipv6 or both")
+var ErrReferralLimit = errors.New("referral limit exceeded")
+var ErrCNAMEChainLimit = errors.New("CNAME chain limit exceeded")
+var ErrQueryLimit = errors.New("upstream query limit exceeded")
+var ErrResolutionTimeout = errors.New("resolution time limit exceeded")
+var ErrLookupDepthLimit = errors.New("name server lookup depth limit exceeded")
+var ErrNXDomain = errors.New("domain name does not exist")
+var ErrNoData = errors.New("domain name has no records of the requested type")
+var ErrLameDelegation = errors.New("name server is not authoritative for the zone delegated to it")
+var ErrServerFailure = errors.New("name server failed to answer the query")
+var ErrNameTooLong = errors.New("domain name synthesized from the DNAME record is too long")
+var ErrBogus = errors.New("response failed DNSSEC validation")
+var ErrUnsupportedAlgorithm = errors.New("DNSSEC algorithm or digest type is not supported")
+var ErrInvalidSignature = errors.New("DNSSEC signature does not verify")
+var ErrKeyMismatch = errors.New("DNSSEC signature was not made with the given key")
+var ErrInvalidTrustAnchor = errors.New("trust anchors must be DS or DNSKEY records")
+var ErrInvalidAnchorState = errors.New("trust anchor state must be one of ADDPEND, VALID, MISSING or REVOKED")
+var ErrZoneSyntax = errors.New("zone file is malformed")
+var ErrNoSigningKeys = errors.New("no keys are available to sign the zone")
+var ErrOutOfZone = errors.New("record is outside of the zone being signed")
+var ErrNoSOA = errors.New("zone has no SOA record at its apex")
+var ErrInvalidPrivateKey = errors.New("private key file is malformed or does not match its DNSKEY record")
+var ErrInvalidUpstream = errors.New("upstream must be given as address[:port][#server-name][?pin=base64-spki-sha256] or https://host[:port]/path[{?dns}][#bootstrap-ip[;bootstrap-ip...]]")
+var ErrPinMismatch = errors.New("certificate of the upstream does not hold a pinned key")
+var ErrConnectionClosed = errors.New("connection to the upstream is closed")
+var ErrServerClosed = errors.New("server has been closed")
+var ErrIdMismatch = errors.New("response does not carry the message ID of the request")
+var ErrQuestionMismatch = errors.New("response does not carry the question of the request")
+var ErrMalformedMessage = errors.New("message could not be parsed")
+var ErrNoServer = errors.New("no server is listening at the address")
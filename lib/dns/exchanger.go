@@ -0,0 +1,188 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+//Interface to exchange DNS messages with name servers over a transport. Implementations must only return a response that answers the
+//request, carrying the same message ID and question, and must give up once the context is done.
+type Exchanger interface {
+	//Sends the request to the server at the given address, given as "ip:port", and returns the response it sends back.
+	Exchange(ctx context.Context, request *Message, address string) (*Message, error)
+}
+
+//Returns the exchanger used by default, which sends queries over UDP and repeats them over TCP when the response is truncated. Discarded
+//datagrams are counted in the given counters, if any.
+func DefaultExchanger(discarded *DiscardCounters) Exchanger {
+	return &FallbackExchanger{Datagram: &UdpExchanger{Discarded: discarded}, Stream: &TcpExchanger{Discarded: discarded}}
+}
+
+//Exchanges DNS messages over UDP (RFC 1035 - Section 4.2.1), from a fresh random source port for every query. Datagrams that do not
+//come from the queried address and port, or do not answer the request, are discarded and the exchanger waits for the next datagram.
+type UdpExchanger struct {
+	//Counters of the datagrams discarded, or nil if they are not counted.
+	Discarded *DiscardCounters
+}
+
+//Sends the request to the server over UDP and waits for its response until the context is done.
+func (ue *UdpExchanger) Exchange(ctx context.Context, request *Message, address string) (*Message, error) {
+	host, port, err := splitServerAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	udpConnect := UdpConnect{}
+	err = udpConnect.ConnectTo(host, port)
+	if err != nil {
+		return nil, err
+	}
+	defer udpConnect.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		udpConnect.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { udpConnect.SetDeadline(time.Now()) })
+	defer stop()
+
+	err = udpConnect.Send(request.Pack())
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		buffer, err := udpConnect.Receive()
+		if err == ErrUnexpectedSource {
+			ue.Discarded.count(err)
+			continue
+		} else if err != nil {
+			if ctx.Err() != nil || errors.Is(err, os.ErrDeadlineExceeded) {
+				return nil, fmt.Errorf("%w: %s did not respond in time", ErrNoResponse, address)
+			}
+			return nil, err
+		}
+
+		response, err := matchResponse(request, buffer)
+		if err != nil {
+			ue.Discarded.count(err)
+			continue
+		}
+		return response, nil
+	}
+}
+
+//Exchanges DNS messages over TCP, prefixing each message with its length (RFC 1035 - Section 4.2.2). A new connection is opened for
+//every query and closed once its response has been received.
+type TcpExchanger struct {
+	//Counters of the responses discarded, or nil if they are not counted.
+	Discarded *DiscardCounters
+}
+
+//Sends the request to the server over TCP and waits for its response until the context is done.
+func (te *TcpExchanger) Exchange(ctx context.Context, request *Message, address string) (*Message, error) {
+	dialer := net.Dialer{}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	buffer := request.Pack()
+	_, err = conn.Write(append(PackUInt16(uint16(len(buffer))), buffer...))
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 2)
+	_, err = io.ReadFull(conn, header)
+	if err == nil {
+		buffer = make([]byte, UnpackUInt16(header))
+		_, err = io.ReadFull(conn, buffer)
+	}
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, os.ErrDeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %s did not respond in time", ErrNoResponse, address)
+		}
+		return nil, err
+	}
+
+	response, err := matchResponse(request, buffer)
+	if err != nil {
+		te.Discarded.count(err)
+		return nil, err
+	}
+	return response, nil
+}
+
+//Sends queries over a datagram transport and repeats them over a stream transport when the response is truncated (RFC 7766 - Section 5).
+type FallbackExchanger struct {
+	//Exchanger the queries are sent over first.
+	Datagram Exchanger
+	//Exchanger the queries whose response was truncated are sent over again.
+	Stream Exchanger
+}
+
+//Sends the request over the datagram exchanger, and over the stream exchanger if the response sent back is truncated.
+func (fe *FallbackExchanger) Exchange(ctx context.Context, request *Message, address string) (*Message, error) {
+	response, err := fe.Datagram.Exchange(ctx, request, address)
+	if err != nil || !response.Header.Truncation {
+		return response, err
+	}
+	return fe.Stream.Exchange(ctx, request, address)
+}
+
+//Parses the received byte stream and checks that it answers the given request. Returns ErrMalformedMessage, ErrIdMismatch or
+//ErrQuestionMismatch if it does not.
+func matchResponse(request *Message, buffer []byte) (*Message, error) {
+	response := NewMessage(MSG_RESPONSE, 0)
+	err := response.Unpack(buffer)
+	if err != nil {
+		return nil, err
+	}
+	if !response.Header.IsResponse || response.Header.Identifier != request.Header.Identifier {
+		return nil, fmt.Errorf("%w: received %d while expecting %d", ErrIdMismatch, response.Header.Identifier, request.Header.Identifier)
+	} else if !response.HasSameQuestions(request) {
+		return nil, ErrQuestionMismatch
+	}
+	return response, nil
+}
+
+//Counts a response discarded with the given error. Does nothing if the counters are nil.
+func (dc *DiscardCounters) count(err error) {
+	if dc == nil {
+		return
+	} else if errors.Is(err, ErrUnexpectedSource) {
+		dc.SourceMismatch.Add(1)
+	} else if errors.Is(err, ErrIdMismatch) {
+		dc.IdMismatch.Add(1)
+	} else if errors.Is(err, ErrQuestionMismatch) {
+		dc.QuestionMismatch.Add(1)
+	} else if errors.Is(err, ErrCaseMismatch) {
+		dc.CaseMismatch.Add(1)
+	} else if errors.Is(err, ErrMalformedMessage) {
+		dc.Malformed.Add(1)
+	}
+}
+
+//Splits a server address given as "ip:port" into the IP address and the port number.
+func splitServerAddress(address string) (string, int, error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return "", 0, err
+	}
+	return host, port, nil
+}
@@ -0,0 +1,133 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+//Returns the packed response to the request, answering it with the given A record.
+func packedAnswer(request *Message, address string) []byte {
+	response := NewMessage(MSG_RESPONSE, request.Header.Identifier)
+	response.Questions = append(response.Questions, request.Questions...)
+	response.Header.SetQuestionCount(uint16(len(response.Questions)))
+	response.AddAnswers([]Resource{*NewResourceRecord(request.Questions[0].Name.Value, 300, "IN", "A", address)})
+	return response.Pack()
+}
+
+//Returns a packed response with the given message ID, whose question name is a compression pointer to itself.
+func pointerLoopResponse(id uint16) []byte {
+	buffer := append(PackUInt16(id), 0x81, 0x80, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	return append(buffer, 0xC0, 0x0C, 0x00, 0x01, 0x00, 0x01)
+}
+
+//Returns the packed response to the request, answering it with a record of the given type that holds the given record data.
+func answerWithRdata(request *Message, recType RecordType, rdata []byte) []byte {
+	buffer := packedAnswer(request, "192.0.2.1")
+	//Replaces the type, class, TTL, length and address of the A record that follow its compressed owner name.
+	buffer = append(buffer[:len(buffer) - 14], PackUInt16(uint16(recType))...)
+	buffer = append(buffer, 0x00, 0x01, 0x00, 0x00, 0x01, 0x2C)
+	buffer = append(buffer, PackUInt16(uint16(len(rdata)))...)
+	return append(buffer, rdata...)
+}
+
+func TestMatchResponse(t *testing.T) {
+	request := newQuery(0x1234, "www.example.com.", TYPE_A)
+	valid := packedAnswer(request, "192.0.2.1")
+
+	//Valid response whose answer points back at its own owner name.
+	answerLoop := append([]byte{}, valid[:MESSAGE_HEADER_LENGTH]...)
+	answerLoop = append(answerLoop, valid[MESSAGE_HEADER_LENGTH: MESSAGE_HEADER_LENGTH + 21]...)
+	answerLoop = append(answerLoop, 0xC0, byte(MESSAGE_HEADER_LENGTH + 21), 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x01, 0x2C, 0x00, 0x04, 192, 0, 2, 1)
+
+	otherRequest := newQuery(0x1234, "www.example.org.", TYPE_A)
+	testCases := []struct {
+		name string
+		buffer []byte
+		err error
+	}{
+		{name: "question name pointing to itself", buffer: pointerLoopResponse(0x1234), err: ErrMalformedMessage},
+		{name: "answer owner name pointing to itself", buffer: answerLoop, err: ErrMalformedMessage},
+		{name: "shorter than a header", buffer: valid[:MESSAGE_HEADER_LENGTH - 1], err: ErrMalformedMessage},
+		{name: "truncated answer", buffer: valid[:len(valid) - 2], err: ErrMalformedMessage},
+		{name: "DS record data shorter than its fields", buffer: answerWithRdata(request, TYPE_DS, []byte{0, 1, 8}), err: ErrMalformedMessage},
+		{name: "DNSKEY record data shorter than its fields", buffer: answerWithRdata(request, TYPE_DNSKEY, []byte{1, 1, 3}), err: ErrMalformedMessage},
+		{name: "RRSIG record data shorter than its fields", buffer: answerWithRdata(request, TYPE_RRSIG, []byte{0, 1, 13, 2, 0, 0}), err: ErrMalformedMessage},
+		{
+			name: "RRSIG signer name running past its record data",
+			buffer: append(answerWithRdata(request, TYPE_RRSIG, append(make([]byte, 18), 3, 'c', 'o')), 'm', 0),
+			err: ErrMalformedMessage,
+		},
+		{name: "NSEC next name running past its record data", buffer: append(answerWithRdata(request, TYPE_NSEC, []byte{3, 'c', 'o'}), 'm', 0), err: ErrMalformedMessage},
+		{name: "NSEC3 record data shorter than its fields", buffer: answerWithRdata(request, TYPE_NSEC3, []byte{1, 0, 0}), err: ErrMalformedMessage},
+		{name: "NSEC3 salt longer than its record data", buffer: answerWithRdata(request, TYPE_NSEC3, []byte{1, 0, 0, 0, 200, 0xAB, 0xCD}), err: ErrMalformedMessage},
+		{name: "NSEC3 hash longer than its record data", buffer: answerWithRdata(request, TYPE_NSEC3, []byte{1, 0, 0, 0, 0, 20, 1, 2, 3}), err: ErrMalformedMessage},
+		{name: "NSEC3PARAM salt longer than its record data", buffer: answerWithRdata(request, TYPE_NSEC3PARAM, []byte{1, 0, 0, 0, 4, 0xAB}), err: ErrMalformedMessage},
+		{name: "CAA record without record data", buffer: answerWithRdata(request, TYPE_CAA, []byte{}), err: ErrMalformedMessage},
+		{name: "CAA tag longer than its record data", buffer: answerWithRdata(request, TYPE_CAA, []byte{0, 75, 'i', 's', 's', 'u', 'e'}), err: ErrMalformedMessage},
+		{name: "SVCB record data shorter than its priority", buffer: answerWithRdata(request, TYPE_SVCB, []byte{0}), err: ErrMalformedMessage},
+		{name: "HTTPS target name running past its record data", buffer: append(answerWithRdata(request, TYPE_HTTPS, []byte{0, 1, 3, 'c', 'o'}), 'm', 0), err: ErrMalformedMessage},
+		{name: "SVCB parameter key cut short", buffer: answerWithRdata(request, TYPE_SVCB, []byte{0, 1, 0, 0, 1}), err: ErrMalformedMessage},
+		{name: "SVCB parameter value longer than its record data", buffer: answerWithRdata(request, TYPE_SVCB, []byte{0, 1, 0, 0, 1, 0, 9, 'h', '2'}), err: ErrMalformedMessage},
+		{name: "SOA record data shorter than its fields", buffer: append(answerWithRdata(request, TYPE_SOA, []byte{0, 0, 0, 0, 0, 1}), make([]byte, 16)...), err: ErrMalformedMessage},
+		{name: "EDNS option code cut short", buffer: answerWithRdata(request, TYPE_OPT, []byte{0, 12}), err: ErrMalformedMessage},
+		{name: "EDNS option longer than its OPT record", buffer: append(answerWithRdata(request, TYPE_OPT, []byte{0, 12, 0, 8, 0, 0}), make([]byte, 6)...), err: ErrMalformedMessage},
+		{name: "other message ID", buffer: packedAnswer(newQuery(0x4321, "www.example.com.", TYPE_A), "192.0.2.1"), err: ErrIdMismatch},
+		{name: "other question", buffer: packedAnswer(otherRequest, "192.0.2.1"), err: ErrQuestionMismatch},
+		{name: "matching response", buffer: valid},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			response, err := matchResponse(request, testCase.buffer)
+			if testCase.err == nil && (err != nil || len(response.Answers) != 1) {
+				t.Errorf("matching failed with %v", err)
+			} else if testCase.err != nil && (!errors.Is(err, testCase.err) || response != nil) {
+				t.Errorf("matching returned %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
+
+func TestUdpExchangerDiscardsPointerLoops(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		buffer := make([]byte, UDP_MESSAGE_SIZE_LIMIT)
+		length, client, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			return
+		}
+		request := NewMessage(MSG_REQUEST, 0)
+		if request.Unpack(buffer[:length]) != nil {
+			return
+		}
+		conn.WriteToUDP(pointerLoopResponse(request.Header.Identifier), client)
+		conn.WriteToUDP(packedAnswer(request, "192.0.2.1"), client)
+	}()
+
+	discarded := &DiscardCounters{}
+	exchanger := &UdpExchanger{Discarded: discarded}
+	request := newQuery(Id(), "www.example.com.", TYPE_A)
+	ctx, cancel := context.WithTimeout(context.Background(), 2 * time.Second)
+	defer cancel()
+	response, err := exchanger.Exchange(ctx, request, conn.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("exchange failed: %s", err.Error())
+	}
+	if len(response.Answers) != 1 || discarded.Malformed.Load() != 1 {
+		t.Errorf("received %d answers with %d malformed datagrams discarded, expected 1 answer and 1 discarded datagram", len(response.Answers), discarded.Malformed.Load())
+	}
+}
+
+//Returns a request with the given message ID and question.
+func newQuery(id uint16, name string, recType RecordType) *Message {
+	request := NewMessage(MSG_REQUEST, id)
+	request.NewQuestion(name, recType)
+	return request
+}
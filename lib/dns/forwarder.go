@@ -0,0 +1,327 @@
+package dns
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//A recursive resolver that queries are forwarded to over DNS-over-TLS (RFC 7858) or DNS-over-HTTPS (RFC 8484).
+type Upstream struct {
+	//Address and port of the upstream. For a DNS-over-HTTPS upstream, the host and port of its URL.
+	Address string
+	//Name the certificate of the upstream is verified against, which is also sent as the TLS server name.
+	ServerName string
+	//SHA-256 digests of the SubjectPublicKeyInfo of the keys the upstream is trusted to use (RFC 7858 - Section 4.2). When set, the
+	//certificate chain must hold one of the keys and certification authorities are not consulted.
+	Pins [][]byte
+	//Certification authorities trusted to issue the certificate of the upstream, or nil for the system roots.
+	RootCAs *x509.CertPool
+	//URL template of a DNS-over-HTTPS upstream, such as "https://dns.example/dns-query{?dns}". Empty for DNS-over-TLS upstreams.
+	Template string
+	//Addresses the host of the URL template is reached at, so that a DNS-over-HTTPS upstream can be used without first resolving its
+	//name. The host is resolved by the system resolver if there are none.
+	Bootstrap []string
+}
+
+//Connection to an upstream that packed messages are exchanged over.
+type upstreamConnection interface {
+	Exchange(buffer []byte, deadline time.Time) ([]byte, error)
+	IsClosed() bool
+	Close() error
+}
+
+//Parses an upstream given as "address[:port][#server-name][?pin=base64-spki-sha256[&pin=...]]" for DNS-over-TLS, or as the URL template
+//"https://host[:port]/path[{?dns}][#bootstrap-ip[;bootstrap-ip...]]" for DNS-over-HTTPS. For DNS-over-TLS the port defaults to 853,
+//and the address itself is used as the server name if none is given.
+func ParseUpstream(value string) (*Upstream, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(strings.ToLower(value), "https://") {
+		return parseHTTPSUpstream(value)
+	}
+
+	value, query, _ := strings.Cut(value, "?")
+	address, serverName, _ := strings.Cut(value, "#")
+	if address == "" {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidUpstream, value)
+	}
+
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		host, port = strings.Trim(address, "[]"), strconv.Itoa(DOT_PORT_NUMBER)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return nil, fmt.Errorf("%w: invalid port in %q", ErrInvalidUpstream, address)
+	}
+
+	upstream := Upstream{Address: net.JoinHostPort(host, port), ServerName: serverName, Pins: make([][]byte, 0)}
+	if upstream.ServerName == "" {
+		upstream.ServerName = host
+	}
+	for _, parameter := range strings.Split(query, "&") {
+		key, pin, _ := strings.Cut(parameter, "=")
+		if key == "" {
+			continue
+		} else if key != "pin" {
+			return nil, fmt.Errorf("%w: unknown parameter %q", ErrInvalidUpstream, key)
+		}
+
+		digest, err := base64.StdEncoding.DecodeString(pin)
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("%w: pin %q is not a base64 SHA-256 digest", ErrInvalidUpstream, pin)
+		}
+		upstream.Pins = append(upstream.Pins, digest)
+	}
+	return &upstream, nil
+}
+
+//Parses a DNS-over-HTTPS upstream given as its URL template, optionally followed by the bootstrap addresses of its host.
+func parseHTTPSUpstream(value string) (*Upstream, error) {
+	template, bootstrap, _ := strings.Cut(value, "#")
+	expanded, err := expandDoHTemplate(template, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	target, _ := url.Parse(expanded)
+	port := target.Port()
+	if port == "" {
+		port = "443"
+	}
+	upstream := Upstream{Address: net.JoinHostPort(target.Hostname(), port), ServerName: target.Hostname(), Pins: make([][]byte, 0), Template: template, Bootstrap: make([]string, 0)}
+	for _, address := range strings.Split(bootstrap, ";") {
+		address = strings.Trim(strings.TrimSpace(address), "[]")
+		if address == "" {
+			continue
+		} else if net.ParseIP(address) == nil {
+			return nil, fmt.Errorf("%w: bootstrap address %q is not an IP address", ErrInvalidUpstream, address)
+		}
+		upstream.Bootstrap = append(upstream.Bootstrap, address)
+	}
+	return &upstream, nil
+}
+
+//Returns true if queries are sent to the upstream over DNS-over-HTTPS.
+func (upstream *Upstream) IsHTTPS() bool {
+	return upstream.Template != ""
+}
+
+//Returns the SPKI pin of the certificate, which is the base64 SHA-256 digest of its SubjectPublicKeyInfo.
+func SPKIPin(certificate *x509.Certificate) string {
+	digest := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(digest[:])
+}
+
+//Returns the string representation of the upstream.
+func (upstream *Upstream) String() string {
+	if upstream.IsHTTPS() {
+		return upstream.Template
+	}
+	return upstream.Address + "#" + upstream.ServerName
+}
+
+//Returns the TLS configuration used to connect to the upstream. Without pins the certificate is verified against the certification
+//authorities for the server name, while with pins the connection is accepted only if the certificate of the upstream holds a pinned
+//key or chains up to a certificate that does.
+func (upstream *Upstream) tlsConfig() *tls.Config {
+	config := tls.Config{ServerName: upstream.ServerName, RootCAs: upstream.RootCAs, MinVersion: tls.VersionTLS12}
+	if len(upstream.Pins) > 0 {
+		//The pins take the place of the certificate chain verification, which is done by VerifyConnection instead.
+		config.InsecureSkipVerify = true
+		config.VerifyConnection = upstream.verifyPins
+	}
+	return &config
+}
+
+//Checks that the certificate presented by the upstream holds a pinned key, or is issued for the server name by a chain of certificates
+//leading up to one that holds a pinned key. Only the certificate of the upstream is bound to the connection, so a pinned certificate
+//elsewhere in the chain is trusted only once the chain from the certificate of the upstream up to it has been verified.
+func (upstream *Upstream) verifyPins(state tls.ConnectionState) error {
+	for index, certificate := range state.PeerCertificates {
+		if !upstream.isPinned(certificate) {
+			continue
+		} else if index == 0 {
+			return nil
+		}
+
+		roots, intermediates := x509.NewCertPool(), x509.NewCertPool()
+		roots.AddCert(certificate)
+		for _, intermediate := range state.PeerCertificates[1:index] {
+			intermediates.AddCert(intermediate)
+		}
+		_, err := state.PeerCertificates[0].Verify(x509.VerifyOptions{DNSName: upstream.ServerName, Roots: roots, Intermediates: intermediates})
+		if err != nil {
+			return fmt.Errorf("%w: %s: %s", ErrPinMismatch, upstream.String(), err.Error())
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrPinMismatch, upstream.String())
+}
+
+//Returns true if the public key of the certificate matches one of the pins of the upstream.
+func (upstream *Upstream) isPinned(certificate *x509.Certificate) bool {
+	digest := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+	for _, pin := range upstream.Pins {
+		if subtle.ConstantTimeCompare(digest[:], pin) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+//Forwards queries to recursive resolvers over DNS-over-TLS or DNS-over-HTTPS instead of resolving them iteratively. The upstreams are tried in the
+//order they are configured, and an upstream that fails is set aside for UPSTREAM_FAILURE_HOLD so that the queries that follow go
+//straight to the next one. One connection is kept open per upstream and shared by all the queries sent to it. It is safe for
+//concurrent use.
+type Forwarder struct {
+	//Upstreams, in the order of preference.
+	Upstreams []*Upstream
+	//Guards the connections and the failure times.
+	mutex sync.Mutex
+	//Open connections, keyed by the string representation of their upstream.
+	connections map[string]upstreamConnection
+	//Time until which each failed upstream is set aside, keyed by the string representation of the upstream.
+	failures map[string]time.Time
+}
+
+//Creates a forwarder sending queries to the given upstreams, in the order of preference.
+func NewForwarder(upstreams []*Upstream) *Forwarder {
+	return &Forwarder{Upstreams: upstreams, connections: make(map[string]upstreamConnection), failures: make(map[string]time.Time)}
+}
+
+//Sends the packed query to the upstreams, one after the other, until one of them responds before the deadline. Returns the packed
+//response along with the upstream that sent it. A query written to a connection that turns out to have been closed by the upstream
+//is sent again over a new connection.
+func (forwarder *Forwarder) Exchange(buffer []byte, deadline time.Time) ([]byte, *Upstream, error) {
+	var lastErr error = ErrNoResponse
+	for _, upstream := range forwarder.ordered(time.Now()) {
+		for attempt := 0; attempt < 2; attempt++ {
+			conn, reused, err := forwarder.connection(upstream, deadline)
+			if err != nil {
+				lastErr = err
+				break
+			}
+
+			response, err := conn.Exchange(buffer, deadline)
+			if err == nil {
+				forwarder.recordSuccess(upstream)
+				return response, upstream, nil
+			}
+			lastErr = err
+			if !reused || !conn.IsClosed() || errors.Is(err, ErrNoResponse) {
+				break
+			}
+		}
+		forwarder.recordFailure(upstream)
+	}
+	return nil, nil, fmt.Errorf("%w: no upstream answered: %s", ErrNoResponse, lastErr.Error())
+}
+
+//Closes the connections to the upstreams.
+func (forwarder *Forwarder) Close() {
+	forwarder.mutex.Lock()
+	defer forwarder.mutex.Unlock()
+	for key, conn := range forwarder.connections {
+		conn.Close()
+		delete(forwarder.connections, key)
+	}
+}
+
+//Returns the upstreams in the order they are to be tried: those that have not failed recently in the order of preference, followed
+//by those that have, which are only tried once all others fail.
+func (forwarder *Forwarder) ordered(now time.Time) []*Upstream {
+	forwarder.mutex.Lock()
+	defer forwarder.mutex.Unlock()
+	available, failed := make([]*Upstream, 0, len(forwarder.Upstreams)), make([]*Upstream, 0)
+	for _, upstream := range forwarder.Upstreams {
+		if now.Before(forwarder.failures[upstream.String()]) {
+			failed = append(failed, upstream)
+		} else {
+			available = append(available, upstream)
+		}
+	}
+	return append(available, failed...)
+}
+
+//Returns the open connection to the upstream, connecting to it first if there is none. Also returns true if the connection was
+//already open.
+func (forwarder *Forwarder) connection(upstream *Upstream, deadline time.Time) (upstreamConnection, bool, error) {
+	forwarder.mutex.Lock()
+	defer forwarder.mutex.Unlock()
+	key := upstream.String()
+	conn, exists := forwarder.connections[key]
+	if exists && !conn.IsClosed() {
+		return conn, true, nil
+	}
+
+	var err error
+	if upstream.IsHTTPS() {
+		httpsConnect := &HttpsConnect{}
+		err = httpsConnect.ConnectTo(upstream.Template, upstream.tlsConfig(), upstream.Bootstrap)
+		conn = httpsConnect
+	} else {
+		tlsConnect := &TlsConnect{}
+		err = tlsConnect.ConnectTo(upstream.Address, upstream.tlsConfig(), deadline)
+		conn = tlsConnect
+	}
+
+	if err != nil {
+		delete(forwarder.connections, key)
+		return nil, false, err
+	}
+	forwarder.connections[key] = conn
+	return conn, false, nil
+}
+
+//Sets the upstream aside after a failure.
+func (forwarder *Forwarder) recordFailure(upstream *Upstream) {
+	forwarder.mutex.Lock()
+	forwarder.failures[upstream.String()] = time.Now().Add(UPSTREAM_FAILURE_HOLD)
+	forwarder.mutex.Unlock()
+}
+
+//Brings the upstream back into use after it answered.
+func (forwarder *Forwarder) recordSuccess(upstream *Upstream) {
+	forwarder.mutex.Lock()
+	delete(forwarder.failures, upstream.String())
+	forwarder.mutex.Unlock()
+}
+
+//Sends the request to the upstreams of the forwarder instead of the name servers of a zone, with a fresh random message ID, and checks
+//that the response answers it. Returns the response along with the upstream that sent it.
+func (resolver *Resolver) forward(request *Message) (*Message, string, error) {
+	err := resolver.limits.Spend()
+	if err != nil {
+		return nil, "", err
+	}
+
+	request.Header.SetIdentifier(Id())
+	request.Header.SetRecursionDesired(true)
+	resolver.Log("**********************************************")
+	resolver.Log("DNS Request being forwarded upstream.")
+	resolver.Log("**********************************************")
+	resolver.Log(fmt.Sprintf("Request Contents are:\n%s", request.String()))
+	buffer, upstream, err := resolver.forwarder.Exchange(request.Pack(), resolver.limits.Deadline(time.Now().Add(UPSTREAM_RESPONSE_TIMEOUT)))
+	if err != nil {
+		return nil, "", err
+	}
+
+	response, err := matchResponse(request, buffer)
+	if err != nil {
+		resolver.Discarded.count(err)
+		return nil, "", fmt.Errorf("%w: %s sent a response that does not match the query: %s", ErrNoResponse, upstream.String(), err.Error())
+	}
+	resolver.Log(fmt.Sprintf("Response received back from %s:\n%s", upstream.String(), response.String()))
+	resolver.Log("**********************************************")
+	return response, upstream.String(), nil
+}
@@ -0,0 +1,211 @@
+package dns
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+)
+
+//Name the certificates of the test servers are issued for.
+const TEST_SERVER_NAME = "dns.test"
+
+//Certification authority issuing the certificates of the test servers.
+type testAuthority struct {
+	certificate *x509.Certificate
+	key *ecdsa.PrivateKey
+}
+
+//Creates a self-signed certification authority with the given common name.
+func newTestAuthority(t *testing.T, name string) *testAuthority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: name}, NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testAuthority{certificate: certificate, key: key}
+}
+
+//Issues a certificate for TEST_SERVER_NAME and 127.0.0.1, presented along with the certificate of the authority.
+func (authority *testAuthority) Issue(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: TEST_SERVER_NAME}, NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+		DNSNames: []string{TEST_SERVER_NAME}, IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)}, ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage: x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, authority.certificate, &key.PublicKey, authority.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der, authority.certificate.Raw}, PrivateKey: key}
+}
+
+//Returns a pool holding the certificate of the authority.
+func (authority *testAuthority) Pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(authority.certificate)
+	return pool
+}
+
+//Returns the SHA-256 digest of the SubjectPublicKeyInfo of the first certificate of the chain.
+func pinOf(t *testing.T, certificate []byte) []byte {
+	t.Helper()
+	parsed, err := x509.ParseCertificate(certificate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(parsed.RawSubjectPublicKeyInfo)
+	return digest[:]
+}
+
+//Starts a DNS-over-TLS server presenting the given certificate chain, which answers every query with an A record of 192.0.2.53, and
+//returns its address.
+func serveDoT(t *testing.T, certificate tls.Certificate) string {
+	t.Helper()
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				for {
+					header := make([]byte, 2)
+					if _, err := io.ReadFull(conn, header); err != nil {
+						return
+					}
+					buffer := make([]byte, UnpackUInt16(header))
+					if _, err := io.ReadFull(conn, buffer); err != nil {
+						return
+					}
+					request := NewMessage(MSG_REQUEST, 0)
+					if request.Unpack(buffer) != nil {
+						return
+					}
+					response := packedAnswer(request, "192.0.2.53")
+					conn.Write(append(PackUInt16(uint16(len(response))), response...))
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestUpstreamCertificateVerification(t *testing.T) {
+	authority, attacker := newTestAuthority(t, "Test CA"), newTestAuthority(t, "Attacker CA")
+	certificate := authority.Issue(t)
+	forged := attacker.Issue(t)
+	//Chain of a certificate issued by another authority, with the certificate of the pinned authority appended to it.
+	forgedWithPinnedIssuer := tls.Certificate{Certificate: [][]byte{forged.Certificate[0], authority.certificate.Raw}, PrivateKey: forged.PrivateKey}
+
+	testCases := []struct {
+		name string
+		chain tls.Certificate
+		rootCAs *x509.CertPool
+		pins [][]byte
+		ok bool
+		err error
+	}{
+		{name: "certificate issued by a trusted authority", chain: certificate, rootCAs: authority.Pool(), ok: true},
+		{name: "certificate issued by an untrusted authority", chain: forged, rootCAs: authority.Pool()},
+		{name: "pin of the upstream certificate", chain: certificate, pins: [][]byte{pinOf(t, certificate.Certificate[0])}, ok: true},
+		{name: "pin of the issuing authority", chain: certificate, pins: [][]byte{pinOf(t, authority.certificate.Raw)}, ok: true},
+		{name: "pin matching no certificate", chain: certificate, pins: [][]byte{pinOf(t, attacker.certificate.Raw)}, err: ErrPinMismatch},
+		{name: "pinned authority appended to a chain it did not issue", chain: forgedWithPinnedIssuer, pins: [][]byte{pinOf(t, authority.certificate.Raw)}, err: ErrPinMismatch},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			upstream := Upstream{Address: serveDoT(t, testCase.chain), ServerName: TEST_SERVER_NAME, RootCAs: testCase.rootCAs, Pins: testCase.pins}
+			conn := &TlsConnect{}
+			err := conn.ConnectTo(upstream.Address, upstream.tlsConfig(), time.Now().Add(5 * time.Second))
+			if err == nil {
+				defer conn.Close()
+			}
+			if testCase.ok && err != nil {
+				t.Fatalf("connection failed: %s", err.Error())
+			} else if !testCase.ok && err == nil {
+				t.Fatal("connection succeeded, expected it to fail")
+			} else if testCase.err != nil && !errors.Is(err, testCase.err) {
+				t.Errorf("connection failed with %v, expected %v", err, testCase.err)
+			}
+		})
+	}
+}
+
+func TestForwarderFallsBackToNextUpstream(t *testing.T) {
+	authority := newTestAuthority(t, "Test CA")
+	working := &Upstream{Address: serveDoT(t, authority.Issue(t)), ServerName: TEST_SERVER_NAME, RootCAs: authority.Pool()}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unreachable := listener.Addr().String()
+	listener.Close()
+
+	testCases := []struct {
+		name string
+		failing *Upstream
+	}{
+		{name: "unreachable upstream", failing: &Upstream{Address: unreachable, ServerName: TEST_SERVER_NAME, RootCAs: authority.Pool()}},
+		{name: "upstream with a mismatched pin", failing: &Upstream{Address: working.Address, ServerName: "other.test", Pins: [][]byte{make([]byte, sha256.Size)}}},
+		{name: "upstream with an untrusted certificate", failing: &Upstream{Address: working.Address, ServerName: "127.0.0.1", RootCAs: x509.NewCertPool()}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			forwarder := NewForwarder([]*Upstream{testCase.failing, working})
+			defer forwarder.Close()
+			for attempt := 0; attempt < 2; attempt++ {
+				request := newQuery(Id(), "www.example.com.", TYPE_A)
+				buffer, upstream, err := forwarder.Exchange(request.Pack(), time.Now().Add(5 * time.Second))
+				if err != nil {
+					t.Fatalf("exchange failed: %s", err.Error())
+				}
+				if upstream != working {
+					t.Errorf("answered by %s, expected %s", upstream.String(), working.String())
+				}
+				if _, err := matchResponse(request, buffer); err != nil {
+					t.Errorf("response does not match the query: %s", err.Error())
+				}
+			}
+			if ordered := forwarder.ordered(time.Now()); ordered[0] != working {
+				t.Errorf("failed upstream %s is still tried first", testCase.failing.String())
+			}
+		})
+	}
+}
@@ -287,6 +287,14 @@ func (hdr *Header) String() string {
 		flags = append(flags, "RA")
 	}
 
+	if hdr.Authenticated {
+		flags = append(flags, "AD")
+	}
+
+	if hdr.CheckingDisabled {
+		flags = append(flags, "CD")
+	}
+
 	return_value += fmt.Sprintf("Flags: %s, QUESTION: %d, ANSWER: %d, AUTHORITY: %d, ADDITIONAL: %d\n", strings.Join(flags, " "), int(hdr.QdCount), int(hdr.AnCount), int(hdr.NsCount), int(hdr.ArCount))
 	return return_value
 }
\ No newline at end of file
@@ -0,0 +1,188 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Structure to manage the exchange of DNS messages with a DNS-over-HTTPS server (RFC 8484). The HTTP client keeps its connections to
+//the server open and reuses them, and concurrent queries share a single connection over HTTP/2.
+type HttpsConnect struct {
+	Client *http.Client
+	//URL template of the server (RFC 8484 - Section 4.1), such as "https://dns.example/dns-query{?dns}".
+	Template string
+	//Closed once the client can no longer be used.
+	closed chan struct{}
+	//Ensures the client is closed only once by concurrent callers.
+	closeOnce sync.Once
+}
+
+//Prepares the HTTP client used to reach the server at the given URL template with the given TLS configuration. If bootstrap addresses
+//are given, connections are made to them, in order, instead of to the addresses the host of the URL resolves to. No connection is
+//opened until the first message is exchanged.
+func (hc *HttpsConnect) ConnectTo(template string, config *tls.Config, bootstrap []string) error {
+	if _, err := expandDoHTemplate(template, nil); err != nil {
+		return err
+	}
+
+	dialer := net.Dialer{}
+	transport := http.Transport{TLSClientConfig: config, ForceAttemptHTTP2: true, DialContext: dialer.DialContext}
+	if len(bootstrap) > 0 {
+		transport.DialContext = func(ctx context.Context, network string, address string) (net.Conn, error) {
+			_, port, err := net.SplitHostPort(address)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, ip := range bootstrap {
+				var conn net.Conn
+				conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+				if err == nil {
+					return conn, nil
+				}
+			}
+			return nil, err
+		}
+	}
+
+	hc.Client = &http.Client{Transport: &transport}
+	hc.Template = template
+	hc.closed = make(chan struct{})
+	return nil
+}
+
+//Sends the message to the server and waits until its response arrives or the deadline passes. The message is sent with a GET request
+//if the URL template has a "dns" variable and with a POST request otherwise. As RFC 8484 - Section 4.1 recommends, the message ID is
+//set to 0 on the wire so that responses can be cached by HTTP caches, and the ID of the message is restored in the response.
+func (hc *HttpsConnect) Exchange(buffer []byte, deadline time.Time) ([]byte, error) {
+	if len(buffer) < MESSAGE_HEADER_LENGTH {
+		return nil, ErrParametersMissing
+	} else if hc.IsClosed() {
+		return nil, fmt.Errorf("%w: %s", ErrConnectionClosed, hc.Template)
+	}
+
+	id := buffer[:2:2]
+	message := append(PackUInt16(0), buffer[2:]...)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	request, err := hc.newRequest(ctx, message)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := hc.Client.Do(request)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%w: %s did not respond in time", ErrNoResponse, hc.Template)
+		}
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(response.Header.Get("Content-Type"))
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: %s answered with HTTP status %s", ErrNoResponse, hc.Template, response.Status)
+	} else if mediaType != DOH_MEDIA_TYPE {
+		return nil, fmt.Errorf("%w: %s answered with content of type %q", ErrNoResponse, hc.Template, mediaType)
+	}
+
+	received, err := io.ReadAll(io.LimitReader(response.Body, STREAM_MESSAGE_SIZE_LIMIT + 1))
+	if err != nil {
+		return nil, err
+	} else if len(received) > STREAM_MESSAGE_SIZE_LIMIT {
+		return nil, ErrMessageTooLong
+	}
+
+	if len(received) >= MESSAGE_HEADER_LENGTH && UnpackUInt16(received[:2]) == 0 {
+		copy(received[:2], id)
+	}
+	return received, nil
+}
+
+//Returns true if the client has been closed and can no longer be used.
+func (hc *HttpsConnect) IsClosed() bool {
+	select {
+	case <-hc.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+//Closes the connections held open by the HTTP client.
+func (hc *HttpsConnect) Close() error {
+	hc.closeOnce.Do(func() {
+		close(hc.closed)
+		hc.Client.CloseIdleConnections()
+	})
+	return nil
+}
+
+//Returns the HTTP request carrying the packed message, as a GET request with the message in the "dns" variable of the URL template
+//or as a POST request with the message as its body.
+func (hc *HttpsConnect) newRequest(ctx context.Context, message []byte) (*http.Request, error) {
+	var request *http.Request
+	var err error
+	if hasDoHVariable(hc.Template) {
+		var target string
+		target, err = expandDoHTemplate(hc.Template, message)
+		if err != nil {
+			return nil, err
+		}
+		request, err = http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	} else {
+		request, err = http.NewRequestWithContext(ctx, http.MethodPost, hc.Template, bytes.NewReader(message))
+		if err == nil {
+			request.Header.Set("Content-Type", DOH_MEDIA_TYPE)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Accept", DOH_MEDIA_TYPE)
+	return request, nil
+}
+
+//Returns true if the URL template has a "dns" variable, in which case queries are sent with GET requests.
+func hasDoHVariable(template string) bool {
+	return strings.Contains(template, "{?dns}") || strings.Contains(template, "{&dns}")
+}
+
+//Returns the URL given by the template for the packed message, with the "dns" variable set to the message in unpadded base64url
+//(RFC 8484 - Section 6). A nil message leaves the variable out. Only the "dns" variable, in the "{?dns}" or "{&dns}" forms, is
+//supported, and the URL must use the https scheme.
+func expandDoHTemplate(template string, message []byte) (string, error) {
+	value := ""
+	if message != nil {
+		value = "dns=" + base64.RawURLEncoding.EncodeToString(message)
+	}
+
+	expanded := template
+	if value != "" {
+		expanded = strings.Replace(expanded, "{?dns}", "?" + value, 1)
+		expanded = strings.Replace(expanded, "{&dns}", "&" + value, 1)
+	} else {
+		expanded = strings.Replace(expanded, "{?dns}", "", 1)
+		expanded = strings.Replace(expanded, "{&dns}", "", 1)
+	}
+
+	target, err := url.Parse(expanded)
+	if err != nil || strings.ContainsAny(expanded, "{}") {
+		return "", fmt.Errorf("%w: invalid URL template %q", ErrInvalidUpstream, template)
+	} else if target.Scheme != "https" || target.Hostname() == "" {
+		return "", fmt.Errorf("%w: URL template %q must be an https URL", ErrInvalidUpstream, template)
+	}
+	return expanded, nil
+}
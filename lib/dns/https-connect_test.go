@@ -0,0 +1,150 @@
+package dns
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+//Starts a DNS-over-HTTPS server answering with the given handler, and returns the URL of its DOH_PATH along with the TLS configuration
+//clients trust it with.
+func serveDoH(t *testing.T, handler http.HandlerFunc) (string, *tls.Config) {
+	t.Helper()
+	authority := newTestAuthority(t, "Test CA")
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{authority.Issue(t)}}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server.URL + DOH_PATH, &tls.Config{RootCAs: authority.Pool()}
+}
+
+//Answers the query carried by the request with an A record of 192.0.2.53, after checking that it was sent with the given method.
+func answerDoH(t *testing.T, method string) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		var buffer []byte
+		var err error
+		if request.Method != method {
+			t.Errorf("query sent with %s, expected %s", request.Method, method)
+		} else if method == http.MethodGet {
+			buffer, err = base64.RawURLEncoding.DecodeString(request.URL.Query().Get("dns"))
+		} else {
+			if request.Header.Get("Content-Type") != DOH_MEDIA_TYPE {
+				t.Errorf("query sent as %q, expected %q", request.Header.Get("Content-Type"), DOH_MEDIA_TYPE)
+			}
+			buffer, err = io.ReadAll(request.Body)
+		}
+
+		query := NewMessage(MSG_REQUEST, 0)
+		if err != nil || query.Unpack(buffer) != nil {
+			http.Error(writer, "malformed DNS query", http.StatusBadRequest)
+			return
+		} else if query.Header.Identifier != 0 {
+			t.Errorf("query sent with message ID %d, expected 0", query.Header.Identifier)
+		}
+		writer.Header().Set("Content-Type", DOH_MEDIA_TYPE)
+		writer.Write(packedAnswer(query, "192.0.2.53"))
+	}
+}
+
+func TestHttpsConnectExchange(t *testing.T) {
+	testCases := []struct {
+		name string
+		variable string
+		handler func(*testing.T) http.HandlerFunc
+		err error
+	}{
+		{
+			name: "GET request with the query in base64url", variable: "{?dns}",
+			handler: func(t *testing.T) http.HandlerFunc { return answerDoH(t, http.MethodGet) },
+		},
+		{
+			name: "POST request with the query as its body",
+			handler: func(t *testing.T) http.HandlerFunc { return answerDoH(t, http.MethodPost) },
+		},
+		{
+			name: "response of another content type",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(writer http.ResponseWriter, request *http.Request) {
+					writer.Header().Set("Content-Type", "text/html")
+					writer.Write([]byte("<html></html>"))
+				}
+			},
+			err: ErrNoResponse,
+		},
+		{
+			name: "response with an error status",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(writer http.ResponseWriter, request *http.Request) {
+					http.Error(writer, "unavailable", http.StatusServiceUnavailable)
+				}
+			},
+			err: ErrNoResponse,
+		},
+		{
+			name: "response longer than a DNS message",
+			handler: func(t *testing.T) http.HandlerFunc {
+				return func(writer http.ResponseWriter, request *http.Request) {
+					writer.Header().Set("Content-Type", DOH_MEDIA_TYPE)
+					writer.Write(bytes.Repeat([]byte{0}, STREAM_MESSAGE_SIZE_LIMIT + 1))
+				}
+			},
+			err: ErrMessageTooLong,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			target, config := serveDoH(t, testCase.handler(t))
+			conn := &HttpsConnect{}
+			err := conn.ConnectTo(target + testCase.variable, config, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+
+			request := newQuery(0x4321, "www.example.com.", TYPE_A)
+			buffer, err := conn.Exchange(request.Pack(), time.Now().Add(5 * time.Second))
+			if testCase.err != nil {
+				if !errors.Is(err, testCase.err) {
+					t.Errorf("exchange returned %v, expected %v", err, testCase.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("exchange failed: %s", err.Error())
+			}
+			response, err := matchResponse(request, buffer)
+			if err != nil || len(response.Answers) != 1 {
+				t.Errorf("response does not answer the query: %v", err)
+			}
+		})
+	}
+}
+
+func TestHttpsConnectConcurrentClose(t *testing.T) {
+	conn := &HttpsConnect{}
+	err := conn.ConnectTo("https://dns.test" + DOH_PATH, &tls.Config{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var closers sync.WaitGroup
+	for index := 0; index < 8; index++ {
+		closers.Add(1)
+		go func() {
+			defer closers.Done()
+			conn.Close()
+		}()
+	}
+	closers.Wait()
+	if !conn.IsClosed() {
+		t.Error("connection is not closed")
+	}
+}
@@ -0,0 +1,142 @@
+package dns
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Round-trip statistics gathered for a single name server.
+type ServerStats struct {
+	//Smoothed round-trip time of the queries sent to the server.
+	SRTT time.Duration
+	//Number of consecutive queries the server failed to answer.
+	Failures int
+	//Total number of queries sent to the server.
+	Queries int
+	//Time at which the statistics were last updated.
+	LastUpdated time.Time
+}
+
+//Infrastructure cache that tracks the smoothed round-trip time (SRTT) and failures of every name server queried,
+//so that the fastest responsive server of an NS set can be picked for later queries.
+type InfraCache struct {
+	mutex sync.Mutex
+	//Statistics of each name server, keyed by its IP address.
+	servers map[string]*ServerStats
+}
+
+//Creates a new, empty infrastructure cache.
+func NewInfraCache() *InfraCache {
+	ic := InfraCache{}
+	ic.servers = make(map[string]*ServerStats)
+	return &ic
+}
+
+//Returns the statistics of the given server, creating them with a small random SRTT if the server has never been seen.
+//The random SRTT makes sure every new server gets tried before falling back to the ones already measured.
+func (ic *InfraCache) getStats(server string) *ServerStats {
+	stats, ok := ic.servers[server]
+	if !ok {
+		stats = &ServerStats{}
+		stats.SRTT = time.Duration(rand.Int64N(int64(UNKNOWN_SERVER_SRTT)))
+		stats.LastUpdated = time.Now()
+		ic.servers[server] = stats
+	}
+	return stats
+}
+
+//Records a response received from the server after the given round-trip time.
+func (ic *InfraCache) RecordSuccess(server string, rtt time.Duration) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	stats := ic.getStats(server)
+	if stats.Queries == 0 || stats.Failures > 0 {
+		stats.SRTT = rtt
+	} else {
+		stats.SRTT = time.Duration((1 - SRTT_WEIGHT) * float64(stats.SRTT) + SRTT_WEIGHT * float64(rtt))
+	}
+	stats.Queries++
+	stats.Failures = 0
+	stats.LastUpdated = time.Now()
+}
+
+//Records a query the server failed to answer. The SRTT of the server is doubled, up to the response timeout.
+func (ic *InfraCache) RecordFailure(server string) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	stats := ic.getStats(server)
+	stats.SRTT = min(stats.SRTT * 2 + UNKNOWN_SERVER_SRTT, UDP_RESPONSE_TIMEOUT)
+	stats.Queries++
+	stats.Failures++
+	stats.LastUpdated = time.Now()
+}
+
+//Orders the given servers from most to least preferred. Responsive servers come first, sorted by SRTT, and servers
+//that failed repeatedly come last. Occasionally a random server is moved to the front so that the SRTT of slower
+//servers keeps getting refreshed.
+func (ic *InfraCache) Select(servers []string) []string {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	ordered := make([]string, len(servers))
+	copy(ordered, servers)
+	for _, server := range ordered {
+		ic.getStats(server)
+	}
+
+	sort.SliceStable(ordered, func(i int, j int) bool {
+		first, second := ic.servers[ordered[i]], ic.servers[ordered[j]]
+		firstDown, secondDown := first.Failures >= SERVER_FAILURE_LIMIT, second.Failures >= SERVER_FAILURE_LIMIT
+		if firstDown != secondDown {
+			return secondDown
+		}
+		return first.SRTT < second.SRTT
+	})
+
+	if len(ordered) > 1 && rand.Float64() < SERVER_EXPLORATION_RATE {
+		index := 1 + rand.IntN(len(ordered) - 1)
+		ordered[0], ordered[index] = ordered[index], ordered[0]
+	}
+	return ordered
+}
+
+//Returns a copy of the statistics of the given server and whether the server has been seen before.
+func (ic *InfraCache) Get(server string) (ServerStats, bool) {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	stats, ok := ic.servers[server]
+	if !ok {
+		return ServerStats{}, false
+	}
+	return *stats, true
+}
+
+//Returns the statistics of the given servers as a table, one server per line.
+func (ic *InfraCache) Describe(servers []string) string {
+	ic.mutex.Lock()
+	defer ic.mutex.Unlock()
+	lines := make([]string, 0, len(servers))
+	for _, server := range servers {
+		stats, ok := ic.servers[server]
+		if !ok {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s \t SRTT: %s \t Queries: %d \t Failures: %d", server, stats.SRTT.Round(time.Microsecond), stats.Queries, stats.Failures))
+	}
+	return strings.Join(lines, NEWLINE_SEPERATOR)
+}
+
+//Returns the string representation of the infrastructure cache, listing every server sorted by its IP address.
+func (ic *InfraCache) String() string {
+	ic.mutex.Lock()
+	servers := make([]string, 0, len(ic.servers))
+	for server := range ic.servers {
+		servers = append(servers, server)
+	}
+	ic.mutex.Unlock()
+	sort.Strings(servers)
+	return ic.Describe(servers)
+}
@@ -0,0 +1,95 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+//Answers the request sent to an in-memory server, returning nil to leave it unanswered.
+type MemoryHandler func(request *Message) *Message
+
+//Exchanger that hands queries to handlers registered in memory instead of sending them over the network, so that the resolver can be
+//exercised without any network access. Messages are packed and parsed on their way to and from the handlers, as they would be on the
+//wire. As on UDP, a response larger than the payload size advertised by the request (512 octets without EDNS) is truncated, unless the
+//exchanger emulates a stream transport. It is safe for concurrent use.
+type MemoryExchanger struct {
+	//Emulates a stream transport, on which responses are never truncated, instead of a datagram transport.
+	Stream bool
+	//Handlers of the in-memory servers, shared with the exchangers created by StreamExchanger.
+	servers *memoryServers
+}
+
+//Handlers of the in-memory servers, keyed by their address.
+type memoryServers struct {
+	//Guards the handlers.
+	mutex sync.RWMutex
+	//Handlers, keyed by the address of their server given as "ip:port".
+	handlers map[string]MemoryHandler
+}
+
+//Creates an in-memory exchanger emulating a datagram transport, with no servers.
+func NewMemoryExchanger() *MemoryExchanger {
+	return &MemoryExchanger{servers: &memoryServers{handlers: make(map[string]MemoryHandler)}}
+}
+
+//Registers the handler answering the queries sent to the given address, given as "ip:port". A nil handler removes the server.
+func (me *MemoryExchanger) Handle(address string, handler MemoryHandler) {
+	me.servers.mutex.Lock()
+	defer me.servers.mutex.Unlock()
+	if handler == nil {
+		delete(me.servers.handlers, address)
+	} else {
+		me.servers.handlers[address] = handler
+	}
+}
+
+//Returns an exchanger reaching the same servers over an emulated stream transport, on which responses are never truncated.
+func (me *MemoryExchanger) StreamExchanger() *MemoryExchanger {
+	return &MemoryExchanger{Stream: true, servers: me.servers}
+}
+
+//Hands the request to the handler of the server at the given address and returns its response. Fails with ErrNoServer if no handler
+//is registered at the address, and with ErrNoResponse if the handler leaves the request unanswered.
+func (me *MemoryExchanger) Exchange(ctx context.Context, request *Message, address string) (*Message, error) {
+	me.servers.mutex.RLock()
+	handler, exists := me.servers.handlers[address]
+	me.servers.mutex.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrNoServer, address)
+	} else if ctx.Err() != nil {
+		return nil, fmt.Errorf("%w: %s did not respond in time", ErrNoResponse, address)
+	}
+
+	received := NewMessage(MSG_REQUEST, 0)
+	err := received.Unpack(request.Pack())
+	if err != nil {
+		return nil, err
+	}
+	answer := handler(received)
+	if answer == nil {
+		return nil, fmt.Errorf("%w: %s did not respond", ErrNoResponse, address)
+	}
+
+	buffer := answer.Pack()
+	if !me.Stream && len(buffer) > advertisedPayloadSize(received) {
+		truncated := NewMessage(MSG_RESPONSE, answer.Header.Identifier)
+		truncated.Header = answer.Header
+		truncated.Header.Truncation = true
+		truncated.Questions = answer.Questions
+		truncated.Header.SetAnswerCount(0)
+		truncated.Header.SetNameServerCount(0)
+		truncated.Header.SetAdditionalRecordCount(0)
+		buffer = truncated.Pack()
+	}
+	return matchResponse(request, buffer)
+}
+
+//Returns the largest UDP payload the sender of the request can receive, as advertised by its OPT record (RFC 6891 - Section 6.2.5).
+func advertisedPayloadSize(request *Message) int {
+	opt, ok := request.GetEDNS()
+	if !ok || int(opt.Class) < UDP_DEFAULT_PAYLOAD_SIZE {
+		return UDP_DEFAULT_PAYLOAD_SIZE
+	}
+	return int(opt.Class)
+}
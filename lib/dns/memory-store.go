@@ -0,0 +1,268 @@
+package dns
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+//In-memory representation of a local resource stored in a cache store.
+type LocalResource struct {
+	LastModified time.Time
+	resource *Resource
+}
+
+//Returns the string representation of the local resource record.
+func (lr *LocalResource) String() string {
+	resourceString := lr.resource.CacheString()
+	return fmt.Sprintf("%s%s%s\n", resourceString, WHITESPACE, lr.LastModified.Format(time.RFC3339))
+}
+
+//Returns the resource record held by the local resource.
+func (lr *LocalResource) GetResource() *Resource {
+	return lr.resource
+}
+
+//Returns true if the TTL of the local resource has elapsed since it was last modified.
+func (lr *LocalResource) HasExpired() bool {
+	TimeSinceLastMod := time.Now().UTC().Sub(lr.LastModified)
+	return TimeSinceLastMod.Seconds() > float64(lr.resource.TTL)
+}
+
+//Returns the number of seconds left before the local resource expires.
+func (lr *LocalResource) RemainingTTL() uint32 {
+	elapsed := time.Now().UTC().Sub(lr.LastModified).Seconds()
+	if elapsed >= float64(lr.resource.TTL) {
+		return 0
+	}
+	return lr.resource.TTL - uint32(elapsed)
+}
+
+//Cache store that holds all the resource records in memory and never persists them. Records are indexed by their canonical domain
+//name and record type. An expired RRset is removed when it is looked up, and the whole store is swept for expired records at most
+//once every CACHE_SWEEP_INTERVAL as records are added, so that records that are never looked up again do not pile up. It is safe for
+//concurrent use.
+type MemoryStore struct {
+	//Guards the resource records against concurrent access.
+	mutex sync.RWMutex
+	//RRsets present in the store, keyed by their canonical domain name and record type.
+	rrsets map[string]map[RecordType][]LocalResource
+	//Time at which the store was last swept for expired records.
+	lastSweep time.Time
+}
+
+//Creates a new, empty in-memory cache store.
+func NewMemoryStore() *MemoryStore {
+	ms := MemoryStore{}
+	ms.rrsets = make(map[string]map[RecordType][]LocalResource)
+	ms.lastSweep = time.Now().UTC()
+	return &ms
+}
+
+//Creates a new local resource object and returns a pointer to the object.
+func (ms *MemoryStore) NewLocalResource(name string, ttl uint32, class string, recType string, data string, LastModified string) *LocalResource {
+	localResource := LocalResource{}
+	localResource.resource = NewResourceRecord(name, ttl, class, recType, data)
+	lastMod, err := time.Parse(time.RFC3339 ,LastModified)
+	if err != nil {
+		localResource.LastModified = time.Now().UTC()
+	} else {
+		localResource.LastModified = lastMod
+	}
+	return &localResource
+}
+
+//Creates a new local resource record and adds it to the store if it has not already expired.
+func (ms *MemoryStore) Add(name string, ttl uint32, class string, recType string, data string) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.add(name, ttl, class, recType, data)
+}
+
+//Adds a new local resource record to the store without acquiring the lock.
+func (ms *MemoryStore) add(name string, ttl uint32, class string, recType string, data string) {
+	CurrentTime := time.Now().UTC()
+	if ttl != 0 && !ms.HasRecordExpired(ttl, CurrentTime) {
+		localResource := ms.NewLocalResource(name, ttl, class, recType, data, CurrentTime.Format(time.RFC3339))
+		ms.insert(*localResource)
+	}
+}
+
+//Adds the local resource to the RRset it belongs to, without acquiring the lock.
+func (ms *MemoryStore) insert(localResource LocalResource) {
+	if ms.rrsets == nil {
+		ms.rrsets = make(map[string]map[RecordType][]LocalResource)
+	}
+	name := Canonicalize(localResource.resource.Name.Value)
+	if ms.rrsets[name] == nil {
+		ms.rrsets[name] = make(map[RecordType][]LocalResource)
+	}
+	recType := localResource.resource.Type
+	ms.rrsets[name][recType] = append(ms.rrsets[name][recType], localResource)
+}
+
+//Returns the unexpired RRset matching the given domain name and record type.
+func (ms *MemoryStore) Get(name string, recType RecordType) ([]Resource, bool) {
+	return ms.FindResources(name, recType)
+}
+
+//Replaces the RRsets present in the store with the given resource records. Records are grouped into RRsets by their domain name and record type,
+//and RRSIG records by the type of the RRset they cover, so that the signatures of the other RRsets of the domain name are kept.
+func (ms *MemoryStore) Put(resources []Resource) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	for _, RR := range resources {
+		if rrsig, ok := RR.Rdata.(*RRSIGResource); ok {
+			ms.deleteSignatures(RR.Name.Value, rrsig.TypeCovered)
+		} else {
+			ms.delete(RR.Name.Value, RR.Type)
+		}
+	}
+
+	for _, RR := range resources {
+		ms.add(RR.Name.Value, RR.TTL, RR.Class.String(), RR.Type.String(), RR.GetData())
+	}
+
+	if time.Since(ms.lastSweep) >= CACHE_SWEEP_INTERVAL {
+		ms.sweep()
+	}
+}
+
+//Removes the RRset matching the given domain name and record type from the store. If the record type is 0, RRsets of all types are removed for the domain name.
+func (ms *MemoryStore) Delete(name string, recType RecordType) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+	ms.delete(name, recType)
+}
+
+//Removes the RRset matching the given domain name and record type without acquiring the lock.
+func (ms *MemoryStore) delete(name string, recType RecordType) {
+	name = Canonicalize(name)
+	if recType == 0 {
+		delete(ms.rrsets, name)
+		return
+	}
+
+	delete(ms.rrsets[name], recType)
+	if len(ms.rrsets[name]) == 0 {
+		delete(ms.rrsets, name)
+	}
+}
+
+//Removes the RRSIG records of the given domain name that cover the given record type, without acquiring the lock.
+func (ms *MemoryStore) deleteSignatures(name string, typeCovered RecordType) {
+	ms.retain(name, TYPE_RRSIG, func(lrr LocalResource) bool {
+		rrsig, ok := lrr.resource.Rdata.(*RRSIGResource)
+		return !ok || rrsig.TypeCovered != typeCovered
+	})
+}
+
+//Keeps only the records of the RRset matching the given domain name and record type for which 'keep' returns true, removing the RRset
+//if none are left, without acquiring the lock.
+func (ms *MemoryStore) retain(name string, recType RecordType, keep func(lrr LocalResource) bool) {
+	name = Canonicalize(name)
+	retained := make([]LocalResource, 0, len(ms.rrsets[name][recType]))
+	for _, lrr := range ms.rrsets[name][recType] {
+		if keep(lrr) {
+			retained = append(retained, lrr)
+		}
+	}
+
+	if len(retained) > 0 {
+		ms.rrsets[name][recType] = retained
+	} else {
+		ms.delete(name, recType)
+	}
+}
+
+//Removes the expired records of every RRset in the store, without acquiring the lock.
+func (ms *MemoryStore) sweep() {
+	for name, types := range ms.rrsets {
+		for recType := range types {
+			ms.retain(name, recType, func(lrr LocalResource) bool { return !lrr.HasExpired() })
+		}
+	}
+	ms.lastSweep = time.Now().UTC()
+}
+
+//Invokes the callback for every record in the store, including expired records. Iteration stops when the callback returns false.
+func (ms *MemoryStore) Iterate(callback func(record LocalResource) bool) {
+	for _, lrr := range ms.Records() {
+		if !callback(lrr) {
+			break
+		}
+	}
+}
+
+//Returns a snapshot of all the records in the store, including expired records that have not been removed yet, sorted in the canonical
+//order of their domain names and then by record type.
+func (ms *MemoryStore) Records() []LocalResource {
+	ms.mutex.RLock()
+	names := make([]string, 0, len(ms.rrsets))
+	for name := range ms.rrsets {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, CompareNames)
+
+	records := make([]LocalResource, 0)
+	for _, name := range names {
+		types := make([]RecordType, 0, len(ms.rrsets[name]))
+		for recType := range ms.rrsets[name] {
+			types = append(types, recType)
+		}
+		slices.Sort(types)
+		for _, recType := range types {
+			records = append(records, ms.rrsets[name][recType]...)
+		}
+	}
+	ms.mutex.RUnlock()
+	return records
+}
+
+//In-memory stores have no backing storage, so there is nothing to flush.
+func (ms *MemoryStore) Flush() error {
+	return nil
+}
+
+//Returns copies of all unexpired cached records matching the given domain name and record type, whose TTL is the time they have left
+//in the cache rather than the TTL they were cached with. Expired records found along the way are removed.
+func (ms *MemoryStore) FindResources(name string, recType RecordType) ([]Resource, bool) {
+	name = Canonicalize(name)
+	resolvedValues := make([]Resource, 0)
+	expired := false
+	ms.mutex.RLock()
+	for _, lrr := range ms.rrsets[name][recType] {
+		if ms.HasRecordExpired(lrr.resource.TTL, lrr.LastModified) {
+			expired = true
+		} else {
+			resource := *lrr.resource
+			resource.TTL = lrr.RemainingTTL()
+			resolvedValues = append(resolvedValues, resource)
+		}
+	}
+	ms.mutex.RUnlock()
+
+	if expired {
+		ms.mutex.Lock()
+		ms.retain(name, recType, func(lrr LocalResource) bool { return !ms.HasRecordExpired(lrr.resource.TTL, lrr.LastModified) })
+		ms.mutex.Unlock()
+	}
+
+	if len(resolvedValues) == 0 {
+		return resolvedValues, false
+	}
+
+	return resolvedValues, true
+}
+
+//Checks if the local resource is expired and returns true if it is and false if it has not expired.
+func (ms *MemoryStore) HasRecordExpired(ttl uint32, LastModified time.Time) bool {
+	TimeSinceLastMod := time.Now().UTC().Sub(LastModified)
+	TimeInSeconds := TimeSinceLastMod.Seconds()
+	if TimeInSeconds > float64(ttl) {
+		return true
+	} else {
+		return false
+	}
+}
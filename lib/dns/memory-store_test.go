@@ -0,0 +1,106 @@
+package dns
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+//Adds the record, given in presentation format as "name ttl class type data", to the store as if it had been cached at the given time.
+func addCachedAt(ms *MemoryStore, record string, cachedAt time.Time) {
+	fields := strings.SplitN(record, WHITESPACE, 5)
+	ttl, _ := strconv.ParseUint(fields[1], 10, 32)
+	localResource := ms.NewLocalResource(fields[0], uint32(ttl), fields[2], fields[3], fields[4], cachedAt.Format(time.RFC3339))
+	ms.mutex.Lock()
+	ms.insert(*localResource)
+	ms.mutex.Unlock()
+}
+
+//Returns the records held by the store, including expired ones, each as "name TYPE data".
+func storedRecords(ms interface{ Records() []LocalResource }) []string {
+	records := make([]string, 0)
+	for _, record := range ms.Records() {
+		records = append(records, record.resource.Name.Value + WHITESPACE + record.resource.Type.String() + WHITESPACE + record.resource.GetData())
+	}
+	return records
+}
+
+func TestMemoryStoreLookups(t *testing.T) {
+	ms := NewMemoryStore()
+	ms.Put([]Resource{
+		*NewResourceRecord("www.example.com.", 300, "IN", "A", "192.0.2.1"),
+		*NewResourceRecord("www.example.com.", 300, "IN", "A", "192.0.2.2"),
+		*NewResourceRecord("www.example.com.", 300, "IN", "AAAA", "2001:db8::1"),
+	})
+	ms.Put([]Resource{*NewResourceRecord("www.example.com.", 300, "IN", "AAAA", "2001:db8::2")})
+
+	testCases := []struct {
+		name string
+		qname string
+		qtype RecordType
+		records []string
+	}{
+		{name: "RRset of several records", qname: "www.example.com.", qtype: TYPE_A, records: []string{"192.0.2.1", "192.0.2.2"}},
+		{name: "name in another letter case", qname: "WWW.Example.COM", qtype: TYPE_A, records: []string{"192.0.2.1", "192.0.2.2"}},
+		{name: "RRset replaced by a later one", qname: "www.example.com.", qtype: TYPE_AAAA, records: []string{"2001:db8::2"}},
+		{name: "type that is not cached", qname: "www.example.com.", qtype: TYPE_TXT},
+		{name: "name that is not cached", qname: "mail.example.com.", qtype: TYPE_A},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			RRs, ok := ms.Get(testCase.qname, testCase.qtype)
+			data := make([]string, 0)
+			for _, rr := range RRs {
+				data = append(data, rr.GetData())
+			}
+			if ok != (len(testCase.records) > 0) || strings.Join(data, ",") != strings.Join(testCase.records, ",") {
+				t.Errorf("found %v (%t), expected %v", data, ok, testCase.records)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreRemovesExpiredRecords(t *testing.T) {
+	ms := NewMemoryStore()
+	longAgo := time.Now().Add(-time.Hour)
+	addCachedAt(ms, "old.example.com. 60 IN A 192.0.2.1", longAgo)
+	addCachedAt(ms, "stale.example.com. 60 IN A 192.0.2.2", longAgo)
+	addCachedAt(ms, "live.example.com. 86400 IN A 192.0.2.3", longAgo)
+
+	_, ok := ms.Get("old.example.com.", TYPE_A)
+	if ok {
+		t.Error("expired RRset was returned")
+	}
+	records := storedRecords(ms)
+	if strings.Join(records, ",") != "live.example.com. A 192.0.2.3,stale.example.com. A 192.0.2.2" {
+		t.Errorf("store holds %v after looking up an expired RRset, expected it to be removed", records)
+	}
+
+	ms.Put([]Resource{*NewResourceRecord("new.example.com.", 300, "IN", "A", "192.0.2.4")})
+	if records := storedRecords(ms); len(records) != 3 {
+		t.Errorf("store holds %v, expected the expired records to be kept until the next sweep", records)
+	}
+	ms.lastSweep = time.Now().Add(-CACHE_SWEEP_INTERVAL)
+	ms.Put([]Resource{*NewResourceRecord("new.example.com.", 300, "IN", "A", "192.0.2.4")})
+	records = storedRecords(ms)
+	if strings.Join(records, ",") != "live.example.com. A 192.0.2.3,new.example.com. A 192.0.2.4" {
+		t.Errorf("store holds %v after a sweep, expected only the unexpired records", records)
+	}
+}
+
+func TestMemoryStoreReturnsRemainingTTL(t *testing.T) {
+	ms := NewMemoryStore()
+	addCachedAt(ms, "www.example.com. 300 IN A 192.0.2.1", time.Now().Add(-100 * time.Second))
+
+	RRs, ok := ms.Get("www.example.com.", TYPE_A)
+	if !ok || RRs[0].TTL < 199 || RRs[0].TTL > 200 {
+		t.Errorf("found %v, expected the record with 200 seconds left", RRs)
+	}
+	for _, record := range ms.Records() {
+		if record.resource.TTL != 300 {
+			t.Errorf("store holds the record with a TTL of %d, expected the TTL it was cached with", record.resource.TTL)
+		}
+	}
+}
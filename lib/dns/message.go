@@ -1,6 +1,7 @@
 package dns
 
 import (
+	"fmt"
 	"strings"
 )
 
@@ -50,6 +51,77 @@ func (msg *Message) Initialize(mt MessageType, MsgId uint16) {
 	msg.compressionMap = make(CompressionMap)
 }
 
+//Returns a new message with the same header and questions, that can be sent independently of the original message.
+func (msg *Message) CloneRequest() *Message {
+	clone := NewMessage(MSG_REQUEST, msg.Header.Identifier)
+	clone.Header = msg.Header
+	clone.Questions = append(clone.Questions, msg.Questions...)
+	clone.Additional = append(clone.Additional, msg.Additional...)
+	return clone
+}
+
+//Adds an OPT record to the additional section of the message (RFC 6891), advertising the given UDP payload size and, if 'dnssecOK'
+//is true, setting the DO bit to ask for the DNSSEC records of the answer (RFC 3225).
+func (msg *Message) SetEDNS(udpSize uint16, dnssecOK bool) {
+	opt := Resource{}
+	opt.Name = DomainName{}
+	opt.Name.Initialize(DOMAIN_LABEL_SEPERATOR)
+	opt.Type = TYPE_OPT
+	opt.Class = ClassType(udpSize)
+	if dnssecOK {
+		opt.TTL = EDNS_DO_BIT
+	}
+	opt.Rdata = &OPTResource{Options: make([]EDNSOption, 0)}
+	msg.Additional = append(msg.Additional, opt)
+	msg.Header.SetAdditionalRecordCount(msg.Header.ArCount + 1)
+}
+
+//Returns the OPT record of the message, if the message carries one.
+func (msg *Message) GetEDNS() (Resource, bool) {
+	OPT_RRs, ok := msg.FindAdditionalRecords(TYPE_OPT)
+	if !ok {
+		return Resource{}, false
+	}
+	return OPT_RRs[0], true
+}
+
+//Returns true if the message carries an OPT record with the DO bit set, asking for DNSSEC records.
+func (msg *Message) IsDNSSECOK() bool {
+	opt, ok := msg.GetEDNS()
+	return ok && opt.TTL & EDNS_DO_BIT != 0
+}
+
+//Pads the message with the Padding option of its OPT record (RFC 7830), so that its packed length is a multiple of the block size as
+//the Block-Length Padding strategy of RFC 8467 recommends. Any padding the message already carries is replaced. A message without an
+//OPT record is left as it is, as is a message too long to be padded within the size limit of a DNS message sent over a stream.
+func (msg *Message) Pad(blockSize int) {
+	var opt *OPTResource
+	for index := range msg.Additional {
+		if msg.Additional[index].Type == TYPE_OPT {
+			opt, _ = msg.Additional[index].Rdata.(*OPTResource)
+			break
+		}
+	}
+	if opt == nil {
+		return
+	}
+
+	options := make([]EDNSOption, 0, len(opt.Options) + 1)
+	for _, option := range opt.Options {
+		if option.Code != EDNS_PADDING_OPTION {
+			options = append(options, option)
+		}
+	}
+	opt.Options = options
+
+	//The Padding option takes 4 octets for its code and length before the padding itself.
+	length := len(msg.Pack()) + 4
+	padding := (blockSize - length % blockSize) % blockSize
+	if length + padding <= STREAM_MESSAGE_SIZE_LIMIT {
+		opt.Options = append(opt.Options, EDNSOption{Code: EDNS_PADDING_OPTION, Data: make([]byte, padding)})
+	}
+}
+
 //Creates a new question and adds it to the DNS Message instance.
 func (msg *Message) NewQuestion(name string, recType RecordType) {
 	question := Question{}
@@ -76,7 +148,7 @@ func (msg *Message) HasQuestion(name string) bool {
 	return ok
 }
 
-// Checks if the message contains an answer record for the given domain name.
+// Checks if the message contains an answer record pointing at the given domain name, through a CNAME record or an alias mode SVCB or HTTPS record.
 func (msg *Message) HasAnswer(name string) bool {
 	name = Canonicalize(name)
 	ok := false
@@ -87,6 +159,12 @@ func (msg *Message) HasAnswer(name string) bool {
 				ok = true
 				break
 			}
+
+			svcb, isSVCB := ans.Rdata.(*SVCBResource)
+			if isSVCB && svcb.IsAlias() && strings.EqualFold(name, svcb.Target.Value) {
+				ok = true
+				break
+			}
 		}
 	}
 
@@ -101,8 +179,18 @@ func (msg *Message) AddAnswers(resources []Resource) {
 	msg.Header.SetAnswerCount(CurrentCount)
 }
 
-//Pack the message as a sequence of octets.
+//Appends the resource records to the authority collection of the Message instance.
+func (msg *Message) AddAuthorities(resources []Resource) {
+	msg.Authoritative = append(msg.Authoritative, resources...)
+	CurrentCount := msg.Header.NsCount
+	CurrentCount += uint16(len(resources))
+	msg.Header.SetNameServerCount(CurrentCount)
+}
+
+//Pack the message as a sequence of octets. Domain names are compressed afresh on every call, so that the message can be packed again
+//after it is changed.
 func (msg *Message) Pack() []byte {
+	msg.compressionMap = make(CompressionMap)
 	buffer := make([]byte, 0)
 	buffer = append(buffer, msg.Header.Pack()...)
 	offset := len(buffer)
@@ -137,14 +225,21 @@ func (msg *Message) Pack() []byte {
 	return buffer
 }
 
-//Unpack the sequence of bytes to a Message instance.
-func (msg *Message) Unpack(response []byte) {
+//Unpack the sequence of bytes to a Message instance. Returns ErrMalformedMessage if the byte stream cannot be parsed as a DNS message.
+func (msg *Message) Unpack(response []byte) error {
+	if len(response) < MESSAGE_HEADER_LENGTH {
+		return fmt.Errorf("%w: message is shorter than its header", ErrMalformedMessage)
+	}
+	var err error
 	offset := 0
 	offset = msg.Header.Unpack(response, offset)
 	if msg.Header.QdCount > 0 {
 		for index := 1; index <= int(msg.Header.QdCount); index++ {
 			question := Question{}
-			offset = question.Unpack(response, offset)
+			offset, err = question.Unpack(response, offset)
+			if err != nil {
+				return err
+			}
 			msg.Questions = append(msg.Questions, question)
 		}
 	}
@@ -152,7 +247,10 @@ func (msg *Message) Unpack(response []byte) {
 	if msg.Header.AnCount > 0 {
 		for index := 1; index <= int(msg.Header.AnCount); index++ {
 			answer := Resource{}
-			offset = answer.Unpack(response, offset)
+			offset, err = answer.Unpack(response, offset)
+			if err != nil {
+				return err
+			}
 			msg.Answers = append(msg.Answers, answer)
 		}
 	}
@@ -160,7 +258,10 @@ func (msg *Message) Unpack(response []byte) {
 	if msg.Header.NsCount > 0 {
 		for index := 1; index <= int(msg.Header.NsCount); index++ {
 			authoritative := Resource{}
-			offset = authoritative.Unpack(response, offset)
+			offset, err = authoritative.Unpack(response, offset)
+			if err != nil {
+				return err
+			}
 			msg.Authoritative = append(msg.Authoritative, authoritative)
 		}
 	}
@@ -168,10 +269,15 @@ func (msg *Message) Unpack(response []byte) {
 	if msg.Header.ArCount > 0 {
 		for index := 1; index <= int(msg.Header.ArCount); index++ {
 			additional := Resource{}
-			offset = additional.Unpack(response, offset)
+			offset, err = additional.Unpack(response, offset)
+			if err != nil {
+				return err
+			}
 			msg.Additional = append(msg.Additional, additional)
 		}
 	}
+
+	return nil
 }
 
 //Returns a string representation of the DNS Message instance. 
@@ -221,6 +327,22 @@ func (msg *Message) IsResponse(request *Message) bool {
 		return false
 	}
 
+	return msg.HasSameQuestions(request)
+}
+
+//Checks if the message carries the same questions (domain name, record type and class) as the given request.
+func (msg *Message) HasSameQuestions(request *Message) bool {
+	if len(msg.Questions) != len(request.Questions) {
+		return false
+	}
+
+	for index, que := range request.Questions {
+		responseQue := msg.Questions[index]
+		if !strings.EqualFold(que.Name.Value, responseQue.Name.Value) || que.Type != responseQue.Type || que.Class != responseQue.Class {
+			return false
+		}
+	}
+
 	return true
 }
 
@@ -276,4 +398,146 @@ func (msg *Message) FindAdditionalRecords(recType RecordType) ([]Resource, bool)
 	} else {
 		return nil, false
 	}
-}
\ No newline at end of file
+}
+
+//Checks if the questions of the message carry exactly the same letter case as the questions in the given request.
+func (msg *Message) HasExactQuestions(request *Message) bool {
+	if len(msg.Questions) != len(request.Questions) {
+		return false
+	}
+
+	for index, que := range request.Questions {
+		if que.Name.RawValue != msg.Questions[index].Name.RawValue {
+			return false
+		}
+	}
+
+	return true
+}
+
+//Returns the RRs from Answer section of DNS message that match the given domain name and record type and fall within the bailiwick of 'zone'.
+func (msg *Message) FindAnswerRecordsFor(name string, recType RecordType, zone string) ([]Resource, bool) {
+	rrValues := make([]Resource, 0)
+	name = Canonicalize(name)
+	answers, _ := msg.FindAnswerRecords(recType)
+	for _, ans := range answers {
+		if strings.EqualFold(name, ans.Name.Value) && IsSubDomain(ans.Name.Value, zone) {
+			rrValues = append(rrValues, ans)
+		}
+	}
+
+	if len(rrValues) > 0 {
+		return rrValues, true
+	} else {
+		return nil, false
+	}
+}
+
+//Returns the RRSIG records from Answer section of DNS message that cover the RRset of the given domain name and record type and fall
+//within the bailiwick of 'zone'.
+func (msg *Message) FindSignaturesFor(name string, recType RecordType, zone string) ([]Resource, bool) {
+	rrValues := make([]Resource, 0)
+	signatures, _ := msg.FindAnswerRecordsFor(name, TYPE_RRSIG, zone)
+	for _, sig := range signatures {
+		rrsig, ok := sig.Rdata.(*RRSIGResource)
+		if ok && rrsig.TypeCovered == recType {
+			rrValues = append(rrValues, sig)
+		}
+	}
+
+	if len(rrValues) > 0 {
+		return rrValues, true
+	} else {
+		return nil, false
+	}
+}
+
+//Returns the RRSIG records from Authoritative section of DNS message that cover the RRset of the given domain name and record type and
+//fall within the bailiwick of 'zone'.
+func (msg *Message) FindAuthoritySignaturesFor(name string, recType RecordType, zone string) ([]Resource, bool) {
+	rrValues := make([]Resource, 0)
+	signatures, _ := msg.FindAuthorityRecords(TYPE_RRSIG)
+	for _, sig := range signatures {
+		rrsig, ok := sig.Rdata.(*RRSIGResource)
+		if ok && rrsig.TypeCovered == recType && strings.EqualFold(Canonicalize(name), sig.Name.Value) && IsSubDomain(sig.Name.Value, zone) {
+			rrValues = append(rrValues, sig)
+		}
+	}
+
+	if len(rrValues) > 0 {
+		return rrValues, true
+	} else {
+		return nil, false
+	}
+}
+
+//Returns the DNAME record from Answer section of DNS message whose owner is an ancestor of 'name' and falls within the bailiwick of 'zone'.
+func (msg *Message) FindDNAMEFor(name string, zone string) (Resource, bool) {
+	name = Canonicalize(name)
+	answers, _ := msg.FindAnswerRecords(TYPE_DNAME)
+	for _, ans := range answers {
+		owner := ans.Name.Value
+		if !strings.EqualFold(name, owner) && IsSubDomain(name, owner) && IsSubDomain(owner, zone) {
+			return ans, true
+		}
+	}
+
+	return Resource{}, false
+}
+
+//Returns the NS records from Authoritative section of DNS message that delegate 'name' to a zone below 'zone', along with the delegated zone.
+//Referrals to zones outside the bailiwick of 'zone', or to zones that do not contain 'name', are ignored.
+func (msg *Message) FindReferral(name string, zone string) ([]Resource, string, bool) {
+	NS_RRs, exists := msg.FindAuthorityRecords(TYPE_NS)
+	if !exists {
+		return nil, "", false
+	}
+
+	zone = Canonicalize(zone)
+	delegatedZone := ""
+	rrValues := make([]Resource, 0)
+	for _, ns := range NS_RRs {
+		owner := ns.Name.Value
+		if delegatedZone == "" {
+			if strings.EqualFold(owner, zone) || !IsSubDomain(owner, zone) || !IsSubDomain(name, owner) {
+				continue
+			}
+			delegatedZone = owner
+		}
+
+		if strings.EqualFold(owner, delegatedZone) {
+			rrValues = append(rrValues, ns)
+		}
+	}
+
+	if len(rrValues) > 0 {
+		return rrValues, delegatedZone, true
+	} else {
+		return nil, "", false
+	}
+}
+
+//Returns the glue records of the given type from Additional section of DNS message. Only records owned by one of the given
+//nameservers and falling within the bailiwick of 'zone' (the zone of the server that sent the message) are returned.
+func (msg *Message) FindGlueRecords(NS_RRs []Resource, recType RecordType, zone string) ([]Resource, bool) {
+	rrValues := make([]Resource, 0)
+	additional, _ := msg.FindAdditionalRecords(recType)
+	for _, add := range additional {
+		if !IsSubDomain(add.Name.Value, zone) {
+			continue
+		}
+
+		for _, ns := range NS_RRs {
+			if strings.EqualFold(add.Name.Value, ns.GetData()) {
+				rrValues = append(rrValues, add)
+				break
+			}
+		}
+	}
+
+	if len(rrValues) > 0 {
+		return rrValues, true
+	} else {
+		return nil, false
+	}
+}
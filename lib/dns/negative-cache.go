@@ -0,0 +1,178 @@
+package dns
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Validated NSEC or NSEC3 records of a zone, from which the resolver answers queries for the names and types they deny without asking
+//the name servers of the zone again (aggressive use of the DNSSEC-validated cache, RFC 8198). It is safe for concurrent use.
+type zoneDenials struct {
+	//Guards the entries against concurrent access.
+	mutex sync.Mutex
+	//NSEC or NSEC3 records of the zone, keyed by their owner name.
+	entries map[string]denialEntry
+	//SOA record of the zone, returned along with the answers formed from the entries.
+	soa denialEntry
+}
+
+//A single NSEC, NSEC3 or SOA record kept in the negative cache.
+type denialEntry struct {
+	//The record followed by the RRSIG records covering it.
+	records []Resource
+	//Time after which the record can no longer be used.
+	expires time.Time
+}
+
+//Keeps the records of a secure denial of existence from the given zone for aggressive negative caching. 'records' holds the SOA,
+//NSEC or NSEC3 records of the denial, each followed by the RRSIG records covering it. The records are kept no longer than the TTL
+//and minimum field of the SOA record allow for negative answers (RFC 8198 - Section 5.4).
+func (resolver *Resolver) cacheDenial(zone string, records []Resource) {
+	zone = Canonicalize(zone)
+	value, _ := resolver.denialCache.LoadOrStore(zone, &zoneDenials{entries: make(map[string]denialEntry)})
+	denials := value.(*zoneDenials)
+	denials.mutex.Lock()
+	defer denials.mutex.Unlock()
+
+	now := time.Now()
+	for owner, entry := range denials.entries {
+		if now.After(entry.expires) {
+			delete(denials.entries, owner)
+		}
+	}
+
+	limit := uint32(0)
+	for _, rr := range records {
+		if soa, ok := rr.Rdata.(*SOAResource); ok && strings.EqualFold(rr.Name.Value, zone) {
+			limit = min(rr.TTL, soa.Minimum)
+		}
+	}
+
+	for start := 0; start < len(records); {
+		end := start + 1
+		for end < len(records) && records[end].Type == TYPE_RRSIG {
+			end++
+		}
+
+		rr := records[start]
+		ttl := rr.TTL
+		if limit > 0 {
+			ttl = min(ttl, limit)
+		}
+		entry := denialEntry{records: append([]Resource{}, records[start: end]...), expires: now.Add(time.Duration(ttl) * time.Second)}
+		if rr.Type == TYPE_NSEC || rr.Type == TYPE_NSEC3 {
+			denials.entries[Canonicalize(rr.Name.Value)] = entry
+		} else if rr.Type == TYPE_SOA && strings.EqualFold(rr.Name.Value, zone) {
+			denials.soa = entry
+		}
+		start = end
+	}
+}
+
+//Answers the query for the given domain name and record type from the NSEC or NSEC3 records cached for the closest enclosing zone,
+//if they prove that the domain name does not exist or has no records of the type. The proof is then added to the response being formed
+//and ErrNXDomain or ErrNoData is returned, while nil is returned if the query must be sent upstream. Proofs relying on opt-out NSEC3
+//records are never used, as the records may hide unsigned delegations.
+func (resolver *Resolver) synthesizeDenial(name string, recType RecordType) error {
+	start := name
+	if recType == TYPE_DS && CountLabels(name) > 0 {
+		start = parentOf(name)
+	}
+
+	for labels := CountLabels(start); labels >= 0; labels-- {
+		zone := LastLabels(start, labels)
+		value, ok := resolver.denialCache.Load(zone)
+		if !ok {
+			continue
+		}
+
+		denials := value.(*zoneDenials)
+		authority, err := denials.prove(name, recType)
+		if err == nil {
+			return nil
+		}
+
+		resolver.response.AddAuthorities(authority)
+		resolver.Log(fmt.Sprintf("Negative answer for %s type records of %s formed from the NSEC records cached for %s.", recType.String(), name, zone))
+		return fmt.Errorf("%w: %s, as per the NSEC records cached for %s", err, name, zone)
+	}
+	return nil
+}
+
+//Checks whether the unexpired records of the zone prove that the domain name does not exist or has no records of the type. Returns
+//ErrNXDomain or ErrNoData along with the records of the proof and the SOA record of the zone, with their TTLs counted down, or nil
+//if there is no secure proof.
+func (denials *zoneDenials) prove(name string, recType RecordType) ([]Resource, error) {
+	denials.mutex.Lock()
+	defer denials.mutex.Unlock()
+
+	now := time.Now()
+	proof, ok := proveDenial(name, recType, denials.unexpired(now))
+	if !ok || proof.status != SECURITY_SECURE {
+		return nil, nil
+	}
+
+	authority := make([]Resource, 0)
+	if now.Before(denials.soa.expires) {
+		authority = append(authority, denials.soa.remaining(now)...)
+	}
+	for _, rr := range proof.records {
+		authority = append(authority, denials.entries[Canonicalize(rr.Name.Value)].remaining(now)...)
+	}
+
+	if proof.rcode == RC_NXDOMAIN {
+		return authority, ErrNXDomain
+	}
+	return authority, ErrNoData
+}
+
+//Returns the NSEC or NSEC3 records cached for the zones enclosing 'wildcard' that prove that 'name' does not exist, along with their RRSIG
+//records and the security status of an RRset of 'name' served from the cache that was expanded from the wildcard. The RRset is bogus
+//if none of the cached records prove it.
+func (resolver *Resolver) cachedWildcardProof(name string, wildcard string) ([]Resource, SecurityStatus, error) {
+	encloser := parentOf(wildcard)
+	for labels := CountLabels(encloser); labels >= 0; labels-- {
+		value, ok := resolver.denialCache.Load(LastLabels(encloser, labels))
+		if !ok {
+			continue
+		}
+
+		denials := value.(*zoneDenials)
+		denials.mutex.Lock()
+		now := time.Now()
+		proof, ok := proveWildcardExpansion(name, wildcard, denials.unexpired(now))
+		records := make([]Resource, 0)
+		for _, rr := range proof.records {
+			records = append(records, denials.entries[Canonicalize(rr.Name.Value)].remaining(now)...)
+		}
+		denials.mutex.Unlock()
+		if ok {
+			return records, proof.status, nil
+		}
+	}
+	return nil, SECURITY_BOGUS, fmt.Errorf("no cached NSEC or NSEC3 record proves that %s does not exist, which its answer expanded from %s relies on", name, wildcard)
+}
+
+//Returns the NSEC or NSEC3 records of the zone that have not expired. The caller must hold the mutex.
+func (denials *zoneDenials) unexpired(now time.Time) []Resource {
+	records := make([]Resource, 0, len(denials.entries))
+	for _, entry := range denials.entries {
+		if now.Before(entry.expires) {
+			records = append(records, entry.records[0])
+		}
+	}
+	return records
+}
+
+//Returns the records of the entry with their TTLs set to the time left before the entry expires.
+func (entry denialEntry) remaining(now time.Time) []Resource {
+	ttl := uint32(entry.expires.Sub(now).Seconds())
+	records := make([]Resource, 0, len(entry.records))
+	for _, rr := range entry.records {
+		rr.TTL = ttl
+		records = append(records, rr)
+	}
+	return records
+}
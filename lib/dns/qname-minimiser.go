@@ -0,0 +1,60 @@
+package dns
+
+//Tracks the progress of QNAME minimisation (RFC 9156) while a domain name is being resolved iteratively.
+//Each query reveals one label more than the zone being queried, using an NS query, until the complete domain name is reached.
+type qnameMinimiser struct {
+	//Complete domain name being resolved.
+	name string
+	//Record type being resolved.
+	recType RecordType
+	//Number of labels of the domain name revealed by the next minimised query.
+	labels int
+	//Set when minimisation is switched off, either by configuration or after a server failed to answer a minimised query.
+	disabled bool
+	//Set when the last question handed out was a minimised one.
+	minimised bool
+}
+
+//Creates a new QNAME minimiser for the given domain name and record type.
+func newQnameMinimiser(name string, recType RecordType, enabled bool) *qnameMinimiser {
+	qm := qnameMinimiser{}
+	qm.name = Canonicalize(name)
+	qm.recType = recType
+	qm.disabled = !enabled
+	return &qm
+}
+
+//Returns the domain name and record type to be queried next from a name server authoritative for 'zone'.
+func (qm *qnameMinimiser) Question(zone string) (string, RecordType) {
+	totalLabels := CountLabels(qm.name)
+	zoneLabels := CountLabels(zone)
+	if qm.labels <= zoneLabels {
+		qm.labels = zoneLabels + 1
+	}
+
+	if qm.disabled || qm.labels >= totalLabels {
+		qm.minimised = false
+		return qm.name, qm.recType
+	}
+
+	qm.minimised = true
+	return LastLabels(qm.name, qm.labels), TYPE_NS
+}
+
+//Returns true if the last question handed out was minimised.
+func (qm *qnameMinimiser) Minimised() bool {
+	return qm.minimised
+}
+
+//Moves the minimisation forward after a minimised query that did not lead to a referral. A NOERROR response means the name
+//exists without being a zone cut, so one more label is revealed to the same server. Any other response code is treated
+//as a server that cannot handle minimised queries, and the complete domain name is sent from then on.
+func (qm *qnameMinimiser) Advance(rcode ResponseCode) bool {
+	if rcode == RC_NOERROR {
+		qm.labels++
+		return true
+	}
+
+	qm.disabled = true
+	return false
+}
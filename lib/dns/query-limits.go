@@ -0,0 +1,43 @@
+package dns
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+//Work limits of a single client query, shared by every lookup performed to answer it, including the name server lookups
+//forked from it, so that referral loops and misbehaving zones cannot keep the resolver busy forever.
+type queryLimits struct {
+	//Number of queries sent upstream so far.
+	queries atomic.Int32
+	//Time by which the client query must be answered.
+	deadline time.Time
+}
+
+//Creates the work limits for a client query starting now.
+func newQueryLimits() *queryLimits {
+	ql := queryLimits{}
+	ql.deadline = time.Now().Add(MAX_RESOLUTION_TIME)
+	return &ql
+}
+
+//Accounts for one more query sent upstream. Returns an error if the client query has run out of queries or time.
+func (ql *queryLimits) Spend() error {
+	if time.Now().After(ql.deadline) {
+		return fmt.Errorf("%w: no answer within %s", ErrResolutionTimeout, MAX_RESOLUTION_TIME)
+	}
+
+	if ql.queries.Add(1) > MAX_UPSTREAM_QUERIES {
+		return fmt.Errorf("%w: more than %d queries sent upstream", ErrQueryLimit, MAX_UPSTREAM_QUERIES)
+	}
+	return nil
+}
+
+//Returns the given deadline, brought forward to the deadline of the client query if that comes earlier.
+func (ql *queryLimits) Deadline(deadline time.Time) time.Time {
+	if ql.deadline.Before(deadline) {
+		return ql.deadline
+	}
+	return deadline
+}
@@ -31,15 +31,31 @@ func (que *Question) Pack(compressionMap CompressionMap, offset int) []byte {
 	return buffer
 }
 
-//Unpacks a stream of bytes to a Question instance.
-func (que *Question) Unpack(buffer []byte, offset int) int {
-	offset = que.Name.Unpack(buffer, offset)
+//Unpacks a stream of bytes to a Question instance. Returns ErrMalformedMessage if the question does not fit in the byte stream or its
+//domain name cannot be parsed.
+func (que *Question) Unpack(buffer []byte, offset int) (int, error) {
+	offset, err := que.Name.Unpack(buffer, offset)
+	if err != nil {
+		return offset, err
+	} else if offset + 4 > len(buffer) {
+		return offset, fmt.Errorf("%w: question for %s runs past the end of the message", ErrMalformedMessage, que.Name.Value)
+	}
 	que.Type = RecordType(UnpackUInt16(buffer[offset: offset + 2]))
 	que.Class = ClassType(UnpackUInt16(buffer[offset + 2: offset + 4]))
-	return offset + 4
+	return offset + 4, nil
 }
 
 //Returns the string representation of DNS Question instance.
 func (que *Question) String() string {
 	return fmt.Sprintf("%s \t %s \t %s\n", que.Name.String(), que.Class.String(), que.Type.String())
-}
\ No newline at end of file
+}
+
+//Randomizes the letter case of the domain name being queried, as an anti-spoofing measure (DNS 0x20 encoding).
+func (que *Question) RandomizeCase() {
+	que.Name.RawValue = RandomizeCase(que.Name.Value)
+}
+
+//Restores the domain name being queried to its canonical, lower case form.
+func (que *Question) ResetCase() {
+	que.Name.RawValue = que.Name.Value
+}
@@ -1,5 +1,11 @@
 package dns
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 //Identifies a protocol family or instance of a protocol.
 type ClassType uint16
 
@@ -27,12 +33,36 @@ func (rt RecordType) String() string {
 		return "AAAA"
 	case TYPE_CNAME:
 		return "CNAME"
+	case TYPE_SOA:
+		return "SOA"
 	case TYPE_NS:
 		return "NS"
 	case TYPE_TXT:
 		return "TXT"
+	case TYPE_DNAME:
+		return "DNAME"
+	case TYPE_SVCB:
+		return "SVCB"
+	case TYPE_HTTPS:
+		return "HTTPS"
+	case TYPE_CAA:
+		return "CAA"
+	case TYPE_DS:
+		return "DS"
+	case TYPE_RRSIG:
+		return "RRSIG"
+	case TYPE_NSEC:
+		return "NSEC"
+	case TYPE_DNSKEY:
+		return "DNSKEY"
+	case TYPE_NSEC3:
+		return "NSEC3"
+	case TYPE_NSEC3PARAM:
+		return "NSEC3PARAM"
+	case TYPE_OPT:
+		return "OPT"
 	default:
-		return ""
+		return fmt.Sprintf("TYPE%d", uint16(rt))
 	}
 }
 
@@ -68,6 +98,24 @@ func (recTypes RecordTypes) GetRecordType(key string) RecordType {
 	return recType
 }
 
+//Parses the presentation format of a record type, accepting the generic "TYPEnnn" format of RFC 3597 for any type.
+//Returns false if the value is not a record type.
+func ParseRecordType(value string) (RecordType, bool) {
+	value = strings.ToUpper(value)
+	recType, ok := AllowedRRTypes[value]
+	if ok {
+		return recType, true
+	}
+
+	if strings.HasPrefix(value, "TYPE") {
+		number, err := strconv.ParseUint(strings.TrimPrefix(value, "TYPE"), 10, 16)
+		if err == nil {
+			return RecordType(number), true
+		}
+	}
+	return 0, false
+}
+
 //Maps a class type string with its enumerated value.
 type ClassTypes map[string]ClassType
 
@@ -1,76 +1,306 @@
 package dns
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"math/rand/v2"
+	"net"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+//Counts the datagrams discarded by the resolver because they did not match the query sent upstream.
+type DiscardCounters struct {
+	//Datagrams received from an address or port other than the one queried.
+	SourceMismatch atomic.Uint64
+	//Datagrams that are not responses or carry a message ID other than the one queried.
+	IdMismatch atomic.Uint64
+	//Responses whose question does not match the question queried.
+	QuestionMismatch atomic.Uint64
+	//Responses whose question does not preserve the randomized letter case of the question queried.
+	CaseMismatch atomic.Uint64
+	//Datagrams that could not be parsed as a DNS message.
+	Malformed atomic.Uint64
+}
+
+//Returns the total number of datagrams discarded.
+func (dc *DiscardCounters) Total() uint64 {
+	return dc.SourceMismatch.Load() + dc.IdMismatch.Load() + dc.QuestionMismatch.Load() + dc.CaseMismatch.Load() + dc.Malformed.Load()
+}
+
+//Returns the string representation of the discard counters.
+func (dc *DiscardCounters) String() string {
+	return fmt.Sprintf("source mismatch: %d, ID mismatch: %d, question mismatch: %d, case mismatch: %d, malformed: %d", dc.SourceMismatch.Load(), dc.IdMismatch.Load(), dc.QuestionMismatch.Load(), dc.CaseMismatch.Load(), dc.Malformed.Load())
+}
+
 // Structure to represent a DNS Resolver.
 type Resolver struct {
 	//References the BIND file containing the DNS root server details.
-	RootServers BindFile
-	//References the BIND file containing all the cached resource records.
-	Cache BindFile
+	RootServers *BindFile
+	//References the cache store containing all the cached resource records.
+	Cache CacheStore
 	//Logger to be used to generate logs.
 	Logger *log.Logger
 	//References the DNS response being formed during domain name resolution.
 	response *Message
 	//Flag to enable or disable Trace logs
 	traceLogs bool
+	//Counters of the upstream responses discarded for not matching the query sent.
+	Discarded *DiscardCounters
+	//Flag to enable or disable randomization of the letter case of the domain names queried upstream (DNS 0x20 encoding).
+	caseRandomization bool
+	//Name servers found not to preserve the letter case of the question in their responses.
+	caseInsensitiveServers *sync.Map
+	//Flag to enable or disable QNAME minimisation (RFC 9156).
+	qnameMinimisation bool
+	//Round-trip time and failure statistics of the name servers queried so far.
+	Infra *InfraCache
+	//Name server records and glue addresses learnt from referrals. They are not authoritative, so they are kept apart from Cache,
+	//where they would be served as answers and replace the authoritative records of the same names.
+	Delegations *MemoryStore
+	//Tracks the name server lookups still running in the background.
+	pending *sync.WaitGroup
+	//IP version(s) used to reach the name servers - ipv4, ipv6 or both.
+	transport string
+	//Port number the name servers are queried on.
+	port int
+	//Work limits of the client query being answered, shared with the name server lookups forked from it.
+	limits *queryLimits
+	//Number of CNAME records followed so far while resolving the domain name.
+	cnameChain int
+	//Nesting depth of name server lookups, zero for the client query itself.
+	depth int
+	//Flag to enable or disable DNSSEC validation of the answers received.
+	dnssec bool
+	//Flag to skip DNSSEC validation for the query being answered, as asked by a client setting the CD bit.
+	checkingDisabled bool
+	//DS records of the keys trusted without validation, from which every chain of trust starts.
+	trustAnchors []Resource
+	//Authenticated keys and security status of the zones validated so far.
+	zoneKeyCache *sync.Map
+	//Trust anchor file whose keys are followed across key rollovers (RFC 5011), if the trust anchors were taken from one.
+	anchorFile *TrustAnchorFile
+	//Validated NSEC and NSEC3 records of the zones validated so far, used to answer negative queries without asking upstream (RFC 8198).
+	denialCache *sync.Map
+	//Forwarder the queries are sent to instead of being resolved iteratively, if forwarding is enabled.
+	forwarder *Forwarder
+	//Exchanger the messages are exchanged with the name servers through.
+	exchanger Exchanger
+	//Ports the queries for specific name servers are routed to, keyed by the IP address of the server.
+	serverPorts *sync.Map
+}
+
+//Outcome of resolving the addresses of a single name server.
+type nameServerAddresses struct {
+	//Domain name of the name server.
+	name string
+	//IP addresses of the name server.
+	addresses []string
+	//Error that occurred while resolving the name server, if any.
+	err error
+}
+
+//Response received from a name server while racing name servers of both IP versions.
+type serverResponse struct {
+	//Response received, or nil if the server did not respond.
+	response *Message
+	//IP address of the name server queried.
+	server string
+}
+
+// Enables or disables QNAME minimisation, which reveals only one more label of the domain name to each zone queried.
+func (resolver *Resolver) SetQnameMinimisation(value bool) {
+	resolver.qnameMinimisation = value
+}
+
+// Sets the IP version(s) used to reach name servers: ipv4, ipv6 or both. With both, servers of the two IP versions are raced.
+func (resolver *Resolver) SetTransport(value string) error {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value != TRANSPORT_IPv4 && value != TRANSPORT_IPv6 && value != TRANSPORT_BOTH {
+		return ErrInvalidTransport
+	}
+	resolver.transport = value
+	return nil
+}
+
+// Sets the port number the name servers are queried on.
+func (resolver *Resolver) SetUpstreamPort(port int) {
+	resolver.port = port
+}
+
+// Routes the queries for the name server with the given IP address to the given port instead of the upstream port, such as to reach a
+// server listening on a custom port.
+func (resolver *Resolver) SetServerPort(server string, port int) {
+	resolver.serverPorts.Store(server, port)
+}
+
+// Enables or disables DNSSEC validation. When enabled, the DO bit is set on every query sent upstream, answers are validated from
+// the trust anchors down and bogus answers are returned as SERVFAIL, while secure answers are returned with the AD flag set.
+func (resolver *Resolver) SetDNSSECValidation(value bool) {
+	resolver.dnssec = value
+}
+
+// Disables DNSSEC validation for the queries answered from now on, as a client does by setting the CD bit. The DNSSEC records
+// are still fetched and returned, but bogus answers are returned as they are.
+func (resolver *Resolver) SetCheckingDisabled(value bool) {
+	resolver.checkingDisabled = value
+}
+
+// Replaces the trust anchors DNSSEC validation starts from, which are the root zone keys by default. Anchors can be given as DS
+// records or as DNSKEY records, which are turned into DS records, and can be set for any zone.
+func (resolver *Resolver) SetTrustAnchors(anchors []Resource) error {
+	trustAnchors := make([]Resource, 0, len(anchors))
+	for _, anchor := range anchors {
+		record, err := trustAnchorDS(anchor)
+		if err != nil {
+			return err
+		}
+		trustAnchors = append(trustAnchors, record)
+	}
+
+	resolver.trustAnchors = trustAnchors
+	resolver.anchorFile = nil
+	resolver.zoneKeyCache = &sync.Map{}
+	resolver.denialCache = &sync.Map{}
+	return nil
+}
+
+// Takes the trust anchors DNSSEC validation starts from out of the trust anchor file. The keys of the zones with anchors in the file
+// are then followed across key rollovers as per RFC 5011, every time they are authenticated, and the file is updated accordingly.
+func (resolver *Resolver) SetTrustAnchorFile(file *TrustAnchorFile) {
+	resolver.trustAnchors = make([]Resource, 0)
+	resolver.anchorFile = file
+	resolver.zoneKeyCache = &sync.Map{}
+	resolver.denialCache = &sync.Map{}
+}
+
+// Forwards every query to the upstreams of the forwarder over DNS-over-TLS or DNS-over-HTTPS instead of resolving it iteratively from the root servers.
+// The answers are still cached and, with DNSSEC enabled, validated. Passing nil goes back to iterative resolution.
+func (resolver *Resolver) SetForwarder(forwarder *Forwarder) {
+	resolver.forwarder = forwarder
+}
+
+// Enables or disables randomization of the letter case of domain names queried upstream (DNS 0x20 encoding).
+func (resolver *Resolver) SetCaseRandomization(value bool) {
+	resolver.caseRandomization = value
 }
 
 // Queries the DNS server and fetches the 't' type record for 'name'.
 func (resolver *Resolver) Resolve(name string, t RecordType) {
+	response := resolver.Query(name, t)
+	fmt.Println(response.String())
+}
+
+// Resolves the 't' type record for 'name' and returns the response formed for the client.
+func (resolver *Resolver) Query(name string, t RecordType) *Message {
+	resolver.startQuery(name, t)
+	_, err := resolver.resolve(name, t)
+	if err != nil {
+		resolver.Log(err.Error())
+		resolver.response.Header.SetResponseCode(responseCodeFor(err))
+		if !errors.Is(err, ErrNXDomain) && !errors.Is(err, ErrNoData) {
+			resolver.response.Header.Authenticated = false
+		}
+	}
+	return resolver.response
+}
+
+// Answers the query a client sent in the request message and returns the response to send back to it. The query is resolved on a copy of
+// the resolver that shares its configuration and caches, so that the queries of several clients can be answered at once. A request setting
+// the CD bit is answered without DNSSEC validation. The AD bit and the DNSSEC records of the answer are only returned to clients that set the
+// DO bit (RFC 3225) or, for the AD bit, the AD bit of the request (RFC 6840 - Section 5.8).
+func (resolver *Resolver) Answer(request *Message) *Message {
+	response := NewMessage(MSG_RESOLVER_RESPONSE, request.Header.Identifier)
+	response.Header.SetRecursionDesired(request.Header.RecursionDesired)
+	response.Header.CheckingDisabled = request.Header.CheckingDisabled
+	response.Questions = append(response.Questions, request.Questions...)
+	response.Header.SetQuestionCount(uint16(len(response.Questions)))
+	_, hasEDNS := request.GetEDNS()
+	if hasEDNS {
+		response.SetEDNS(UDP_MESSAGE_SIZE_LIMIT, request.IsDNSSECOK())
+	}
+
+	if request.Header.IsResponse || len(request.Questions) != 1 || request.Questions[0].Type == TYPE_OPT {
+		response.Header.SetResponseCode(RC_FORMERR)
+		return response
+	} else if request.Header.Opcode != OPCODE_QUERY || request.Questions[0].Class != CLASS_IN || !resolver.IsAllowed(request.Questions[0].Type.String()) {
+		response.Header.SetResponseCode(RC_NOTIMP)
+		return response
+	}
+
+	question := request.Questions[0]
+	client := *resolver
+	client.checkingDisabled = resolver.checkingDisabled || request.Header.CheckingDisabled
+	answer := client.Query(question.Name.Value, question.Type)
+	response.Header.SetResponseCode(answer.Header.Rcode)
+	response.Header.Authenticated = answer.Header.Authenticated && (request.IsDNSSECOK() || request.Header.Authenticated)
+	if request.IsDNSSECOK() {
+		response.AddAnswers(answer.Answers)
+		response.AddAuthorities(answer.Authoritative)
+	} else {
+		response.AddAnswers(withoutDNSSECRecords(answer.Answers, question.Type))
+		response.AddAuthorities(withoutDNSSECRecords(answer.Authoritative, question.Type))
+	}
+	return response
+}
+
+// Returns the resource records other than the RRSIG, NSEC and NSEC3 records that were not asked for, which are only returned to clients
+// that set the DO bit.
+func withoutDNSSECRecords(records []Resource, recType RecordType) []Resource {
+	filtered := make([]Resource, 0, len(records))
+	for _, rr := range records {
+		if rr.Type == recType || (rr.Type != TYPE_RRSIG && rr.Type != TYPE_NSEC && rr.Type != TYPE_NSEC3) {
+			filtered = append(filtered, rr)
+		}
+	}
+	return filtered
+}
+
+// Prepares the resolver to answer a new client query for the 't' type record of 'name', with a fresh response and work limits.
+func (resolver *Resolver) startQuery(name string, t RecordType) {
 	MsgId := Id()
 	resolver.response = NewMessage(MSG_RESOLVER_RESPONSE, MsgId)
 	resolver.response.NewQuestion(name, t)
-	if t == TYPE_A {
-		_, err := resolver.resolveA(name)
-		if err != nil {
-			resolver.Log(err.Error())
-			resolver.response.Header.SetResponseCode(RC_SERVFAIL)
-			fmt.Println(resolver.response.String())
-			return
-		}
+	resolver.limits = newQueryLimits()
+	resolver.cnameChain = 0
+	resolver.resetSecurity()
+}
 
-		fmt.Println(resolver.response.String())
-	} else if t == TYPE_AAAA {
-		_, err := resolver.resolveAAAA(name)
-		if err != nil {
-			resolver.Log(err.Error())
-			resolver.response.Header.SetResponseCode(RC_SERVFAIL)
-			fmt.Println(resolver.response.String())
-			return
+// Finds the CAA records relevant to issuing certificates for the given domain name, by climbing the DNS tree as per RFC 8659 - Section 3.
+// The CAA records of the domain name itself are looked up first, following CNAME records, and then those of each parent domain up to,
+// but not including, the root. Returns the first non-empty set of CAA records found along with the domain name that holds them.
+// An empty set with no error means no CAA records exist and any certification authority may issue. Any other lookup failure is
+// returned as an error, so that the caller does not issue on incomplete information.
+func (resolver *Resolver) LookupCAA(name string) ([]Resource, string, error) {
+	name = Canonicalize(name)
+	for labels := CountLabels(name); labels > 0; labels-- {
+		domain := LastLabels(name, labels)
+		resolver.startQuery(domain, TYPE_CAA)
+		RRs, err := resolver.resolve(domain, TYPE_CAA)
+		if errors.Is(err, ErrNoData) || errors.Is(err, ErrNXDomain) {
+			continue
+		} else if err != nil {
+			return nil, "", err
 		}
 
-		fmt.Println(resolver.response.String())
-	} else if t == TYPE_TXT {
-		_, err := resolver.resolveTXT(name)
-		if err != nil {
-			resolver.Log(err.Error())
-			resolver.response.Header.SetResponseCode(RC_SERVFAIL)
-			fmt.Println(resolver.response.String())
-			return
+		CAA_RRs := make([]Resource, 0)
+		for _, rr := range RRs {
+			if rr.Type == TYPE_CAA {
+				CAA_RRs = append(CAA_RRs, rr)
+			}
 		}
 
-		fmt.Println(resolver.response.String())
-	} else if t == TYPE_CNAME {
-		_, err := resolver.resolveCNAME(name)
-		if err != nil {
-			resolver.Log(err.Error())
-			resolver.response.Header.SetResponseCode(RC_SERVFAIL)
-			fmt.Println(resolver.response.String())
-			return
+		if len(CAA_RRs) > 0 {
+			resolver.Log(fmt.Sprintf("CAA records relevant to %s found at %s.", name, domain))
+			return CAA_RRs, domain, nil
 		}
-
-		fmt.Println(resolver.response.String())
-	} else {
-		resolver.Log(ErrInvalidRecordType.Error())
-		resolver.response.Header.SetResponseCode(RC_NOTIMP)
-		fmt.Println(resolver.response.String())
 	}
+
+	return make([]Resource, 0), "", nil
 }
 
 // Adds the given resource records to resolver response message if the domain name given is present in the Question.
@@ -84,198 +314,285 @@ func (resolver *Resolver) addToResolverResponse(name string, resources []Resourc
 
 //Adds the given resource records to resolver cache.
 func (resolver *Resolver) addToCache(resources []Resource) {
-	for _, RR := range resources {
-		resolver.Cache.Add(RR.Name.Value, RR.TTL, RR.Class.String(), RR.Type.String(), RR.GetData())
-	}
+	resolver.Cache.Put(resources)
 }
 
-// Resolves the given domain name and returns its A resource records.
-func (resolver *Resolver) resolveA(name string) ([]Resource, error) {
-	cacheRecords, ok := resolver.Cache.Resolve(name, TYPE_A)
+// Resolves the given domain name iteratively, starting at the closest zone whose name servers are cached, and returns its
+// resource records of the given type. CNAME records found for the domain name are followed, unless CNAME records are themselves
+// being resolved, and every record learnt along the way is cached.
+func (resolver *Resolver) resolve(name string, recType RecordType) ([]Resource, error) {
+	cacheRecords, ok := resolveFromCache(resolver.Cache, name, recType)
 	if ok {
-		resolver.addToResolverResponse(name, cacheRecords)
-		resolver.Log(fmt.Sprintf("A type records for %s have been served from the cache.", name))
-		return cacheRecords, nil
+		cacheRecords, err := resolver.secureCachedRecords(cacheRecords)
+		if err == nil {
+			resolver.addToResolverResponse(name, cacheRecords)
+			resolver.Log(fmt.Sprintf("%s type records for %s have been served from the cache.", recType.String(), name))
+			return resolver.followAlias(name, recType, cacheRecords)
+		}
+		//Cached records that fail validation are not served, and are resolved again from the name servers instead.
+		resolver.Log(fmt.Sprintf("Cached %s type records for %s are not served: %s", recType.String(), name, err.Error()))
+	}
+
+	if resolver.isValidating() {
+		err := resolver.synthesizeDenial(name, recType)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	nameserver := resolver.getRootServer(TYPE_A)
-	request := NewMessage(MSG_REQUEST, resolver.response.Header.Identifier)
-	request.NewQuestion(name, TYPE_A)
+	startName := name
+	if recType == TYPE_DS && CountLabels(name) > 0 {
+		// DS records are served by the parent zone (RFC 4035 - Section 3.1.4.1), so the resolution starts above the zone itself.
+		startName = LastLabels(name, CountLabels(name) - 1)
+	}
+
+	zone, nameservers := DOMAIN_LABEL_SEPERATOR, make([]string, 0)
+	if resolver.forwarder == nil {
+		zone, nameservers = resolver.getClosestNameServers(startName)
+	}
+	minimiser := newQnameMinimiser(name, recType, resolver.qnameMinimisation && resolver.forwarder == nil)
+	referrals := 0
 	for {
-		response := resolver.getResponse(request, nameserver)
-		if response.Header.AnCount > 0 {
-			CNAME_RRs, exists := response.FindAnswerRecords(TYPE_CNAME)
-			if exists {
-				resolver.addToResolverResponse(name, CNAME_RRs)
-				resolver.addToCache(CNAME_RRs)
-				return resolver.resolveA(CNAME_RRs[0].GetData())
+		queryName, queryType := minimiser.Question(zone)
+		request := NewMessage(MSG_REQUEST, resolver.response.Header.Identifier)
+		request.NewQuestion(queryName, queryType)
+		if resolver.dnssec {
+			request.SetEDNS(UDP_MESSAGE_SIZE_LIMIT, true)
+		}
+		response, nameserver, err := resolver.sendQuery(request, nameservers)
+		if err != nil {
+			return nil, err
+		}
+
+		if minimiser.Minimised() {
+			_, _, isReferral := response.FindReferral(name, zone)
+			if !isReferral {
+				resolver.advanceMinimiser(minimiser, response, nameserver)
+				continue
 			}
+		}
 
-			A_RRs, _ := response.FindAnswerRecords(TYPE_A)
-			resolver.addToResolverResponse(name, A_RRs)
-			resolver.addToCache(A_RRs)
-			return  A_RRs, nil
+		err = resolver.checkResponseCode(response, name, recType, zone, nameserver)
+		if err != nil {
+			return nil, err
 		}
+		if response.Header.AnCount > 0 {
+			DNAME_RR, exists := response.FindDNAMEFor(name, zone)
+			if exists {
+				DNAME_RRs, err := resolver.secureRRSet(response, DNAME_RR.Name.Value, TYPE_DNAME, zone, []Resource{DNAME_RR})
+				if err != nil {
+					return nil, err
+				}
+				return resolver.followDNAME(name, recType, DNAME_RRs)
+			}
 
-		if response.Header.NsCount > 0 && response.Header.ArCount == 0 {
-			NS_RRs, Exists := response.FindAuthorityRecords(TYPE_NS)
-			if !Exists {
-				return nil, ErrNameServerFetch
+			if recType != TYPE_CNAME {
+				CNAME_RRs, exists := response.FindAnswerRecordsFor(name, TYPE_CNAME, zone)
+				if exists {
+					CNAME_RRs, err := resolver.secureRRSet(response, name, TYPE_CNAME, zone, CNAME_RRs)
+					if err != nil {
+						return nil, err
+					}
+					resolver.addToResolverResponse(name, CNAME_RRs)
+					resolver.addToCache(CNAME_RRs)
+					err = resolver.followCNAME(name)
+					if err != nil {
+						return nil, err
+					}
+					return resolver.resolve(CNAME_RRs[0].GetData(), recType)
+				}
 			}
-			NS_IPs, err := resolver.resolveA(NS_RRs[0].GetData())
-			if err != nil {
-				return nil, err
+
+			RRs, exists := response.FindAnswerRecordsFor(name, recType, zone)
+			if exists {
+				RRs, err := resolver.secureRRSet(response, name, recType, zone, RRs)
+				if err != nil {
+					return nil, err
+				}
+				resolver.addToResolverResponse(name, RRs)
+				resolver.addToCache(RRs)
+				return resolver.followAlias(name, recType, RRs)
 			}
+		}
+
+		NS_RRs, delegatedZone, Exists := response.FindReferral(name, zone)
+		if !Exists {
+			return nil, resolver.checkNoData(response, name, recType, zone, nameserver)
+		}
 
-			nameserver = NS_IPs[0].GetData()
+		referrals++
+		if referrals > MAX_REFERRALS {
+			return nil, fmt.Errorf("%w: more than %d referrals followed while resolving %s", ErrReferralLimit, MAX_REFERRALS, name)
+		}
+
+		resolver.Delegations.Put(NS_RRs)
+		Glue_RRs, Exists := resolver.findGlue(response, NS_RRs, zone)
+		if Exists {
+			resolver.Delegations.Put(Glue_RRs)
+			nameservers = getAddresses(Glue_RRs)
 		} else {
-			Add_RRs, Exists := response.FindAdditionalRecords(TYPE_A)
-			if !Exists {
-				return nil, ErrNameServerFetch
+			NS_IPs, err := resolver.resolveNameServers(NS_RRs)
+			if err != nil {
+				return nil, err
 			}
 
-			nameserver = Add_RRs[0].GetData()
+			nameservers = NS_IPs
 		}
+		zone = delegatedZone
 	}
 }
 
-// Resolves the given domain name and returns its AAAA resource records.
-func (resolver *Resolver) resolveAAAA(name string) ([]Resource, error) {
-	cacheRecords, ok := resolver.Cache.Resolve(name, TYPE_AAAA)
-	if ok {
-		resolver.addToResolverResponse(name, cacheRecords)
-		resolver.Log(fmt.Sprintf("AAAA type records for %s have been served from the cache.", name))
-		return cacheRecords, nil
+// Checks the response code of a response received from a name server of 'zone' for the given domain name and record type. An authoritative
+// NXDOMAIN ends the resolution with ErrNXDomain, and the records proving it are added to the response being formed. A server that refused the
+// query or could not answer it, after every name server of the zone was tried, ends the resolution with ErrLameDelegation or ErrServerFailure respectively.
+func (resolver *Resolver) checkResponseCode(response *Message, name string, recType RecordType, zone string, nameserver string) error {
+	rcode := response.Header.Rcode
+	if rcode == RC_NOERROR {
+		return nil
+	} else if rcode == RC_NXDOMAIN && !resolver.isAuthoritative(response) {
+		return fmt.Errorf("%w: %s returned a non-authoritative NXDOMAIN for %s", ErrLameDelegation, nameserver, name)
+	} else if rcode == RC_NXDOMAIN {
+		err := resolver.addNegativeProof(response, name, recType, zone)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s, as per %s", ErrNXDomain, name, nameserver)
+	} else if rcode == RC_REFUSED || rcode == RC_NOTAUTH || rcode == RC_NOTIMP {
+		return fmt.Errorf("%w: %s returned %s for %s", ErrLameDelegation, nameserver, rcode.String(), name)
+	} else {
+		return fmt.Errorf("%w: %s returned %s for %s", ErrServerFailure, nameserver, rcode.String(), name)
 	}
+}
 
-	nameserver := resolver.getRootServer(TYPE_A)
-	request := NewMessage(MSG_REQUEST, resolver.response.Header.Identifier)
-	request.NewQuestion(name, TYPE_AAAA)
-	for {
-		response := resolver.getResponse(request, nameserver)
-		if response.Header.AnCount > 0 {
-			CNAME_RRs, exists := response.FindAnswerRecords(TYPE_CNAME)
-			if exists {
-				resolver.addToResolverResponse(name, CNAME_RRs)
-				resolver.addToCache(CNAME_RRs)
-				return resolver.resolveAAAA(CNAME_RRs[0].GetData())
-			}
-
-			AAAA_RRs, _ := response.FindAnswerRecords(TYPE_AAAA)
-			resolver.addToResolverResponse(name, AAAA_RRs)
-			resolver.addToCache(AAAA_RRs)
-			return AAAA_RRs, nil
+// Determines the outcome of a NOERROR response from a name server of 'zone' that neither answered the query nor referred to another zone.
+// An authoritative server saying so means the domain name exists without records of the requested type (ErrNoData), while a non-authoritative
+// server doing so is not serving the zone that was delegated to it (ErrLameDelegation).
+func (resolver *Resolver) checkNoData(response *Message, name string, recType RecordType, zone string, nameserver string) error {
+	if resolver.isAuthoritative(response) {
+		err := resolver.addNegativeProof(response, name, recType, zone)
+		if err != nil {
+			return err
 		}
+		return fmt.Errorf("%w: %s, as per %s", ErrNoData, name, nameserver)
+	}
 
-		if response.Header.NsCount > 0 && response.Header.ArCount == 0 {
-			NS_RRs, Exists := response.FindAuthorityRecords(TYPE_NS)
-			if !Exists {
-				return nil, ErrNameServerFetch
-			}
-			NS_IPs, err := resolver.resolveA(NS_RRs[0].GetData())
-			if err != nil {
-				return nil, err
-			}
+	return fmt.Errorf("%w: %s neither answered nor referred the query for %s", ErrLameDelegation, nameserver, name)
+}
 
-			nameserver = NS_IPs[0].GetData()
-		} else {
-			Add_RRs, Exists := response.FindAdditionalRecords(TYPE_A)
-			if !Exists {
-				return nil, ErrNameServerFetch
-			}
+// Adds the records proving the denial of existence found in the authority section of a negative response to the authority section of
+// the response being formed: the SOA record and, with DNSSEC enabled, the NSEC or NSEC3 records and their signatures. A denial that
+// fails DNSSEC validation ends the resolution with ErrBogus, and only a secure denial keeps the response marked as authenticated.
+func (resolver *Resolver) addNegativeProof(response *Message, name string, recType RecordType, zone string) error {
+	records, status, err := resolver.secureDenial(response, name, recType, zone)
+	if status == SECURITY_BOGUS {
+		return fmt.Errorf("%w: denial of %s type records of %s: %s", ErrBogus, recType.String(), name, err.Error())
+	}
 
-			nameserver = Add_RRs[0].GetData()
-		}
+	if len(records) > 0 {
+		resolver.response.AddAuthorities(records)
+	}
+	if resolver.isValidating() {
+		resolver.Log(fmt.Sprintf("Denial of %s type records of %s is %s.", recType.String(), name, status.String()))
 	}
+	resolver.recordSecurity(status)
+	return nil
 }
 
-// Resolves the given domain name and returns its TXT resource records.
-func (resolver *Resolver) resolveTXT(name string) ([]Resource, error) {
-	cacheRecords, ok := resolver.Cache.Resolve(name, TYPE_TXT)
-	if ok {
-		resolver.addToResolverResponse(name, cacheRecords)
-		resolver.Log(fmt.Sprintf("TXT type records for %s have been served from the cache.", name))
-		return cacheRecords, nil
+// Returns true if the negative answers of the response can be trusted, which is the case for responses from authoritative servers and
+// for every response of the upstreams queries are forwarded to, as these are recursive resolvers.
+func (resolver *Resolver) isAuthoritative(response *Message) bool {
+	return response.Header.Authoritative || resolver.forwarder != nil
+}
+
+// Returns the response code to be sent back to the client for the error that ended the resolution.
+func responseCodeFor(err error) ResponseCode {
+	if errors.Is(err, ErrNXDomain) {
+		return RC_NXDOMAIN
+	} else if errors.Is(err, ErrNoData) {
+		return RC_NOERROR
+	} else if errors.Is(err, ErrNameTooLong) {
+		return RC_YXDOMAIN
 	}
+	return RC_SERVFAIL
+}
 
-	nameserver := resolver.getRootServer(TYPE_A)
-	request := NewMessage(MSG_REQUEST, resolver.response.Header.Identifier)
-	request.NewQuestion(name, TYPE_TXT)
-	for {
-		response := resolver.getResponse(request, nameserver)
-		if response.Header.AnCount > 0 {
-			TXT_RRs, _ := response.FindAnswerRecords(TYPE_TXT)
-			resolver.addToResolverResponse(name, TXT_RRs)
-			resolver.addToCache(TXT_RRs)
-			return TXT_RRs, nil
-		}
+// Returns true if the response shows that the name server failed to answer the query, so that another name server of the zone should be tried.
+func isServerFailure(response *Message) bool {
+	rcode := response.Header.Rcode
+	return rcode == RC_SERVFAIL || rcode == RC_REFUSED || rcode == RC_NOTAUTH || rcode == RC_NOTIMP || rcode == RC_FORMERR
+}
 
-		if response.Header.NsCount > 0 && response.Header.ArCount == 0 {
-			NS_RRs, Exists := response.FindAuthorityRecords(TYPE_NS)
-			if !Exists {
-				return nil, ErrNameServerFetch
-			}
-			NS_IPs, err := resolver.resolveA(NS_RRs[0].GetData())
-			if err != nil {
-				return nil, err
-			}
+// Synthesizes the CNAME record for the given domain name from the DNAME record received for one of its ancestors, adds both
+// to the response and the cache, and carries on resolving the target of the CNAME record unless CNAME records are being resolved.
+// Any CNAME record sent by the server along with the DNAME record is ignored in favour of the synthesized one (RFC 6672 - Section 3.4).
+// 'DNAME_RRs' holds the DNAME record followed by the RRSIG records covering it, if any.
+func (resolver *Resolver) followDNAME(name string, recType RecordType, DNAME_RRs []Resource) ([]Resource, error) {
+	DNAME_RR := DNAME_RRs[0]
+	CNAME_RR, err := SynthesizeCNAME(name, DNAME_RR)
+	if err != nil {
+		return nil, err
+	}
 
-			nameserver = NS_IPs[0].GetData()
-		} else {
-			Add_RRs, Exists := response.FindAdditionalRecords(TYPE_A)
-			if !Exists {
-				return nil, ErrNameServerFetch
-			}
+	resolver.Log(fmt.Sprintf("%s is aliased to %s by the DNAME record of %s.", name, CNAME_RR.GetData(), DNAME_RR.Name.Value))
+	records := append([]Resource{DNAME_RR, CNAME_RR}, DNAME_RRs[1:]...)
+	resolver.addToResolverResponse(name, records)
+	resolver.addToCache(records)
+	if recType == TYPE_CNAME {
+		return []Resource{CNAME_RR}, nil
+	}
 
-			nameserver = Add_RRs[0].GetData()
-		}
+	err = resolver.followCNAME(name)
+	if err != nil {
+		return nil, err
 	}
+	return resolver.resolve(CNAME_RR.GetData(), recType)
 }
 
-// Resolves the given domain name and returns the CNAME resource records.
-func (resolver *Resolver) resolveCNAME(name string) ([]Resource, error) {
-	cacheRecords, ok := resolver.Cache.Resolve(name, TYPE_CNAME)
-	if ok {
-		resolver.addToResolverResponse(name, cacheRecords)
-		resolver.Log(fmt.Sprintf("CNAME type records for %s have been served from the cache.", name))
-		return cacheRecords, nil
+// Follows the alias mode SVCB or HTTPS record among the given records of the domain name (RFC 9460 - Section 2.4.2), by resolving
+// the same record type for the target of the alias. Aliases count towards the limit on CNAME chains. Records of any other type,
+// service mode records and aliases to the root domain name (meaning the service is not available) are returned as they are.
+func (resolver *Resolver) followAlias(name string, recType RecordType, RRs []Resource) ([]Resource, error) {
+	if recType != TYPE_SVCB && recType != TYPE_HTTPS {
+		return RRs, nil
 	}
 
-	nameserver := resolver.getRootServer(TYPE_A)
-	request := NewMessage(MSG_REQUEST, resolver.response.Header.Identifier)
-	request.NewQuestion(name, TYPE_CNAME)
-	for {
-		response := resolver.getResponse(request, nameserver)
-		if response.Header.AnCount > 0 {
-			CNAME_RRs, Exists := response.FindAnswerRecords(TYPE_CNAME)
-			if Exists {
-				resolver.addToResolverResponse(name, CNAME_RRs)
-				resolver.addToCache(CNAME_RRs)
-				return CNAME_RRs, nil
-			} else {
-				return make([]Resource, 0), nil
-			}
+	for _, rr := range RRs {
+		svcb, ok := rr.Rdata.(*SVCBResource)
+		if !ok || rr.Type != recType || !strings.EqualFold(rr.Name.Value, Canonicalize(name)) || !svcb.IsAlias() {
+			continue
 		}
 
-		if response.Header.NsCount > 0 && response.Header.ArCount == 0 {
-			NS_RRs, Exists := response.FindAuthorityRecords(TYPE_NS)
-			if !Exists {
-				return nil, ErrNameServerFetch
-			}
-			NS_IPs, err := resolver.resolveA(NS_RRs[0].GetData())
-			if err != nil {
-				return nil, err
-			}
-
-			nameserver = NS_IPs[0].GetData()
-		} else {
-			Add_RRs, Exists := response.FindAdditionalRecords(TYPE_A)
-			if !Exists {
-				return nil, ErrNameServerFetch
-			}
+		target := svcb.Target.Value
+		if target == DOMAIN_LABEL_SEPERATOR || strings.EqualFold(target, Canonicalize(name)) {
+			return RRs, nil
+		}
 
-			nameserver = Add_RRs[0].GetData()
+		resolver.Log(fmt.Sprintf("%s record of %s is an alias to %s.", recType.String(), name, target))
+		err := resolver.followCNAME(name)
+		if err != nil {
+			return nil, err
 		}
+		return resolver.resolve(target, recType)
+	}
+
+	return RRs, nil
+}
+
+// Accounts for one more CNAME record followed from the given domain name, failing once the chain of CNAME records grows
+// longer than MAX_CNAME_CHAIN. This stops CNAME records pointing at each other from being followed forever.
+func (resolver *Resolver) followCNAME(name string) error {
+	resolver.cnameChain++
+	if resolver.cnameChain > MAX_CNAME_CHAIN {
+		return fmt.Errorf("%w: more than %d CNAME records followed, the last one from %s", ErrCNAMEChainLimit, MAX_CNAME_CHAIN, name)
+	}
+	return nil
+}
+
+// Moves QNAME minimisation forward after a minimised query that did not return a referral, falling back to
+// the complete domain name if the server could not answer the minimised query.
+func (resolver *Resolver) advanceMinimiser(minimiser *qnameMinimiser, response *Message, nameserver string) {
+	if !minimiser.Advance(response.Header.Rcode) {
+		resolver.Log(fmt.Sprintf("Server %s returned %s for a minimised query, sending the complete domain name instead.", nameserver, response.Header.Rcode.String()))
 	}
 }
 
@@ -290,67 +607,354 @@ func (resolver *Resolver) GetRecordType(recordType string) RecordType {
 	return AllowedRRTypes.GetRecordType(recordType)
 }
 
-// Returns the IP address of a root DNS server chosen at random.
-func (resolver *Resolver) getRootServer(recType RecordType) string {
+// Returns the address record types usable with the configured transport preference.
+func (resolver *Resolver) addressTypes() []RecordType {
+	if resolver.transport == TRANSPORT_IPv6 {
+		return []RecordType{TYPE_AAAA}
+	} else if resolver.transport == TRANSPORT_BOTH {
+		return []RecordType{TYPE_AAAA, TYPE_A}
+	}
+	return []RecordType{TYPE_A}
+}
+
+// Returns the IP addresses of all the root DNS servers usable with the configured transport preference.
+func (resolver *Resolver) getRootServers() []string {
 	rootServerAddress := make([]string, 0)
-	for _, rr := range resolver.RootServers.ResourceRecords {
-		if rr.resource.Type == recType {
-			rootServerAddress = append(rootServerAddress, rr.resource.GetData())
+	for _, recType := range resolver.addressTypes() {
+		for _, rr := range resolver.RootServers.Records() {
+			if rr.resource.Type == recType {
+				rootServerAddress = append(rootServerAddress, rr.resource.GetData())
+			}
+		}
+	}
+
+	return rootServerAddress
+}
+
+// Returns the in-bailiwick glue records of the given name servers that are usable with the configured transport preference.
+func (resolver *Resolver) findGlue(response *Message, NS_RRs []Resource, zone string) ([]Resource, bool) {
+	Glue_RRs := make([]Resource, 0)
+	for _, recType := range resolver.addressTypes() {
+		records, _ := response.FindGlueRecords(NS_RRs, recType, zone)
+		Glue_RRs = append(Glue_RRs, records...)
+	}
+	return Glue_RRs, len(Glue_RRs) > 0
+}
+
+//Returns the cached records of the given domain name and type used to reach name servers. Authoritative records from the answer
+//cache are preferred over the records learnt from referrals.
+func (resolver *Resolver) getDelegationRecords(name string, recType RecordType) ([]Resource, bool) {
+	if RRs, ok := resolver.Cache.Get(name, recType); ok {
+		return RRs, true
+	}
+	return resolver.Delegations.Get(name, recType)
+}
+
+// Returns the closest ancestor zone of the given domain name whose name servers and their addresses are available in the cache,
+// along with those addresses. Falls back to the root zone and the root DNS servers when nothing is cached.
+func (resolver *Resolver) getClosestNameServers(name string) (string, []string) {
+	name = Canonicalize(name)
+	for labels := CountLabels(name); labels > 0; labels-- {
+		zone := LastLabels(name, labels)
+		NS_RRs, ok := resolver.getDelegationRecords(zone, TYPE_NS)
+		if !ok {
+			continue
+		}
+
+		nameservers := make([]string, 0)
+		for _, ns := range NS_RRs {
+			for _, recType := range resolver.addressTypes() {
+				NS_IPs, ok := resolver.getDelegationRecords(ns.GetData(), recType)
+				if ok {
+					nameservers = append(nameservers, getAddresses(NS_IPs)...)
+				}
+			}
+		}
+
+		if len(nameservers) > 0 {
+			resolver.Log(fmt.Sprintf("Starting resolution of %s at zone %s using cached name servers.", name, zone))
+			return zone, nameservers
+		}
+	}
+
+	return DOMAIN_LABEL_SEPERATOR, resolver.getRootServers()
+}
+
+// Resolves the addresses of the name servers of a delegation that came without glue. Up to MAX_PARALLEL_NS_LOOKUPS name servers
+// are resolved concurrently, for every address type usable with the transport preference, and the addresses of whichever resolves
+// first are returned. The remaining lookups carry on in the background so that their addresses are cached for later queries in the same zone.
+func (resolver *Resolver) resolveNameServers(NS_RRs []Resource) ([]string, error) {
+	if resolver.depth >= MAX_NS_LOOKUP_DEPTH {
+		return nil, fmt.Errorf("%w: name server lookups nested more than %d levels deep", ErrLookupDepthLimit, MAX_NS_LOOKUP_DEPTH)
+	}
+
+	count := min(len(NS_RRs), MAX_PARALLEL_NS_LOOKUPS)
+	addressTypes := resolver.addressTypes()
+	results := make(chan nameServerAddresses, count * len(addressTypes))
+	for _, ns := range NS_RRs[:count] {
+		for _, recType := range addressTypes {
+			resolver.pending.Add(1)
+			go func(nsName string, recType RecordType) {
+				defer resolver.pending.Done()
+				nsResolver := resolver.fork(nsName, recType)
+				NS_IPs, err := nsResolver.resolve(nsName, recType)
+				results <- nameServerAddresses{name: nsName, addresses: getAddresses(NS_IPs), err: err}
+			}(ns.GetData(), recType)
+		}
+	}
+
+	lastErr := ErrNameServerFetch
+	for index := 0; index < cap(results); index++ {
+		result := <-results
+		if result.err == nil && len(result.addresses) > 0 {
+			resolver.Log(fmt.Sprintf("Name server %s resolved first to %s.", result.name, strings.Join(result.addresses, ", ")))
+			return result.addresses, nil
+		}
+
+		if result.err != nil {
+			lastErr = result.err
+		}
+	}
+
+	return nil, lastErr
+}
+
+// Returns a copy of the resolver that shares its configuration, cache and statistics but forms its own response message,
+// so that the name and record type given can be resolved without altering the response being formed for the client.
+func (resolver *Resolver) fork(name string, recType RecordType) *Resolver {
+	forked := *resolver
+	forked.response = NewMessage(MSG_RESOLVER_RESPONSE, Id())
+	forked.response.NewQuestion(name, recType)
+	forked.cnameChain = 0
+	forked.depth = resolver.depth + 1
+	//Name server addresses are not validated, lookups made on behalf of validation turn it back on where needed.
+	forked.checkingDisabled = true
+	forked.resetSecurity()
+	return &forked
+}
+
+// Sends the request to the upstreams of the forwarder if forwarding is enabled, or to the name servers of the zone otherwise.
+func (resolver *Resolver) sendQuery(request *Message, nameservers []string) (*Message, string, error) {
+	if resolver.forwarder != nil {
+		return resolver.forward(request)
+	}
+	return resolver.queryNameServers(request, nameservers)
+}
+
+// Sends the request to the name servers of a zone, starting with the fastest responsive server as per the infrastructure cache,
+// and moves on to the next best server when a server does not respond. Returns the response along with the server that sent it,
+// or an error if no server responded or the work limits of the client query were exceeded. A server that answers with SERVFAIL,
+// REFUSED or a similar error is skipped too, and its response is only returned if no other server gives a better answer.
+func (resolver *Resolver) queryNameServers(request *Message, nameservers []string) (*Message, string, error) {
+	ordered := resolver.Infra.Select(nameservers)
+	resolver.Log(fmt.Sprintf("Infrastructure cache for the name servers of the zone:\n%s", resolver.Infra.Describe(ordered)))
+	if resolver.transport == TRANSPORT_BOTH {
+		return resolver.raceNameServers(request, ordered)
+	}
+
+	var failed *Message
+	failedServer := ""
+	for attempt, nameserver := range ordered {
+		if attempt == MAX_SERVER_ATTEMPTS {
+			break
+		}
+
+		err := resolver.limits.Spend()
+		if err != nil {
+			return nil, "", err
+		}
+
+		response := resolver.queryNameServer(request, nameserver)
+		if response == nil {
+			continue
+		}
+
+		if isServerFailure(response) {
+			resolver.Log(fmt.Sprintf("Server %s returned %s, trying the next name server.", nameserver, response.Header.Rcode.String()))
+			failed, failedServer = response, nameserver
+			continue
 		}
+		return response, nameserver, nil
 	}
 
-	RandomIndex := rand.IntN(len(rootServerAddress))
-	RandomServer := rootServerAddress[RandomIndex]
+	if failed != nil {
+		return failed, failedServer, nil
+	}
+	return nil, "", ErrNoResponse
+}
+
+// Races name servers of both IP versions, happy eyeballs style (RFC 8305). In every attempt the next server of the IP version
+// preferred by the infrastructure cache is queried first, and the next server of the other IP version joins in if no response
+// arrives within HAPPY_EYEBALLS_DELAY or the first server fails. The first response received wins.
+func (resolver *Resolver) raceNameServers(request *Message, ordered []string) (*Message, string, error) {
+	preferred, fallback := make([]string, 0), make([]string, 0)
+	for _, nameserver := range ordered {
+		if isIPv6(nameserver) == isIPv6(ordered[0]) {
+			preferred = append(preferred, nameserver)
+		} else {
+			fallback = append(fallback, nameserver)
+		}
+	}
+
+	var failed *Message
+	failedServer := ""
+	for attempt := 0; attempt < MAX_SERVER_ATTEMPTS && (attempt < len(preferred) || attempt < len(fallback)); attempt++ {
+		results := make(chan serverResponse, 2)
+		query := func(nameserver string) {
+			results <- serverResponse{resolver.queryNameServer(request.CloneRequest(), nameserver), nameserver}
+		}
+
+		launched, received := 0, 0
+		if attempt < len(preferred) {
+			err := resolver.limits.Spend()
+			if err != nil {
+				return nil, "", err
+			}
+			launched++
+			go query(preferred[attempt])
+		}
 
-	return RandomServer
+		fallbackLaunched := attempt >= len(fallback)
+		timer := time.NewTimer(HAPPY_EYEBALLS_DELAY)
+		for received < launched || !fallbackLaunched {
+			if launched > 0 {
+				select {
+				case result := <-results:
+					received++
+					if result.response != nil && isServerFailure(result.response) {
+						resolver.Log(fmt.Sprintf("Server %s returned %s, trying the next name server.", result.server, result.response.Header.Rcode.String()))
+						failed, failedServer = result.response, result.server
+					} else if result.response != nil {
+						timer.Stop()
+						return result.response, result.server, nil
+					}
+				case <-timer.C:
+				}
+			}
+
+			if !fallbackLaunched {
+				err := resolver.limits.Spend()
+				if err != nil {
+					timer.Stop()
+					return nil, "", err
+				}
+				resolver.Log(fmt.Sprintf("Querying %s as well, as no response has been received yet.", fallback[attempt]))
+				fallbackLaunched = true
+				launched++
+				go query(fallback[attempt])
+			}
+		}
+		timer.Stop()
+	}
+
+	if failed != nil {
+		return failed, failedServer, nil
+	}
+	return nil, "", ErrNoResponse
+}
+
+// Sends the request to a single name server and records the round-trip time, or the failure, in the infrastructure cache.
+// A server that answers with SERVFAIL, REFUSED or a similar error is recorded as having failed.
+func (resolver *Resolver) queryNameServer(request *Message, nameserver string) *Message {
+	startTime := time.Now()
+	response := resolver.getResponse(request, nameserver)
+	if response == nil {
+		resolver.Infra.RecordFailure(nameserver)
+		return nil
+	}
+
+	if isServerFailure(response) {
+		resolver.Infra.RecordFailure(nameserver)
+		return response
+	}
+
+	resolver.Infra.RecordSuccess(nameserver, time.Since(startTime))
+	return response
 }
 
 // Sends the request to the target DNS server and receives a response over the same connection.
+// When case randomization is enabled, the letter case of the question is randomized unless the server is known not to preserve it.
+// If the server echoes the question in a different case, the query is retried once without randomization and, if that succeeds,
+// the server is remembered as one that does not preserve case.
 func (resolver *Resolver) getResponse(request *Message, ServerAddress string) *Message {
 	ServerAddress = strings.TrimSpace(ServerAddress)
 	if ServerAddress == "" {
 		return nil
 	}
+
+	_, caseInsensitive := resolver.caseInsensitiveServers.Load(ServerAddress)
+	randomizeCase := resolver.caseRandomization && !caseInsensitive
+	response, err := resolver.exchange(request, ServerAddress, randomizeCase)
+	if err == ErrCaseMismatch {
+		resolver.Log(fmt.Sprintf("Server %s did not preserve the letter case of the question, retrying without case randomization.", ServerAddress))
+		response, err = resolver.exchange(request, ServerAddress, false)
+		if err == nil {
+			resolver.caseInsensitiveServers.Store(ServerAddress, true)
+		}
+	}
+
+	if err != nil {
+		resolver.Log(err.Error())
+		return nil
+	}
+	return response
+}
+
+// Sends the request to the target DNS server through the exchanger of the resolver and returns its response. Every exchange uses a fresh
+// random message ID, and the exchanger only accepts a response carrying the same ID and question as the request. The server is queried on
+// the port it is routed to, if any, and on the upstream port otherwise.
+func (resolver *Resolver) exchange(request *Message, ServerAddress string, randomizeCase bool) (*Message, error) {
+	request.Header.SetIdentifier(Id())
+	for index := range request.Questions {
+		if randomizeCase {
+			request.Questions[index].RandomizeCase()
+		} else {
+			request.Questions[index].ResetCase()
+		}
+	}
+	address := resolver.serverAddress(ServerAddress)
 	resolver.Log("**********************************************")
-	resolver.Log(fmt.Sprintf("DNS Request being sent to server - %s.", ServerAddress))
+	resolver.Log(fmt.Sprintf("DNS Request being sent to server - %s.", address))
 	resolver.Log("**********************************************")
 	resolver.Log(fmt.Sprintf("Request Contents are:\n%s", request.String()))
 	resolver.Log("**********************************************")
-	SendBuffer := request.Pack()
-	udpConnect := UdpConnect{}
-	err := udpConnect.ConnectTo(ServerAddress, DNS_PORT_NUMBER)
+	ctx, cancel := context.WithDeadline(context.Background(), resolver.limits.Deadline(time.Now().Add(UDP_RESPONSE_TIMEOUT)))
+	defer cancel()
+	response, err := resolver.exchanger.Exchange(ctx, request, address)
 	if err != nil {
-		resolver.Log(err.Error())
-		return nil
+		return nil, err
 	}
-	var response *Message
-	for validResponse := false; !validResponse; {
-		err = udpConnect.Send(SendBuffer)
-		if err != nil {
-			resolver.Log(err.Error())
-			return nil
-		}
-		receiveBuffer, err := udpConnect.Receive()
-		if err != nil {
-			resolver.Log(err.Error())
-			return nil
-		}
-		response = NewMessage(MSG_RESPONSE, 0)
-		response.Unpack(receiveBuffer)
-		validResponse = response.IsResponse(request)
+
+	if randomizeCase && !response.HasExactQuestions(request) {
+		resolver.Discarded.count(ErrCaseMismatch)
+		return nil, ErrCaseMismatch
 	}
 	resolver.Log(fmt.Sprintf("Response received back:\n%s", response.String()))
 	resolver.Log("**********************************************")
-	err = udpConnect.Close()
-	if err != nil {
-		resolver.Log(err.Error())
+	return response, nil
+}
+
+// Returns the address, as "ip:port", the name server with the given IP address is queried at.
+func (resolver *Resolver) serverAddress(server string) string {
+	port := resolver.port
+	if routed, ok := resolver.serverPorts.Load(server); ok {
+		port = routed.(int)
 	}
-	return response
+	return net.JoinHostPort(server, strconv.Itoa(port))
 }
 
-// Syncs the changes from memory to the local cache file.
+// Waits for the name server lookups running in the background and flushes the changes from memory to the cache store.
 func (resolver *Resolver) Close() {
-	resolver.Cache.Sync()
+	resolver.pending.Wait()
+	resolver.Log(fmt.Sprintf("Infrastructure cache:\n%s", resolver.Infra.String()))
+	if resolver.Discarded.Total() > 0 {
+		resolver.Log(fmt.Sprintf("Upstream responses discarded - %s.", resolver.Discarded.String()))
+	}
+
+	err := resolver.Cache.Flush()
+	if err != nil {
+		resolver.Log(err.Error())
+	}
 }
 
 //Logs information to the log file.
@@ -0,0 +1,685 @@
+package dns
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//IP addresses of the simulated name servers.
+const (
+	ROOT_SERVER = "198.41.0.4"
+	GTLD_SERVER = "192.5.6.30"
+	EXAMPLE_SERVER_1 = "192.0.2.1"
+	EXAMPLE_SERVER_2 = "192.0.2.2"
+	DEPT_SERVER = "192.0.2.3"
+	HOSTING_SERVER = "192.0.2.10"
+	BROKEN_SERVER = "192.0.2.66"
+	LAME_SERVER = "192.0.2.77"
+)
+
+//Returns the zone fixtures of a hierarchy made up of the root zone, the com. and net. TLDs, and the authoritative zones below them:
+//example.com. with glue records, a wildcard and a child zone of its own, glueless.com. whose name server must be resolved in net., and the
+//broken.com. and lame.com. zones delegated to servers that fail to answer for them.
+func hierarchyFixtures() []zoneFixture {
+	return []zoneFixture{
+		{Origin: ".", Servers: []string{ROOT_SERVER}, Records: `
+$TTL 86400
+@                   SOA a.root-servers.net. nstld.verisign-grs.com. 1 1800 900 604800 86400
+@                   NS  a.root-servers.net.
+a.root-servers.net. A   198.41.0.4
+com.                NS  a.gtld-servers.net.
+net.                NS  a.gtld-servers.net.
+a.gtld-servers.net. A   192.5.6.30
+`},
+		{Origin: "com.", Servers: []string{GTLD_SERVER}, Records: `
+$TTL 172800
+@            SOA a.gtld-servers.net. nstld.verisign-grs.com. 1 1800 900 604800 86400
+@            NS  a.gtld-servers.net.
+example      NS  ns1.example
+example      NS  ns2.example
+ns1.example  A   192.0.2.1
+ns2.example  A   192.0.2.2
+glueless     NS  ns.hosting.net.
+broken       NS  ns.broken
+ns.broken    A   192.0.2.66
+lame         NS  ns.lame
+ns.lame      A   192.0.2.77
+`},
+		{Origin: "net.", Servers: []string{GTLD_SERVER}, Records: `
+$TTL 172800
+@            SOA a.gtld-servers.net. nstld.verisign-grs.com. 1 1800 900 604800 86400
+@            NS  a.gtld-servers.net.
+hosting      NS  ns.hosting
+ns.hosting   A   192.0.2.10
+`},
+		{Origin: "example.com.", Servers: []string{EXAMPLE_SERVER_1, EXAMPLE_SERVER_2}, Records: `
+$TTL 300
+@         SOA   ns1 hostmaster 1 3600 900 604800 300
+@         NS    ns1
+@         NS    ns2
+ns1       A     192.0.2.1
+ns2       A     192.0.2.2
+www       A     203.0.113.10
+www       AAAA  2001:db8::10
+www       TXT   "v=spf1 -all"
+ipv4      A     203.0.113.11
+alias     CNAME www
+chain     CNAME alias
+external  CNAME www.glueless.com.
+dangling  CNAME missing.glueless.com.
+loop1     CNAME loop2
+loop2     CNAME loop1
+big       TXT   "` + strings.Repeat("a", 200) + `"
+big       TXT   "` + strings.Repeat("b", 200) + `"
+big       TXT   "` + strings.Repeat("c", 200) + `"
+*.wild    A     203.0.113.40
+real.wild A     203.0.113.41
+dept      NS    ns.dept
+ns.dept   A     192.0.2.3
+`},
+		{Origin: "dept.example.com.", Servers: []string{DEPT_SERVER}, Records: `
+$TTL 300
+@         SOA   ns hostmaster 1 3600 900 604800 300
+@         NS    ns
+ns        A     192.0.2.3
+host      A     203.0.113.30
+`},
+		{Origin: "hosting.net.", Servers: []string{HOSTING_SERVER}, Records: `
+$TTL 300
+@         SOA   ns hostmaster 1 3600 900 604800 300
+@         NS    ns
+ns        A     192.0.2.10
+`},
+		{Origin: "glueless.com.", Servers: []string{HOSTING_SERVER}, Records: `
+$TTL 300
+@         SOA   ns.hosting.net. hostmaster.hosting.net. 1 3600 900 604800 300
+@         NS    ns.hosting.net.
+www       A     203.0.113.20
+`},
+		{Origin: "broken.com.", Servers: []string{BROKEN_SERVER}, Records: `
+$TTL 300
+@         SOA   ns hostmaster 1 3600 900 604800 300
+@         NS    ns
+www       A     203.0.113.66
+`},
+	}
+}
+
+//Returns the answer records of the response, each as "name TYPE data".
+func answerStrings(response *Message) []string {
+	answers := make([]string, 0)
+	for _, answer := range response.Answers {
+		answers = append(answers, answer.Name.Value + WHITESPACE + answer.Type.String() + WHITESPACE + answer.GetData())
+	}
+	return answers
+}
+
+func TestResolverQueries(t *testing.T) {
+	testCases := []struct {
+		name string
+		qname string
+		qtype RecordType
+		faults map[string]serverFault
+		rcode ResponseCode
+		answers []string
+	}{
+		{
+			name: "A record through referrals with glue",
+			qname: "www.example.com.", qtype: TYPE_A, rcode: RC_NOERROR,
+			answers: []string{"www.example.com. A 203.0.113.10"},
+		},
+		{
+			name: "AAAA record through referrals with glue",
+			qname: "www.example.com.", qtype: TYPE_AAAA, rcode: RC_NOERROR,
+			answers: []string{"www.example.com. AAAA 2001:db8::10"},
+		},
+		{
+			name: "TXT record",
+			qname: "www.example.com.", qtype: TYPE_TXT, rcode: RC_NOERROR,
+			answers: []string{"www.example.com. TXT \"v=spf1 -all\""},
+		},
+		{
+			name: "CNAME record queried directly",
+			qname: "alias.example.com.", qtype: TYPE_CNAME, rcode: RC_NOERROR,
+			answers: []string{"alias.example.com. CNAME www.example.com."},
+		},
+		{
+			name: "A record through a CNAME",
+			qname: "alias.example.com.", qtype: TYPE_A, rcode: RC_NOERROR,
+			answers: []string{"alias.example.com. CNAME www.example.com.", "www.example.com. A 203.0.113.10"},
+		},
+		{
+			name: "AAAA record through a CNAME chain",
+			qname: "chain.example.com.", qtype: TYPE_AAAA, rcode: RC_NOERROR,
+			answers: []string{"chain.example.com. CNAME alias.example.com.", "alias.example.com. CNAME www.example.com.", "www.example.com. AAAA 2001:db8::10"},
+		},
+		{
+			name: "TXT record through a CNAME chain",
+			qname: "chain.example.com.", qtype: TYPE_TXT, rcode: RC_NOERROR,
+			answers: []string{"chain.example.com. CNAME alias.example.com.", "alias.example.com. CNAME www.example.com.", "www.example.com. TXT \"v=spf1 -all\""},
+		},
+		{
+			name: "CNAME into a zone with a glueless delegation",
+			qname: "external.example.com.", qtype: TYPE_A, rcode: RC_NOERROR,
+			answers: []string{"external.example.com. CNAME www.glueless.com.", "www.glueless.com. A 203.0.113.20"},
+		},
+		{
+			name: "CNAME to a name that does not exist",
+			qname: "dangling.example.com.", qtype: TYPE_A, rcode: RC_NXDOMAIN,
+			answers: []string{"dangling.example.com. CNAME missing.glueless.com."},
+		},
+		{
+			name: "CNAME loop",
+			qname: "loop1.example.com.", qtype: TYPE_A, rcode: RC_SERVFAIL,
+		},
+		{
+			name: "A record in a child zone of an authoritative zone",
+			qname: "host.dept.example.com.", qtype: TYPE_A, rcode: RC_NOERROR,
+			answers: []string{"host.dept.example.com. A 203.0.113.30"},
+		},
+		{
+			name: "A record through a glueless delegation",
+			qname: "www.glueless.com.", qtype: TYPE_A, rcode: RC_NOERROR,
+			answers: []string{"www.glueless.com. A 203.0.113.20"},
+		},
+		{
+			name: "NXDOMAIN from the authoritative zone",
+			qname: "missing.example.com.", qtype: TYPE_A, rcode: RC_NXDOMAIN,
+		},
+		{
+			name: "NXDOMAIN from the root zone",
+			qname: "www.example.invalid.", qtype: TYPE_AAAA, rcode: RC_NXDOMAIN,
+		},
+		{
+			name: "NXDOMAIN for a TXT record",
+			qname: "missing.example.com.", qtype: TYPE_TXT, rcode: RC_NXDOMAIN,
+		},
+		{
+			name: "NXDOMAIN for a CNAME record",
+			qname: "missing.example.com.", qtype: TYPE_CNAME, rcode: RC_NXDOMAIN,
+		},
+		{
+			name: "No AAAA record for an existing name",
+			qname: "ipv4.example.com.", qtype: TYPE_AAAA, rcode: RC_NOERROR,
+		},
+		{
+			name: "No CNAME record for an existing name",
+			qname: "www.example.com.", qtype: TYPE_CNAME, rcode: RC_NOERROR,
+		},
+		{
+			name: "Truncated TXT records fetched over the stream transport",
+			qname: "big.example.com.", qtype: TYPE_TXT, rcode: RC_NOERROR,
+			answers: []string{"big.example.com. TXT \"" + strings.Repeat("a", 200) + "\"", "big.example.com. TXT \"" + strings.Repeat("b", 200) + "\"", "big.example.com. TXT \"" + strings.Repeat("c", 200) + "\""},
+		},
+		{
+			name: "Name server timing out",
+			qname: "www.broken.com.", qtype: TYPE_A, rcode: RC_SERVFAIL,
+			faults: map[string]serverFault{BROKEN_SERVER: FAULT_TIMEOUT},
+		},
+		{
+			name: "Name server not serving the zone delegated to it",
+			qname: "www.lame.com.", qtype: TYPE_A, rcode: RC_SERVFAIL,
+		},
+		{
+			name: "One name server of the zone timing out",
+			qname: "www.example.com.", qtype: TYPE_A, rcode: RC_NOERROR,
+			faults: map[string]serverFault{EXAMPLE_SERVER_1: FAULT_TIMEOUT},
+			answers: []string{"www.example.com. A 203.0.113.10"},
+		},
+		{
+			name: "One name server of the zone failing",
+			qname: "www.example.com.", qtype: TYPE_TXT, rcode: RC_NOERROR,
+			faults: map[string]serverFault{EXAMPLE_SERVER_2: FAULT_SERVER_FAILURE},
+			answers: []string{"www.example.com. TXT \"v=spf1 -all\""},
+		},
+		{
+			name: "Every name server of the zone failing",
+			qname: "www.example.com.", qtype: TYPE_AAAA, rcode: RC_SERVFAIL,
+			faults: map[string]serverFault{EXAMPLE_SERVER_1: FAULT_SERVER_FAILURE, EXAMPLE_SERVER_2: FAULT_REFUSED},
+		},
+		{
+			name: "Every name server of the zone timing out",
+			qname: "alias.example.com.", qtype: TYPE_CNAME, rcode: RC_SERVFAIL,
+			faults: map[string]serverFault{EXAMPLE_SERVER_1: FAULT_TIMEOUT, EXAMPLE_SERVER_2: FAULT_TIMEOUT},
+		},
+		{
+			name: "TLD server failing",
+			qname: "www.example.com.", qtype: TYPE_A, rcode: RC_SERVFAIL,
+			faults: map[string]serverFault{GTLD_SERVER: FAULT_SERVER_FAILURE},
+		},
+		{
+			name: "Root server timing out",
+			qname: "www.example.com.", qtype: TYPE_A, rcode: RC_SERVFAIL,
+			faults: map[string]serverFault{ROOT_SERVER: FAULT_TIMEOUT},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			hierarchy := newSimulatedHierarchy(t, hierarchyFixtures()...)
+			for server, fault := range testCase.faults {
+				hierarchy.SetFault(server, fault)
+			}
+			resolver := hierarchy.newResolver(t)
+
+			response := resolver.Query(testCase.qname, testCase.qtype)
+			if response.Header.Rcode != testCase.rcode {
+				t.Errorf("response code is %s, expected %s", response.Header.Rcode.String(), testCase.rcode.String())
+			}
+			//The records gathered before a resolution fails are not checked, as only the response code tells the client about the failure.
+			answers := answerStrings(response)
+			if testCase.rcode != RC_SERVFAIL && strings.Join(answers, "\n") != strings.Join(testCase.answers, "\n") {
+				t.Errorf("answers are %q, expected %q", answers, testCase.answers)
+			}
+		})
+	}
+}
+
+func TestResolverUsesGlueRecords(t *testing.T) {
+	hierarchy := newSimulatedHierarchy(t, hierarchyFixtures()...)
+	resolver := hierarchy.newResolver(t)
+	resolver.Query("www.example.com.", TYPE_A)
+
+	for _, question := range []string{"ns1.example.com. A", "ns2.example.com. A", "a.gtld-servers.net. A"} {
+		if hierarchy.Received(question) {
+			t.Errorf("%s was queried although its glue record was given in the referral", question)
+		}
+	}
+	if len(hierarchy.Queries(EXAMPLE_SERVER_1)) + len(hierarchy.Queries(EXAMPLE_SERVER_2)) == 0 {
+		t.Error("no name server of example.com. was queried")
+	}
+}
+
+func TestResolverResolvesGluelessNameServers(t *testing.T) {
+	hierarchy := newSimulatedHierarchy(t, hierarchyFixtures()...)
+	resolver := hierarchy.newResolver(t)
+	resolver.Query("www.glueless.com.", TYPE_A)
+
+	if !hierarchy.Received("ns.hosting.net. A") {
+		t.Error("the address of ns.hosting.net. was not resolved")
+	}
+	if !hierarchy.Received("www.glueless.com. A") {
+		t.Error("www.glueless.com. was not queried from its name server")
+	}
+}
+
+func TestResolverKeepsReferralDataApartFromAnswers(t *testing.T) {
+	hierarchy := newSimulatedHierarchy(t, hierarchyFixtures()...)
+	resolver := hierarchy.newResolver(t)
+	resolver.Query("www.example.com.", TYPE_A)
+	for _, question := range []string{"example.com. NS", "ns1.example.com. A", "ns2.example.com. A"} {
+		name, typeName, _ := strings.Cut(question, WHITESPACE)
+		recType, _ := ParseRecordType(typeName)
+		if RRs, ok := resolver.Cache.Get(name, recType); ok {
+			t.Errorf("referral records %v were cached as answers", RRs)
+		}
+	}
+
+	response := resolver.Query("example.com.", TYPE_NS)
+	if !hierarchy.Received("example.com. NS") {
+		t.Error("the NS records of example.com. were answered from the referral instead of the zone")
+	}
+	for _, rr := range response.Answers {
+		if rr.TTL > 300 {
+			t.Errorf("answer %s carries the TTL of the referral", rr.String())
+		}
+	}
+
+	//Follow the referral of com. again, now that the authoritative records of the name servers are cached.
+	resolver.Query("ns1.example.com.", TYPE_A)
+	resolver.Cache.Delete("example.com.", TYPE_NS)
+	resolver.Delegations = NewMemoryStore()
+	resolver.Query("ipv4.example.com.", TYPE_A)
+	RRs, ok := resolver.Cache.Get("ns1.example.com.", TYPE_A)
+	if !ok || RRs[0].TTL > 300 {
+		t.Errorf("cached address of ns1.example.com. is %v, expected the authoritative record", RRs)
+	}
+}
+
+func TestResolverServesRepeatedQueriesFromCache(t *testing.T) {
+	hierarchy := newSimulatedHierarchy(t, hierarchyFixtures()...)
+	resolver := hierarchy.newResolver(t)
+	resolver.Query("alias.example.com.", TYPE_A)
+	queried := len(hierarchy.Queries(EXAMPLE_SERVER_1)) + len(hierarchy.Queries(EXAMPLE_SERVER_2))
+
+	response := resolver.Query("alias.example.com.", TYPE_A)
+	if len(response.Answers) != 2 {
+		t.Errorf("answers are %q, expected the CNAME and A records", answerStrings(response))
+	}
+	if len(hierarchy.Queries(EXAMPLE_SERVER_1)) + len(hierarchy.Queries(EXAMPLE_SERVER_2)) != queried {
+		t.Error("the name servers of example.com. were queried again for cached records")
+	}
+}
+
+func TestResolverFallsBackToStreamOnTruncation(t *testing.T) {
+	hierarchy := newSimulatedHierarchy(t, hierarchyFixtures()...)
+	resolver := hierarchy.newResolver(t)
+	resolver.Query("www.example.com.", TYPE_TXT)
+	if hierarchy.StreamQueries.Load() != 0 {
+		t.Errorf("%d queries were sent over the stream transport for responses that fit in a datagram", hierarchy.StreamQueries.Load())
+	}
+
+	response := resolver.Query("big.example.com.", TYPE_TXT)
+	if hierarchy.StreamQueries.Load() != 1 {
+		t.Errorf("%d queries were sent over the stream transport, expected 1", hierarchy.StreamQueries.Load())
+	}
+	if response.Header.Truncation || len(response.Answers) != 3 {
+		t.Errorf("answers are %q, expected the three TXT records", answerStrings(response))
+	}
+}
+
+func TestResolverReachesServersOnCustomPorts(t *testing.T) {
+	hierarchy := newSimulatedHierarchy(t, hierarchyFixtures()...)
+	hierarchy.Exchanger.Handle(simulatedAddress(DEPT_SERVER), nil)
+	hierarchy.Exchanger.Handle(DEPT_SERVER + ":5353", hierarchy.handler(simulatedAddress(DEPT_SERVER)))
+
+	resolver := hierarchy.newResolver(t)
+	response := resolver.Query("host.dept.example.com.", TYPE_A)
+	if response.Header.Rcode != RC_SERVFAIL {
+		t.Errorf("response code is %s, expected %s as the server does not listen on port 53", response.Header.Rcode.String(), RC_SERVFAIL.String())
+	}
+
+	resolver = hierarchy.newResolver(t)
+	resolver.SetServerPort(DEPT_SERVER, 5353)
+	response = resolver.Query("host.dept.example.com.", TYPE_A)
+	if response.Header.Rcode != RC_NOERROR || len(response.Answers) != 1 {
+		t.Errorf("answers are %q with response code %s, expected the A record", answerStrings(response), response.Header.Rcode.String())
+	}
+}
+
+//IP address of a name server set up by an attacker, which the resolver must never be led to.
+const ATTACKER_SERVER = "198.51.100.66"
+
+//Returns the handler of the simulated name server at the given IP address, with the given change made to every response it sends.
+func (hierarchy *simulatedHierarchy) tamperedHandler(server string, tamper func(request *Message, response *Message)) MemoryHandler {
+	handler := hierarchy.handler(simulatedAddress(server))
+	return func(request *Message) *Message {
+		response := handler(request)
+		if response != nil {
+			tamper(request, response)
+		}
+		return response
+	}
+}
+
+//Adds the records, given in presentation format as "name ttl class type data", to the additional section of the response.
+func addAdditional(response *Message, records ...string) {
+	for _, record := range records {
+		fields := strings.SplitN(record, WHITESPACE, 5)
+		ttl, _ := strconv.ParseUint(fields[1], 10, 32)
+		response.Additional = append(response.Additional, *NewResourceRecord(fields[0], uint32(ttl), fields[2], fields[3], fields[4]))
+	}
+	response.Header.SetAdditionalRecordCount(uint16(len(response.Additional)))
+}
+
+func TestResolverDropsOutOfBailiwickData(t *testing.T) {
+	testCases := []struct {
+		name string
+		qname string
+		qtype RecordType
+		//Name servers sending the tampered responses.
+		servers []string
+		tamper func(request *Message, response *Message)
+		rcode ResponseCode
+		answers []string
+		//Records that must not be cached, as "name TYPE".
+		poisoned []string
+	}{
+		{
+			name: "answer for a name outside the zone of the server",
+			qname: "www.example.com.", qtype: TYPE_A, servers: []string{EXAMPLE_SERVER_1, EXAMPLE_SERVER_2},
+			tamper: func(request *Message, response *Message) {
+				response.AddAnswers([]Resource{*NewResourceRecord("www.victim.net.", 300, "IN", "A", ATTACKER_SERVER)})
+			},
+			rcode: RC_NOERROR, answers: []string{"www.example.com. A 203.0.113.10"}, poisoned: []string{"www.victim.net. A"},
+		},
+		{
+			name: "referral to a zone outside the zone of the server",
+			qname: "ipv4.example.com.", qtype: TYPE_TXT, servers: []string{EXAMPLE_SERVER_1, EXAMPLE_SERVER_2},
+			tamper: func(request *Message, response *Message) {
+				response.AddAuthorities([]Resource{*NewResourceRecord("victim.net.", 172800, "IN", "NS", "ns.victim.net.")})
+				addAdditional(response, "ns.victim.net. 172800 IN A " + ATTACKER_SERVER)
+			},
+			rcode: RC_NOERROR, poisoned: []string{"victim.net. NS", "ns.victim.net. A"},
+		},
+		{
+			name: "referral to a parent zone",
+			qname: "ipv4.example.com.", qtype: TYPE_TXT, servers: []string{EXAMPLE_SERVER_1, EXAMPLE_SERVER_2},
+			tamper: func(request *Message, response *Message) {
+				response.AddAuthorities([]Resource{*NewResourceRecord("com.", 172800, "IN", "NS", "ns.victim.net.")})
+				addAdditional(response, "ns.victim.net. 172800 IN A " + ATTACKER_SERVER)
+			},
+			rcode: RC_NOERROR, poisoned: []string{"ns.victim.net. A"},
+		},
+		{
+			name: "glue for a name server outside the zone of the server",
+			qname: "www.example.com.", qtype: TYPE_A, servers: []string{GTLD_SERVER},
+			tamper: func(request *Message, response *Message) {
+				NS_RRs, _ := response.FindAuthorityRecords(TYPE_NS)
+				if len(NS_RRs) == 0 || NS_RRs[0].Name.Value != "example.com." {
+					return
+				}
+				response.AddAuthorities([]Resource{*NewResourceRecord("example.com.", 172800, "IN", "NS", "ns.victim.net.")})
+				addAdditional(response, "ns.victim.net. 172800 IN A " + ATTACKER_SERVER, "www.victim.net. 172800 IN A " + ATTACKER_SERVER)
+			},
+			rcode: RC_NOERROR, answers: []string{"www.example.com. A 203.0.113.10"}, poisoned: []string{"ns.victim.net. A", "www.victim.net. A"},
+		},
+		{
+			name: "glue for a name server that is not part of the referral",
+			qname: "www.example.com.", qtype: TYPE_A, servers: []string{GTLD_SERVER},
+			tamper: func(request *Message, response *Message) {
+				NS_RRs, _ := response.FindAuthorityRecords(TYPE_NS)
+				if len(NS_RRs) == 0 || NS_RRs[0].Name.Value != "example.com." {
+					return
+				}
+				addAdditional(response, "www.example.com. 172800 IN A " + ATTACKER_SERVER)
+			},
+			rcode: RC_NOERROR, answers: []string{"www.example.com. A 203.0.113.10"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			hierarchy := newSimulatedHierarchy(t, hierarchyFixtures()...)
+			for _, server := range testCase.servers {
+				hierarchy.Exchanger.Handle(simulatedAddress(server), hierarchy.tamperedHandler(server, testCase.tamper))
+			}
+			hierarchy.Exchanger.Handle(simulatedAddress(ATTACKER_SERVER), func(request *Message) *Message {
+				t.Errorf("query for %s sent to the server of the attacker", request.Questions[0].Name.Value)
+				return nil
+			})
+
+			resolver := hierarchy.newResolver(t)
+			response := resolver.Query(testCase.qname, testCase.qtype)
+			answers := answerStrings(response)
+			if response.Header.Rcode != testCase.rcode || strings.Join(answers, "\n") != strings.Join(testCase.answers, "\n") {
+				t.Errorf("response code %s with answers %v, expected %s with answers %v", response.Header.Rcode.String(), answers, testCase.rcode.String(), testCase.answers)
+			}
+
+			for _, poisoned := range testCase.poisoned {
+				name, typeName, _ := strings.Cut(poisoned, WHITESPACE)
+				recType, _ := ParseRecordType(typeName)
+				if RRs, ok := resolver.Cache.Get(name, recType); ok {
+					t.Errorf("%s type records of %s were cached: %v", typeName, name, RRs)
+				}
+			}
+			cached, _ := resolver.Cache.Get("www.example.com.", TYPE_A)
+			for _, rr := range cached {
+				if rr.GetData() == ATTACKER_SERVER {
+					t.Errorf("forged address of www.example.com. was cached")
+				}
+			}
+		})
+	}
+}
+
+//IPv6 addresses of the simulated name servers of the dual-stack hierarchy.
+const (
+	ROOT_SERVER_V6 = "2001:503:ba3e::2:30"
+	GTLD_SERVER_V6 = "2001:503:a83e::2:30"
+	EXAMPLE_SERVER_V6 = "2001:db8::2"
+	V6ONLY_SERVER = "2001:db8::6"
+)
+
+//Returns the zone fixtures of a hierarchy whose root, com. and example.com. servers are reachable over both IPv4 and IPv6, and whose
+//v6only.com. zone is delegated to a name server with AAAA glue only.
+func dualStackFixtures() []zoneFixture {
+	return []zoneFixture{
+		{Origin: ".", Servers: []string{ROOT_SERVER, ROOT_SERVER_V6}, Records: `
+$TTL 86400
+@                   SOA  a.root-servers.net. nstld.verisign-grs.com. 1 1800 900 604800 86400
+@                   NS   a.root-servers.net.
+com.                NS   a.gtld-servers.net.
+a.gtld-servers.net. A    192.5.6.30
+a.gtld-servers.net. AAAA 2001:503:a83e::2:30
+`},
+		{Origin: "com.", Servers: []string{GTLD_SERVER, GTLD_SERVER_V6}, Records: `
+$TTL 172800
+@            SOA  a.gtld-servers.net. nstld.verisign-grs.com. 1 1800 900 604800 86400
+@            NS   a.gtld-servers.net.
+example      NS   ns1.example
+example      NS   ns2.example
+ns1.example  A    192.0.2.1
+ns2.example  AAAA 2001:db8::2
+v6only       NS   ns.v6only
+ns.v6only    AAAA 2001:db8::6
+`},
+		{Origin: "example.com.", Servers: []string{EXAMPLE_SERVER_1, EXAMPLE_SERVER_V6}, Records: `
+$TTL 300
+@         SOA   ns1 hostmaster 1 3600 900 604800 300
+@         NS    ns1
+@         NS    ns2
+ns1       A     192.0.2.1
+ns2       AAAA  2001:db8::2
+www       A     203.0.113.10
+`},
+		{Origin: "v6only.com.", Servers: []string{V6ONLY_SERVER}, Records: `
+$TTL 300
+@         SOA   ns hostmaster 1 3600 900 604800 300
+@         NS    ns
+ns        AAAA  2001:db8::6
+www       AAAA  2001:db8::60
+`},
+	}
+}
+
+func TestResolverTransports(t *testing.T) {
+	ipv4Servers := []string{ROOT_SERVER, GTLD_SERVER, EXAMPLE_SERVER_1}
+	ipv6Servers := []string{ROOT_SERVER_V6, GTLD_SERVER_V6, EXAMPLE_SERVER_V6, V6ONLY_SERVER}
+	testCases := []struct {
+		name string
+		transport string
+		qname string
+		qtype RecordType
+		faults map[string]serverFault
+		rcode ResponseCode
+		answers []string
+		//Address family that must not have been queried, if any.
+		unused []string
+	}{
+		{
+			name: "IPv4 transport",
+			transport: TRANSPORT_IPv4, qname: "www.example.com.", qtype: TYPE_A, rcode: RC_NOERROR,
+			answers: []string{"www.example.com. A 203.0.113.10"}, unused: ipv6Servers,
+		},
+		{
+			name: "IPv6 transport",
+			transport: TRANSPORT_IPv6, qname: "www.example.com.", qtype: TYPE_A, rcode: RC_NOERROR,
+			answers: []string{"www.example.com. A 203.0.113.10"}, unused: ipv4Servers,
+		},
+		{
+			name: "IPv6 transport with AAAA glue only",
+			transport: TRANSPORT_IPv6, qname: "www.v6only.com.", qtype: TYPE_AAAA, rcode: RC_NOERROR,
+			answers: []string{"www.v6only.com. AAAA 2001:db8::60"}, unused: ipv4Servers,
+		},
+		{
+			name: "both transports with AAAA glue only",
+			transport: TRANSPORT_BOTH, qname: "www.v6only.com.", qtype: TYPE_AAAA, rcode: RC_NOERROR,
+			answers: []string{"www.v6only.com. AAAA 2001:db8::60"},
+		},
+		{
+			name: "IPv6 transport with the IPv6 server of the zone failing",
+			transport: TRANSPORT_IPv6, qname: "www.example.com.", qtype: TYPE_A, faults: map[string]serverFault{EXAMPLE_SERVER_V6: FAULT_TIMEOUT}, rcode: RC_SERVFAIL,
+		},
+		{
+			name: "both transports with the IPv6 servers timing out",
+			transport: TRANSPORT_BOTH, qname: "www.example.com.", qtype: TYPE_A, rcode: RC_NOERROR,
+			faults: map[string]serverFault{ROOT_SERVER_V6: FAULT_TIMEOUT, GTLD_SERVER_V6: FAULT_TIMEOUT, EXAMPLE_SERVER_V6: FAULT_TIMEOUT},
+			answers: []string{"www.example.com. A 203.0.113.10"},
+		},
+		{
+			name: "both transports with the IPv4 servers failing",
+			transport: TRANSPORT_BOTH, qname: "www.example.com.", qtype: TYPE_A, rcode: RC_NOERROR,
+			faults: map[string]serverFault{ROOT_SERVER: FAULT_TIMEOUT, GTLD_SERVER: FAULT_REFUSED, EXAMPLE_SERVER_1: FAULT_SERVER_FAILURE},
+			answers: []string{"www.example.com. A 203.0.113.10"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			hierarchy := newSimulatedHierarchy(t, dualStackFixtures()...)
+			for server, fault := range testCase.faults {
+				hierarchy.SetFault(server, fault)
+			}
+			resolver := hierarchy.newResolver(t)
+			err := resolver.SetTransport(testCase.transport)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			response := resolver.Query(testCase.qname, testCase.qtype)
+			answers := answerStrings(response)
+			if response.Header.Rcode != testCase.rcode || strings.Join(answers, "\n") != strings.Join(testCase.answers, "\n") {
+				t.Errorf("response code %s with answers %v, expected %s with answers %v", response.Header.Rcode.String(), answers, testCase.rcode.String(), testCase.answers)
+			}
+			for _, server := range testCase.unused {
+				if queries := hierarchy.Queries(server); len(queries) > 0 {
+					t.Errorf("%s received %v over a transport that is not in use", server, queries)
+				}
+			}
+		})
+	}
+}
+
+func TestResolverRacesAddressFamilies(t *testing.T) {
+	hierarchy := newSimulatedHierarchy(t, dualStackFixtures()...)
+	//Whichever server of example.com. is queried first answers too late, so that the server of the other address family joins in.
+	var queried atomic.Int32
+	raced := make(map[string]*atomic.Int32)
+	for _, server := range []string{EXAMPLE_SERVER_1, EXAMPLE_SERVER_V6} {
+		handler, count := hierarchy.handler(simulatedAddress(server)), &atomic.Int32{}
+		raced[server] = count
+		hierarchy.Exchanger.Handle(simulatedAddress(server), func(request *Message) *Message {
+			if request.Questions[0].Name.Value == "www.example.com." {
+				count.Add(1)
+				if queried.Add(1) == 1 {
+					time.Sleep(10 * HAPPY_EYEBALLS_DELAY)
+				}
+			}
+			return handler(request)
+		})
+	}
+
+	resolver := hierarchy.newResolver(t)
+	err := resolver.SetTransport(TRANSPORT_BOTH)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver.SetQnameMinimisation(false)
+
+	start := time.Now()
+	response := resolver.Query("www.example.com.", TYPE_A)
+	elapsed := time.Since(start)
+	if answers := answerStrings(response); len(answers) != 1 || answers[0] != "www.example.com. A 203.0.113.10" {
+		t.Fatalf("answers %v, expected the A record of www.example.com.", answers)
+	}
+	if raced[EXAMPLE_SERVER_1].Load() != 1 || raced[EXAMPLE_SERVER_V6].Load() != 1 {
+		t.Errorf("%d queries over IPv4 and %d over IPv6, expected both servers to be raced", raced[EXAMPLE_SERVER_1].Load(), raced[EXAMPLE_SERVER_V6].Load())
+	}
+	if elapsed >= 10 * HAPPY_EYEBALLS_DELAY {
+		t.Errorf("answered after %s, expected the faster server to win the race", elapsed)
+	}
+}
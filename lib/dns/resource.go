@@ -1,14 +1,21 @@
 package dns
 
 import (
+	"bytes"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 //Feature(s) to be implemented for a DNS Resource Body.
 type ResourceBody interface {
 	//Unpacks a stream of bytes into a resource record object.
-	UnpackBody(buffer []byte, offset int, dataLength int) int
+	UnpackBody(buffer []byte, offset int, dataLength int) (int, error)
 }
 
 //Represents a Resource Record in DNS.
@@ -61,6 +68,57 @@ func (resource *Resource) Initialize(domainName string, recType string, classTyp
 		txt.TextValue = strings.TrimSpace(data)
 		resource.RdLength = uint16(len(data))
 		resource.Rdata = &txt
+	} else if resource.Type == TYPE_DNAME {
+		dname := DNAMEResource{}
+		dname.Target = DomainName{}
+		dname.Target.Initialize(data)
+		resource.RdLength = uint16(dname.Target.GetLength())
+		resource.Rdata = &dname
+	} else if resource.Type == TYPE_SVCB || resource.Type == TYPE_HTTPS {
+		svcb := SVCBResource{}
+		svcb.Initialize(data)
+		resource.RdLength = uint16(len(svcb.Pack()))
+		resource.Rdata = &svcb
+	} else if resource.Type == TYPE_CAA {
+		caa := CAAResource{}
+		caa.Initialize(data)
+		resource.RdLength = uint16(len(caa.Pack()))
+		resource.Rdata = &caa
+	} else if resource.Type == TYPE_SOA {
+		soa := SOAResource{}
+		soa.Initialize(data)
+		resource.RdLength = uint16(soa.MName.GetLength() + soa.RName.GetLength() + 20)
+		resource.Rdata = &soa
+	} else if resource.Type == TYPE_DNSKEY {
+		dnskey := DNSKEYResource{}
+		dnskey.Initialize(data)
+		resource.RdLength = uint16(len(dnskey.Pack()))
+		resource.Rdata = &dnskey
+	} else if resource.Type == TYPE_DS {
+		ds := DSResource{}
+		ds.Initialize(data)
+		resource.RdLength = uint16(len(ds.Pack()))
+		resource.Rdata = &ds
+	} else if resource.Type == TYPE_RRSIG {
+		rrsig := RRSIGResource{}
+		rrsig.Initialize(data)
+		resource.RdLength = uint16(len(rrsig.Pack()))
+		resource.Rdata = &rrsig
+	} else if resource.Type == TYPE_NSEC {
+		nsec := NSECResource{}
+		nsec.Initialize(data)
+		resource.RdLength = uint16(len(nsec.Pack()))
+		resource.Rdata = &nsec
+	} else if resource.Type == TYPE_NSEC3 {
+		nsec3 := NSEC3Resource{}
+		nsec3.Initialize(data)
+		resource.RdLength = uint16(len(nsec3.Pack()))
+		resource.Rdata = &nsec3
+	} else if resource.Type == TYPE_NSEC3PARAM {
+		nsec3param := NSEC3PARAMResource{}
+		nsec3param.Initialize(data)
+		resource.RdLength = uint16(len(nsec3param.Pack()))
+		resource.Rdata = &nsec3param
 	}
 }
 
@@ -73,10 +131,36 @@ func (resource *Resource) PackBody(compressionMap CompressionMap, offset int) []
 	} else if resource.Type == TYPE_AAAA {
 		resourceData := resource.GetData()
 		buffer = append(buffer, convertToBytes(resourceData,  ADDRESS_IPv6)...)
-	} else if resource.Type == TYPE_CNAME || resource.Type == TYPE_NS {
-		buffer = append(buffer, resource.Name.Pack(compressionMap, offset)...)
+	} else if obj, ok := resource.Rdata.(*CNAMEResource); ok {
+		buffer = append(buffer, obj.name.Pack(compressionMap, offset)...)
+	} else if obj, ok := resource.Rdata.(*NSResource); ok {
+		buffer = append(buffer, obj.NameServer.Pack(compressionMap, offset)...)
 	} else if resource.Type == TYPE_TXT {
 		buffer = append(buffer, []byte(resource.GetData())...)
+	} else if obj, ok := resource.Rdata.(*DNAMEResource); ok {
+		buffer = append(buffer, obj.Target.Pack(compressionMap, offset)...)
+	} else if obj, ok := resource.Rdata.(*SVCBResource); ok {
+		buffer = append(buffer, obj.Pack()...)
+	} else if obj, ok := resource.Rdata.(*CAAResource); ok {
+		buffer = append(buffer, obj.Pack()...)
+	} else if obj, ok := resource.Rdata.(*SOAResource); ok {
+		buffer = append(buffer, obj.Pack(compressionMap, offset)...)
+	} else if obj, ok := resource.Rdata.(*DNSKEYResource); ok {
+		buffer = append(buffer, obj.Pack()...)
+	} else if obj, ok := resource.Rdata.(*DSResource); ok {
+		buffer = append(buffer, obj.Pack()...)
+	} else if obj, ok := resource.Rdata.(*RRSIGResource); ok {
+		buffer = append(buffer, obj.Pack()...)
+	} else if obj, ok := resource.Rdata.(*NSECResource); ok {
+		buffer = append(buffer, obj.Pack()...)
+	} else if obj, ok := resource.Rdata.(*NSEC3Resource); ok {
+		buffer = append(buffer, obj.Pack()...)
+	} else if obj, ok := resource.Rdata.(*NSEC3PARAMResource); ok {
+		buffer = append(buffer, obj.Pack()...)
+	} else if obj, ok := resource.Rdata.(*OPTResource); ok {
+		buffer = append(buffer, obj.Pack()...)
+	} else if obj, ok := resource.Rdata.(*UnknownResource); ok {
+		buffer = append(buffer, obj.Data...)
 	}
 
 	return buffer
@@ -89,42 +173,171 @@ func (resource *Resource) Pack(compressionMap CompressionMap, offset int) []byte
 	buffer = append(buffer, PackUInt16(uint16(resource.Type))...)
 	buffer = append(buffer, PackUInt16(uint16(resource.Class))...)
 	buffer = append(buffer, PackUInt32(uint32(resource.TTL))...)
+	//The record data may shrink once the domain names in it are compressed, so the length is taken from the packed record data.
+	body := resource.PackBody(compressionMap, offset + len(buffer) + 2)
+	resource.RdLength = uint16(len(body))
 	buffer = append(buffer, PackUInt16(resource.RdLength)...)
-	offset = offset + len(buffer)
-	buffer = append(buffer, resource.PackBody(compressionMap, offset)...)
+	buffer = append(buffer, body...)
+	return buffer
+}
+
+//Packs the resource data in the canonical form of RFC 4034 - Section 6.2, where the domain names embedded in the record data of
+//the types listed there are in lowercase and no domain name is compressed.
+func (resource *Resource) CanonicalBody() []byte {
+	if obj, ok := resource.Rdata.(*CNAMEResource); ok {
+		return packCanonicalName(obj.name.Value)
+	} else if obj, ok := resource.Rdata.(*NSResource); ok {
+		return packCanonicalName(obj.NameServer.Value)
+	} else if obj, ok := resource.Rdata.(*DNAMEResource); ok {
+		return packCanonicalName(obj.Target.Value)
+	} else if obj, ok := resource.Rdata.(*SOAResource); ok {
+		buffer := make([]byte, 0)
+		buffer = append(buffer, packCanonicalName(obj.MName.Value)...)
+		buffer = append(buffer, packCanonicalName(obj.RName.Value)...)
+		for _, value := range []uint32{obj.Serial, obj.Refresh, obj.Retry, obj.Expire, obj.Minimum} {
+			buffer = append(buffer, PackUInt32(value)...)
+		}
+		return buffer
+	} else {
+		//The record data of the remaining types carries no compressed names, and the next domain name of NSEC records keeps
+		//its letter case as per RFC 6840 - Section 5.1.
+		return resource.PackBody(make(CompressionMap), 0)
+	}
+}
+
+//Packs the resource in the canonical form of RFC 4034 - Section 6.2, with the owner name in lowercase and the TTL replaced
+//by the given original TTL, as it is covered by a signature.
+func (resource *Resource) CanonicalPack(originalTTL uint32) []byte {
+	body := resource.CanonicalBody()
+	buffer := make([]byte, 0)
+	buffer = append(buffer, packCanonicalName(resource.Name.Value)...)
+	buffer = append(buffer, PackUInt16(uint16(resource.Type))...)
+	buffer = append(buffer, PackUInt16(uint16(resource.Class))...)
+	buffer = append(buffer, PackUInt32(originalTTL)...)
+	buffer = append(buffer, PackUInt16(uint16(len(body)))...)
+	buffer = append(buffer, body...)
+	return buffer
+}
+
+//Packs the given RRset in the canonical form of RFC 4034 - Section 6.3, where the records are sorted by their canonical record
+//data and duplicate records are removed. This is the form of the RRset that is covered by a RRSIG record.
+func CanonicalRRSet(resources []Resource, originalTTL uint32) []byte {
+	indices := make([]int, 0, len(resources))
+	bodies := make(map[int][]byte)
+	for index := range resources {
+		bodies[index] = resources[index].CanonicalBody()
+		indices = append(indices, index)
+	}
+
+	slices.SortStableFunc(indices, func(first int, second int) int {
+		return bytes.Compare(bodies[first], bodies[second])
+	})
+	buffer := make([]byte, 0)
+	for position, index := range indices {
+		if position > 0 && bytes.Equal(bodies[index], bodies[indices[position - 1]]) {
+			continue
+		}
+		buffer = append(buffer, resources[index].CanonicalPack(originalTTL)...)
+	}
 	return buffer
 }
 
-//Unpacks a stream of bytes to a resource instance.
-func (resource *Resource) Unpack(buffer []byte, offset int) int {
-	offset = resource.Name.Unpack(buffer, offset)
+//Unpacks a stream of bytes to a resource instance. Returns ErrMalformedMessage if the resource record does not fit in the byte stream
+//or its owner name or record data cannot be parsed.
+func (resource *Resource) Unpack(buffer []byte, offset int) (int, error) {
+	offset, err := resource.Name.Unpack(buffer, offset)
+	if err != nil {
+		return offset, err
+	} else if offset + 10 > len(buffer) {
+		return offset, fmt.Errorf("%w: resource record of %s runs past the end of the message", ErrMalformedMessage, resource.Name.Value)
+	}
 	resource.Type = RecordType(UnpackUInt16(buffer[offset: offset + 2]))
 	resource.Class = ClassType(UnpackUInt16(buffer[offset + 2: offset + 4]))
 	resource.TTL = UnpackUInt32(buffer[offset + 4: offset + 8])
 	resource.RdLength = UnpackUInt16(buffer[offset + 8: offset + 10])
+	if offset + 10 + int(resource.RdLength) > len(buffer) {
+		return offset, fmt.Errorf("%w: record data of %s runs past the end of the message", ErrMalformedMessage, resource.Name.Value)
+	}
 	if resource.Type == TYPE_A {
 		ar := AResource{}
-		offset = ar.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		offset, err = ar.UnpackBody(buffer, offset + 10, int(resource.RdLength))
 		resource.Rdata = &ar
 	} else if resource.Type == TYPE_AAAA {
 		aaar := AAAAResource{}
-		offset = aaar.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		offset, err = aaar.UnpackBody(buffer, offset + 10, int(resource.RdLength))
 		resource.Rdata = &aaar
 	} else if resource.Type == TYPE_CNAME {
 		cname := CNAMEResource{}
-		offset = cname.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		offset, err = cname.UnpackBody(buffer, offset + 10, int(resource.RdLength))
 		resource.Rdata = &cname
 	} else if resource.Type == TYPE_NS {
 		ns := NSResource{}
-		offset = ns.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		offset, err = ns.UnpackBody(buffer, offset + 10, int(resource.RdLength))
 		resource.Rdata = &ns
 	} else if resource.Type == TYPE_TXT {
 		txt := TXTResource{}
-		offset = txt.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		offset, err = txt.UnpackBody(buffer, offset + 10, int(resource.RdLength))
 		resource.Rdata = &txt
+	} else if resource.Type == TYPE_DNAME {
+		dname := DNAMEResource{}
+		offset, err = dname.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		resource.Rdata = &dname
+	} else if resource.Type == TYPE_SVCB || resource.Type == TYPE_HTTPS {
+		svcb := SVCBResource{}
+		offset, err = svcb.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		resource.Rdata = &svcb
+	} else if resource.Type == TYPE_CAA {
+		caa := CAAResource{}
+		offset, err = caa.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		resource.Rdata = &caa
+	} else if resource.Type == TYPE_SOA {
+		soa := SOAResource{}
+		offset, err = soa.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		resource.Rdata = &soa
+	} else if resource.Type == TYPE_DNSKEY {
+		dnskey := DNSKEYResource{}
+		offset, err = dnskey.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		resource.Rdata = &dnskey
+	} else if resource.Type == TYPE_DS {
+		ds := DSResource{}
+		offset, err = ds.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		resource.Rdata = &ds
+	} else if resource.Type == TYPE_RRSIG {
+		rrsig := RRSIGResource{}
+		offset, err = rrsig.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		resource.Rdata = &rrsig
+	} else if resource.Type == TYPE_NSEC {
+		nsec := NSECResource{}
+		offset, err = nsec.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		resource.Rdata = &nsec
+	} else if resource.Type == TYPE_NSEC3 {
+		nsec3 := NSEC3Resource{}
+		offset, err = nsec3.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		resource.Rdata = &nsec3
+	} else if resource.Type == TYPE_NSEC3PARAM {
+		nsec3param := NSEC3PARAMResource{}
+		offset, err = nsec3param.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		resource.Rdata = &nsec3param
+	} else if resource.Type == TYPE_OPT {
+		opt := OPTResource{}
+		offset, err = opt.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		resource.Rdata = &opt
+	} else {
+		unknown := UnknownResource{}
+		offset, err = unknown.UnpackBody(buffer, offset + 10, int(resource.RdLength))
+		resource.Rdata = &unknown
 	}
 
-	return offset
+	return offset, err
+}
+
+//Returns ErrMalformedMessage if the 'length' octets of record data of the given type starting at 'offset' run past the end of the
+//record data at 'endOffset', or if the record data runs past the end of the message.
+func checkRecordData(buffer []byte, recType RecordType, offset int, length int, endOffset int) error {
+	if length < 0 || offset + length > endOffset || endOffset > len(buffer) {
+		return fmt.Errorf("%w: %s record data is shorter than its fields", ErrMalformedMessage, recType.String())
+	}
+	return nil
 }
 
 //Returns a string representation of the Resource instance.
@@ -140,6 +353,30 @@ func (resource *Resource) String() string {
 		value_string = obj.String()
 	} else if obj, ok := resource.Rdata.(*TXTResource); ok {
 		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*DNAMEResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*SVCBResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*CAAResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*SOAResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*DNSKEYResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*DSResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*RRSIGResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*NSECResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*NSEC3Resource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*NSEC3PARAMResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*OPTResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*UnknownResource); ok {
+		value_string = obj.String()
 	} else {
 		value_string = ""
 	}
@@ -159,6 +396,30 @@ func (resource *Resource) CacheString() string {
 		value_string = obj.String()
 	} else if obj, ok := resource.Rdata.(*TXTResource); ok {
 		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*DNAMEResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*SVCBResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*CAAResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*SOAResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*DNSKEYResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*DSResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*RRSIGResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*NSECResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*NSEC3Resource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*NSEC3PARAMResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*OPTResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*UnknownResource); ok {
+		value_string = obj.String()
 	} else {
 		value_string = ""
 	}
@@ -186,6 +447,30 @@ func (resource *Resource) GetData() string {
 		value_string = obj.NameServer.Value
 	} else if obj, ok := resource.Rdata.(*TXTResource); ok {
 		value_string = obj.TextValue
+	} else if obj, ok := resource.Rdata.(*DNAMEResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*SVCBResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*CAAResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*SOAResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*DNSKEYResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*DSResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*RRSIGResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*NSECResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*NSEC3Resource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*NSEC3PARAMResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*OPTResource); ok {
+		value_string = obj.String()
+	} else if obj, ok := resource.Rdata.(*UnknownResource); ok {
+		value_string = obj.String()
 	} else {
 		value_string = ""
 	}
@@ -198,10 +483,10 @@ type AResource struct {
 }
 
 //Unpacks a stream of bytes into a A-type resource record value.
-func (ar *AResource) UnpackBody(buffer []byte, offset int, dataLength int) int {
+func (ar *AResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
 	ipBytes := buffer[offset: offset + dataLength]
 	ar.IPv4Address = getIPAddress(ipBytes)
-	return offset + dataLength
+	return offset + dataLength, nil
 }
 
 //Returns the string representation of A-type record data
@@ -215,10 +500,10 @@ type AAAAResource struct {
 }
 
 //Unpacks a stream of bytes into a AAAA-type resource record value.
-func (aaaar *AAAAResource) UnpackBody(buffer []byte, offset int, dataLength int) int {
+func (aaaar *AAAAResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
 	ipBytes := buffer[offset: offset + dataLength]
 	aaaar.IPv6Address = getIPAddress(ipBytes)
-	return offset + dataLength
+	return offset + dataLength, nil
 }
 
 //Returns the string representation of AAAA-type data.
@@ -232,10 +517,9 @@ type CNAMEResource struct {
 }
 
 //Unpacks a stream of bytes into a CNAME-type resource record value.
-func (cname *CNAMEResource) UnpackBody(buffer []byte, offset int, dataLength int) int {
+func (cname *CNAMEResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
 	cname.name = DomainName{}
-	offset = cname.name.Unpack(buffer, offset)
-	return offset
+	return cname.name.Unpack(buffer, offset)
 }
 
 //Returns the string representation of CNAME-type record value.
@@ -249,9 +533,8 @@ type NSResource struct {
 }
 
 //Unpacks a stream of bytes into a NS-type resource record value.
-func (ns *NSResource) UnpackBody(buffer []byte, offset int, dataLength int) int {
-	offset = ns.NameServer.Unpack(buffer, offset)
-	return offset
+func (ns *NSResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
+	return ns.NameServer.Unpack(buffer, offset)
 }
 
 //Returns the string representation of NS-type record value.
@@ -265,14 +548,790 @@ type TXTResource struct {
 }
 
 //Unpacks a stream of bytes into a TXT-type resource record value.
-func (txt *TXTResource) UnpackBody(buffer []byte, offset int, dataLength int) int {
+func (txt *TXTResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
 	txtByteSlice := buffer[offset: offset + int(dataLength)]
 	offset = offset + int(dataLength)
 	txt.TextValue = string(txtByteSlice)
-	return offset
+	return offset, nil
 }
 
 //Returns the TXT value.
 func (txt *TXTResource) String() string {
 	return txt.TextValue
+}
+
+//Represents a DNAME-type Resource Record body, which aliases every domain name below its owner to the same name below the target (RFC 6672).
+type DNAMEResource struct {
+	Target DomainName
+}
+
+//Unpacks a stream of bytes into a DNAME-type resource record value.
+func (dname *DNAMEResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
+	dname.Target = DomainName{}
+	return dname.Target.Unpack(buffer, offset)
+}
+
+//Returns the string representation of DNAME-type record value.
+func (dname *DNAMEResource) String() string {
+	return dname.Target.String()
+}
+
+//Represents a CAA-type Resource Record body, which lists the certification authorities allowed to issue certificates for a domain name (RFC 8659).
+type CAAResource struct {
+	//Flags of the property, of which only the issuer critical flag is defined.
+	Flags uint8
+	//Property tag, such as issue, issuewild or iodef.
+	Tag string
+	//Property value.
+	Value string
+}
+
+//Initializes the CAA-type record value from its presentation format - 'flags tag "value"'.
+func (caa *CAAResource) Initialize(data string) {
+	fields := strings.SplitN(strings.TrimSpace(data), WHITESPACE, 3)
+	for len(fields) < 3 {
+		fields = append(fields, "")
+	}
+	flags, _ := strconv.ParseUint(fields[0], 10, 8)
+	caa.Flags = uint8(flags)
+	caa.Tag = fields[1]
+	value := strings.TrimSpace(fields[2])
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		value = unquoted
+	}
+	caa.Value = value
+}
+
+//Packs the CAA-type record value into a stream of bytes.
+func (caa *CAAResource) Pack() []byte {
+	buffer := make([]byte, 0)
+	buffer = append(buffer, caa.Flags, byte(len(caa.Tag)))
+	buffer = append(buffer, []byte(caa.Tag)...)
+	buffer = append(buffer, []byte(caa.Value)...)
+	return buffer
+}
+
+//Unpacks a stream of bytes into a CAA-type resource record value.
+func (caa *CAAResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
+	endOffset := offset + dataLength
+	err := checkRecordData(buffer, TYPE_CAA, offset, 2, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	caa.Flags = buffer[offset]
+	tagLength := int(buffer[offset + 1])
+	err = checkRecordData(buffer, TYPE_CAA, offset + 2, tagLength, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	caa.Tag = string(buffer[offset + 2: offset + 2 + tagLength])
+	caa.Value = string(buffer[offset + 2 + tagLength: endOffset])
+	return endOffset, nil
+}
+
+//Returns true if the issuer critical flag is set, in which case a certification authority that does not understand the tag must not issue.
+func (caa *CAAResource) IsCritical() bool {
+	return caa.Flags & CAA_CRITICAL_FLAG != 0
+}
+
+//Returns the string representation of CAA-type record value, with the value quoted.
+func (caa *CAAResource) String() string {
+	return fmt.Sprintf("%d %s %s", caa.Flags, caa.Tag, strconv.Quote(caa.Value))
+}
+
+//Represents a SOA-type Resource Record body.
+type SOAResource struct {
+	//Name server that is the primary source of data for the zone.
+	MName DomainName
+	//Mailbox of the person responsible for the zone.
+	RName DomainName
+	//Version number of the zone.
+	Serial uint32
+	//Interval (in seconds) before the zone should be refreshed by secondary servers.
+	Refresh uint32
+	//Interval (in seconds) before a failed refresh should be retried.
+	Retry uint32
+	//Time (in seconds) after which the zone is no longer authoritative if it cannot be refreshed.
+	Expire uint32
+	//TTL (in seconds) of negative responses from the zone, as per RFC 2308.
+	Minimum uint32
+}
+
+//Initializes the SOA-type record value from its presentation format - "mname rname serial refresh retry expire minimum".
+func (soa *SOAResource) Initialize(data string) {
+	fields := strings.Fields(data)
+	for len(fields) < 7 {
+		fields = append(fields, "0")
+	}
+	soa.MName.Initialize(fields[0])
+	soa.RName.Initialize(fields[1])
+	values := make([]uint32, 5)
+	for index := range values {
+		value, _ := strconv.ParseUint(fields[index + 2], 10, 32)
+		values[index] = uint32(value)
+	}
+	soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.Minimum = values[0], values[1], values[2], values[3], values[4]
+}
+
+//Packs the SOA-type record value into a stream of bytes.
+func (soa *SOAResource) Pack(compressionMap CompressionMap, offset int) []byte {
+	buffer := make([]byte, 0)
+	buffer = append(buffer, soa.MName.Pack(compressionMap, offset)...)
+	buffer = append(buffer, soa.RName.Pack(compressionMap, offset + len(buffer))...)
+	for _, value := range []uint32{soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.Minimum} {
+		buffer = append(buffer, PackUInt32(value)...)
+	}
+	return buffer
+}
+
+//Unpacks a stream of bytes into a SOA-type resource record value.
+func (soa *SOAResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
+	endOffset := offset + dataLength
+	offset, err := soa.MName.Unpack(buffer, offset)
+	if err != nil {
+		return offset, err
+	}
+	offset, err = soa.RName.Unpack(buffer, offset)
+	if err != nil {
+		return offset, err
+	}
+	err = checkRecordData(buffer, TYPE_SOA, offset, 20, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	soa.Serial = UnpackUInt32(buffer[offset: offset + 4])
+	soa.Refresh = UnpackUInt32(buffer[offset + 4: offset + 8])
+	soa.Retry = UnpackUInt32(buffer[offset + 8: offset + 12])
+	soa.Expire = UnpackUInt32(buffer[offset + 12: offset + 16])
+	soa.Minimum = UnpackUInt32(buffer[offset + 16: offset + 20])
+	return endOffset, nil
+}
+
+//Returns the string representation of SOA-type record value.
+func (soa *SOAResource) String() string {
+	return fmt.Sprintf("%s %s %d %d %d %d %d", soa.MName.String(), soa.RName.String(), soa.Serial, soa.Refresh, soa.Retry, soa.Expire, soa.Minimum)
+}
+
+//Represents a DNSKEY-type Resource Record body, which holds a public key used to verify the signatures of a zone (RFC 4034 - Section 2).
+type DNSKEYResource struct {
+	//Flags of the key, of which the zone key and secure entry point flags are defined.
+	Flags uint16
+	//Protocol of the key, which must always be 3.
+	Protocol uint8
+	//Cryptographic algorithm of the key.
+	Algorithm uint8
+	//Public key material, in the format defined by the algorithm.
+	PublicKey []byte
+}
+
+//Initializes the DNSKEY-type record value from its presentation format - "flags protocol algorithm base64-key".
+func (dnskey *DNSKEYResource) Initialize(data string) {
+	fields := strings.Fields(data)
+	for len(fields) < 4 {
+		fields = append(fields, "")
+	}
+	dnskey.Flags = uint16(parseUIntString(fields[0], 16))
+	dnskey.Protocol = uint8(parseUIntString(fields[1], 8))
+	dnskey.Algorithm = uint8(parseUIntString(fields[2], 8))
+	dnskey.PublicKey, _ = base64.StdEncoding.DecodeString(strings.Join(fields[3:], ""))
+}
+
+//Packs the DNSKEY-type record value into a stream of bytes.
+func (dnskey *DNSKEYResource) Pack() []byte {
+	buffer := make([]byte, 0)
+	buffer = append(buffer, PackUInt16(dnskey.Flags)...)
+	buffer = append(buffer, dnskey.Protocol, dnskey.Algorithm)
+	buffer = append(buffer, dnskey.PublicKey...)
+	return buffer
+}
+
+//Unpacks a stream of bytes into a DNSKEY-type resource record value.
+func (dnskey *DNSKEYResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
+	endOffset := offset + dataLength
+	err := checkRecordData(buffer, TYPE_DNSKEY, offset, 4, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	dnskey.Flags = UnpackUInt16(buffer[offset: offset + 2])
+	dnskey.Protocol = buffer[offset + 2]
+	dnskey.Algorithm = buffer[offset + 3]
+	dnskey.PublicKey = append([]byte{}, buffer[offset + 4: endOffset]...)
+	return endOffset, nil
+}
+
+//Returns true if the key is a zone key, which is the only kind of key that can sign the records of a zone.
+func (dnskey *DNSKEYResource) IsZoneKey() bool {
+	return dnskey.Flags & DNSKEY_ZONE_KEY_FLAG != 0
+}
+
+//Returns true if the key is marked as a secure entry point, as is usually the case for key signing keys.
+func (dnskey *DNSKEYResource) IsSecureEntryPoint() bool {
+	return dnskey.Flags & DNSKEY_SEP_FLAG != 0
+}
+
+//Returns true if the key has been revoked by its owner (RFC 5011 - Section 2.1) and must no longer be trusted.
+func (dnskey *DNSKEYResource) IsRevoked() bool {
+	return dnskey.Flags & DNSKEY_REVOKE_FLAG != 0
+}
+
+//Returns the key tag identifying the key, as computed in RFC 4034 - Appendix B.
+func (dnskey *DNSKEYResource) KeyTag() uint16 {
+	rdata := dnskey.Pack()
+	if dnskey.Algorithm == 1 {
+		//Keys of the deprecated RSA/MD5 algorithm use the third and second to last octets of the modulus.
+		if len(rdata) < 4 {
+			return 0
+		}
+		return UnpackUInt16(rdata[len(rdata) - 3: len(rdata) - 1])
+	}
+
+	var accumulator uint32
+	for index, value := range rdata {
+		if index & 1 == 0 {
+			accumulator += uint32(value) << 8
+		} else {
+			accumulator += uint32(value)
+		}
+	}
+	accumulator += (accumulator >> 16) & 0xFFFF
+	return uint16(accumulator & 0xFFFF)
+}
+
+//Returns the string representation of DNSKEY-type record value.
+func (dnskey *DNSKEYResource) String() string {
+	return fmt.Sprintf("%d %d %d %s", dnskey.Flags, dnskey.Protocol, dnskey.Algorithm, base64.StdEncoding.EncodeToString(dnskey.PublicKey))
+}
+
+//Represents a DS-type Resource Record body, which holds the digest of a DNSKEY record of a child zone in its parent zone (RFC 4034 - Section 5).
+type DSResource struct {
+	//Key tag of the DNSKEY record the digest refers to.
+	KeyTag uint16
+	//Algorithm of the DNSKEY record the digest refers to.
+	Algorithm uint8
+	//Algorithm used to compute the digest.
+	DigestType uint8
+	//Digest of the owner name and record data of the DNSKEY record.
+	Digest []byte
+}
+
+//Initializes the DS-type record value from its presentation format - "keytag algorithm digest-type hex-digest".
+func (ds *DSResource) Initialize(data string) {
+	fields := strings.Fields(data)
+	for len(fields) < 4 {
+		fields = append(fields, "")
+	}
+	ds.KeyTag = uint16(parseUIntString(fields[0], 16))
+	ds.Algorithm = uint8(parseUIntString(fields[1], 8))
+	ds.DigestType = uint8(parseUIntString(fields[2], 8))
+	ds.Digest, _ = hex.DecodeString(strings.Join(fields[3:], ""))
+}
+
+//Packs the DS-type record value into a stream of bytes.
+func (ds *DSResource) Pack() []byte {
+	buffer := make([]byte, 0)
+	buffer = append(buffer, PackUInt16(ds.KeyTag)...)
+	buffer = append(buffer, ds.Algorithm, ds.DigestType)
+	buffer = append(buffer, ds.Digest...)
+	return buffer
+}
+
+//Unpacks a stream of bytes into a DS-type resource record value.
+func (ds *DSResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
+	endOffset := offset + dataLength
+	err := checkRecordData(buffer, TYPE_DS, offset, 4, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	ds.KeyTag = UnpackUInt16(buffer[offset: offset + 2])
+	ds.Algorithm = buffer[offset + 2]
+	ds.DigestType = buffer[offset + 3]
+	ds.Digest = append([]byte{}, buffer[offset + 4: endOffset]...)
+	return endOffset, nil
+}
+
+//Returns the string representation of DS-type record value.
+func (ds *DSResource) String() string {
+	return fmt.Sprintf("%d %d %d %s", ds.KeyTag, ds.Algorithm, ds.DigestType, strings.ToUpper(hex.EncodeToString(ds.Digest)))
+}
+
+//Represents a RRSIG-type Resource Record body, which holds the signature of a RRset (RFC 4034 - Section 3).
+type RRSIGResource struct {
+	//Type of the RRset covered by the signature.
+	TypeCovered RecordType
+	//Cryptographic algorithm used to create the signature.
+	Algorithm uint8
+	//Number of labels in the owner name of the RRset, not counting a leading wildcard label.
+	Labels uint8
+	//TTL of the RRset as it appears in the authoritative zone.
+	OriginalTTL uint32
+	//Time (in seconds since the epoch) after which the signature is no longer valid.
+	Expiration uint32
+	//Time (in seconds since the epoch) from which the signature is valid.
+	Inception uint32
+	//Key tag of the DNSKEY record that validates the signature.
+	KeyTag uint16
+	//Owner name of the DNSKEY record that validates the signature, which is the apex of the signing zone.
+	SignerName DomainName
+	//Cryptographic signature over the RRset.
+	Signature []byte
+}
+
+//Initializes the RRSIG-type record value from its presentation format -
+//"type algorithm labels original-ttl expiration inception keytag signer base64-signature".
+func (rrsig *RRSIGResource) Initialize(data string) {
+	fields := strings.Fields(data)
+	for len(fields) < 9 {
+		fields = append(fields, "")
+	}
+	rrsig.TypeCovered, _ = ParseRecordType(fields[0])
+	rrsig.Algorithm = uint8(parseUIntString(fields[1], 8))
+	rrsig.Labels = uint8(parseUIntString(fields[2], 8))
+	rrsig.OriginalTTL = uint32(parseUIntString(fields[3], 32))
+	rrsig.Expiration = parseSignatureTime(fields[4])
+	rrsig.Inception = parseSignatureTime(fields[5])
+	rrsig.KeyTag = uint16(parseUIntString(fields[6], 16))
+	rrsig.SignerName = DomainName{}
+	rrsig.SignerName.Initialize(fields[7])
+	rrsig.Signature, _ = base64.StdEncoding.DecodeString(strings.Join(fields[8:], ""))
+}
+
+//Packs the fields of the RRSIG-type record value that precede the signature, which is the prefix of the data being signed as
+//per RFC 4034 - Section 3.1.8.1. The signer name is never compressed and is packed in lowercase.
+func (rrsig *RRSIGResource) PackHeader() []byte {
+	buffer := make([]byte, 0)
+	buffer = append(buffer, PackUInt16(uint16(rrsig.TypeCovered))...)
+	buffer = append(buffer, rrsig.Algorithm, rrsig.Labels)
+	buffer = append(buffer, PackUInt32(rrsig.OriginalTTL)...)
+	buffer = append(buffer, PackUInt32(rrsig.Expiration)...)
+	buffer = append(buffer, PackUInt32(rrsig.Inception)...)
+	buffer = append(buffer, PackUInt16(rrsig.KeyTag)...)
+	buffer = append(buffer, packCanonicalName(rrsig.SignerName.Value)...)
+	return buffer
+}
+
+//Packs the RRSIG-type record value into a stream of bytes.
+func (rrsig *RRSIGResource) Pack() []byte {
+	return append(rrsig.PackHeader(), rrsig.Signature...)
+}
+
+//Unpacks a stream of bytes into a RRSIG-type resource record value.
+func (rrsig *RRSIGResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
+	endOffset := offset + dataLength
+	err := checkRecordData(buffer, TYPE_RRSIG, offset, 18, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	rrsig.TypeCovered = RecordType(UnpackUInt16(buffer[offset: offset + 2]))
+	rrsig.Algorithm = buffer[offset + 2]
+	rrsig.Labels = buffer[offset + 3]
+	rrsig.OriginalTTL = UnpackUInt32(buffer[offset + 4: offset + 8])
+	rrsig.Expiration = UnpackUInt32(buffer[offset + 8: offset + 12])
+	rrsig.Inception = UnpackUInt32(buffer[offset + 12: offset + 16])
+	rrsig.KeyTag = UnpackUInt16(buffer[offset + 16: offset + 18])
+	rrsig.SignerName = DomainName{}
+	offset, err = rrsig.SignerName.Unpack(buffer, offset + 18)
+	if err != nil {
+		return offset, err
+	}
+	err = checkRecordData(buffer, TYPE_RRSIG, offset, 0, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	rrsig.Signature = append([]byte{}, buffer[offset: endOffset]...)
+	return endOffset, nil
+}
+
+//Returns the string representation of RRSIG-type record value, with the validity period in the "YYYYMMDDHHmmSS" format.
+func (rrsig *RRSIGResource) String() string {
+	return fmt.Sprintf("%s %d %d %d %s %s %d %s %s", rrsig.TypeCovered.String(), rrsig.Algorithm, rrsig.Labels, rrsig.OriginalTTL,
+		formatSignatureTime(rrsig.Expiration), formatSignatureTime(rrsig.Inception), rrsig.KeyTag, rrsig.SignerName.String(),
+		base64.StdEncoding.EncodeToString(rrsig.Signature))
+}
+
+//Represents a NSEC-type Resource Record body, which proves the non-existence of the domain names between its owner and the
+//next domain name in the canonical order of the zone, along with the types that do not exist at its owner (RFC 4034 - Section 4).
+type NSECResource struct {
+	//Next domain name of the zone in canonical order.
+	NextDomain DomainName
+	//Record types that exist at the owner name.
+	Types []RecordType
+}
+
+//Initializes the NSEC-type record value from its presentation format - "next-domain type ...".
+func (nsec *NSECResource) Initialize(data string) {
+	fields := strings.Fields(data)
+	if len(fields) == 0 {
+		fields = append(fields, "")
+	}
+	nsec.NextDomain = DomainName{}
+	nsec.NextDomain.Initialize(fields[0])
+	nsec.Types = parseTypeList(fields[1:])
+}
+
+//Packs the NSEC-type record value into a stream of bytes. The next domain name is never compressed.
+func (nsec *NSECResource) Pack() []byte {
+	buffer := make([]byte, 0)
+	buffer = append(buffer, nsec.NextDomain.Pack(make(CompressionMap), 0)...)
+	buffer = append(buffer, packTypeBitmap(nsec.Types)...)
+	return buffer
+}
+
+//Unpacks a stream of bytes into a NSEC-type resource record value.
+func (nsec *NSECResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
+	endOffset := offset + dataLength
+	nsec.NextDomain = DomainName{}
+	offset, err := nsec.NextDomain.Unpack(buffer, offset)
+	if err != nil {
+		return offset, err
+	}
+	err = checkRecordData(buffer, TYPE_NSEC, offset, 0, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	nsec.Types = unpackTypeBitmap(buffer[offset: endOffset])
+	return endOffset, nil
+}
+
+//Returns true if the type bitmap lists the given record type.
+func (nsec *NSECResource) HasType(recType RecordType) bool {
+	return slices.Contains(nsec.Types, recType)
+}
+
+//Returns the string representation of NSEC-type record value.
+func (nsec *NSECResource) String() string {
+	return strings.TrimSpace(nsec.NextDomain.String() + WHITESPACE + typeListString(nsec.Types))
+}
+
+//Represents a NSEC3-type Resource Record body, which proves the non-existence of the domain names whose hashes fall between
+//the hash in its owner name and the next hashed owner name, along with the types that exist at its owner (RFC 5155 - Section 3).
+type NSEC3Resource struct {
+	//Algorithm used to hash the owner names.
+	HashAlgorithm uint8
+	//Flags of the record, of which only the opt-out flag is defined.
+	Flags uint8
+	//Number of additional times the hash function is applied.
+	Iterations uint16
+	//Salt appended to the owner names before hashing.
+	Salt []byte
+	//Hash of the next owner name in the hash order of the zone.
+	NextHashedOwner []byte
+	//Record types that exist at the original owner name.
+	Types []RecordType
+}
+
+//Initializes the NSEC3-type record value from its presentation format - "algorithm flags iterations salt next-hashed-owner type ...".
+//A salt of "-" stands for an empty salt and the next hashed owner name is in base32hex without padding.
+func (nsec3 *NSEC3Resource) Initialize(data string) {
+	fields := strings.Fields(data)
+	for len(fields) < 5 {
+		fields = append(fields, "")
+	}
+	nsec3.HashAlgorithm = uint8(parseUIntString(fields[0], 8))
+	nsec3.Flags = uint8(parseUIntString(fields[1], 8))
+	nsec3.Iterations = uint16(parseUIntString(fields[2], 16))
+	nsec3.Salt = make([]byte, 0)
+	if fields[3] != "-" {
+		nsec3.Salt, _ = hex.DecodeString(fields[3])
+	}
+	nsec3.NextHashedOwner, _ = base32.HexEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(fields[4]))
+	nsec3.Types = parseTypeList(fields[5:])
+}
+
+//Packs the NSEC3-type record value into a stream of bytes.
+func (nsec3 *NSEC3Resource) Pack() []byte {
+	buffer := make([]byte, 0)
+	buffer = append(buffer, nsec3.HashAlgorithm, nsec3.Flags)
+	buffer = append(buffer, PackUInt16(nsec3.Iterations)...)
+	buffer = append(buffer, byte(len(nsec3.Salt)))
+	buffer = append(buffer, nsec3.Salt...)
+	buffer = append(buffer, byte(len(nsec3.NextHashedOwner)))
+	buffer = append(buffer, nsec3.NextHashedOwner...)
+	buffer = append(buffer, packTypeBitmap(nsec3.Types)...)
+	return buffer
+}
+
+//Unpacks a stream of bytes into a NSEC3-type resource record value.
+func (nsec3 *NSEC3Resource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
+	endOffset := offset + dataLength
+	err := checkRecordData(buffer, TYPE_NSEC3, offset, 5, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	nsec3.HashAlgorithm = buffer[offset]
+	nsec3.Flags = buffer[offset + 1]
+	nsec3.Iterations = UnpackUInt16(buffer[offset + 2: offset + 4])
+	saltLength := int(buffer[offset + 4])
+	offset = offset + 5
+	//The salt is followed by at least the length of the next hashed owner name.
+	err = checkRecordData(buffer, TYPE_NSEC3, offset, saltLength + 1, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	nsec3.Salt = append([]byte{}, buffer[offset: offset + saltLength]...)
+	offset = offset + saltLength
+	hashLength := int(buffer[offset])
+	err = checkRecordData(buffer, TYPE_NSEC3, offset + 1, hashLength, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	nsec3.NextHashedOwner = append([]byte{}, buffer[offset + 1: offset + 1 + hashLength]...)
+	offset = offset + 1 + hashLength
+	nsec3.Types = unpackTypeBitmap(buffer[offset: endOffset])
+	return endOffset, nil
+}
+
+//Returns true if the opt-out flag is set, in which case the record may cover unsigned delegations.
+func (nsec3 *NSEC3Resource) IsOptOut() bool {
+	return nsec3.Flags & NSEC3_OPT_OUT_FLAG != 0
+}
+
+//Returns true if the type bitmap lists the given record type.
+func (nsec3 *NSEC3Resource) HasType(recType RecordType) bool {
+	return slices.Contains(nsec3.Types, recType)
+}
+
+//Returns the string representation of NSEC3-type record value.
+func (nsec3 *NSEC3Resource) String() string {
+	salt := "-"
+	if len(nsec3.Salt) > 0 {
+		salt = strings.ToUpper(hex.EncodeToString(nsec3.Salt))
+	}
+	nextHashedOwner := strings.ToLower(base32.HexEncoding.WithPadding(base32.NoPadding).EncodeToString(nsec3.NextHashedOwner))
+	value := fmt.Sprintf("%d %d %d %s %s", nsec3.HashAlgorithm, nsec3.Flags, nsec3.Iterations, salt, nextHashedOwner)
+	return strings.TrimSpace(value + WHITESPACE + typeListString(nsec3.Types))
+}
+
+//Represents a NSEC3PARAM-type Resource Record body, published at the apex of a zone signed with NSEC3 records to give the parameters
+//its authoritative servers hash the domain names with (RFC 5155 - Section 4).
+type NSEC3PARAMResource struct {
+	//Algorithm used to hash the owner names.
+	HashAlgorithm uint8
+	//Flags of the record, all of which are reserved and left clear.
+	Flags uint8
+	//Number of additional times the hash function is applied.
+	Iterations uint16
+	//Salt appended to the owner names before hashing.
+	Salt []byte
+}
+
+//Initializes the NSEC3PARAM-type record value from its presentation format - "algorithm flags iterations salt", where a salt of
+//"-" stands for an empty salt.
+func (nsec3param *NSEC3PARAMResource) Initialize(data string) {
+	fields := strings.Fields(data)
+	for len(fields) < 4 {
+		fields = append(fields, "")
+	}
+	nsec3param.HashAlgorithm = uint8(parseUIntString(fields[0], 8))
+	nsec3param.Flags = uint8(parseUIntString(fields[1], 8))
+	nsec3param.Iterations = uint16(parseUIntString(fields[2], 16))
+	nsec3param.Salt = make([]byte, 0)
+	if fields[3] != "-" {
+		nsec3param.Salt, _ = hex.DecodeString(fields[3])
+	}
+}
+
+//Packs the NSEC3PARAM-type record value into a stream of bytes.
+func (nsec3param *NSEC3PARAMResource) Pack() []byte {
+	buffer := make([]byte, 0)
+	buffer = append(buffer, nsec3param.HashAlgorithm, nsec3param.Flags)
+	buffer = append(buffer, PackUInt16(nsec3param.Iterations)...)
+	buffer = append(buffer, byte(len(nsec3param.Salt)))
+	buffer = append(buffer, nsec3param.Salt...)
+	return buffer
+}
+
+//Unpacks a stream of bytes into a NSEC3PARAM-type resource record value.
+func (nsec3param *NSEC3PARAMResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
+	endOffset := offset + dataLength
+	err := checkRecordData(buffer, TYPE_NSEC3PARAM, offset, 5, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	nsec3param.HashAlgorithm = buffer[offset]
+	nsec3param.Flags = buffer[offset + 1]
+	nsec3param.Iterations = UnpackUInt16(buffer[offset + 2: offset + 4])
+	saltLength := int(buffer[offset + 4])
+	offset = offset + 5
+	err = checkRecordData(buffer, TYPE_NSEC3PARAM, offset, saltLength, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	nsec3param.Salt = append([]byte{}, buffer[offset: offset + saltLength]...)
+	return endOffset, nil
+}
+
+//Returns true if the NSEC3 record was made with the hash algorithm, iterations and salt given by the NSEC3PARAM record.
+func (nsec3param *NSEC3PARAMResource) Matches(nsec3 *NSEC3Resource) bool {
+	return nsec3.HashAlgorithm == nsec3param.HashAlgorithm && nsec3.Iterations == nsec3param.Iterations && bytes.Equal(nsec3.Salt, nsec3param.Salt)
+}
+
+//Returns the string representation of NSEC3PARAM-type record value.
+func (nsec3param *NSEC3PARAMResource) String() string {
+	salt := "-"
+	if len(nsec3param.Salt) > 0 {
+		salt = strings.ToUpper(hex.EncodeToString(nsec3param.Salt))
+	}
+	return fmt.Sprintf("%d %d %d %s", nsec3param.HashAlgorithm, nsec3param.Flags, nsec3param.Iterations, salt)
+}
+
+//Packs the given record types into the type bitmap format of RFC 4034 - Section 4.1.2. The types are split into windows of
+//256 types each, and every window present is written as its number, the length of its bitmap and the bitmap itself.
+func packTypeBitmap(types []RecordType) []byte {
+	sorted := slices.Clone(types)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+	buffer := make([]byte, 0)
+	for index := 0; index < len(sorted); {
+		window := uint8(sorted[index] >> 8)
+		bitmap := make([]byte, 32)
+		length := 0
+		for ; index < len(sorted) && uint8(sorted[index] >> 8) == window; index++ {
+			bit := uint8(sorted[index] & 0xFF)
+			bitmap[bit / 8] |= 0x80 >> (bit % 8)
+			length = int(bit / 8) + 1
+		}
+		buffer = append(buffer, window, byte(length))
+		buffer = append(buffer, bitmap[:length]...)
+	}
+	return buffer
+}
+
+//Unpacks a type bitmap in the format of RFC 4034 - Section 4.1.2 into the record types it lists.
+func unpackTypeBitmap(buffer []byte) []RecordType {
+	types := make([]RecordType, 0)
+	for offset := 0; offset + 2 <= len(buffer); {
+		window := int(buffer[offset])
+		length := int(buffer[offset + 1])
+		offset = offset + 2
+		for index := 0; index < length && offset + index < len(buffer); index++ {
+			for bit := 0; bit < 8; bit++ {
+				if buffer[offset + index] & (0x80 >> bit) != 0 {
+					types = append(types, RecordType(window << 8 | index * 8 + bit))
+				}
+			}
+		}
+		offset = offset + length
+	}
+	return types
+}
+
+//Parses the record types listed in the presentation format of a type bitmap, skipping any value that is not a record type.
+//The types are returned in ascending order, as they are listed in the type bitmap on the wire.
+func parseTypeList(fields []string) []RecordType {
+	types := make([]RecordType, 0)
+	for _, field := range fields {
+		recType, ok := ParseRecordType(field)
+		if ok {
+			types = append(types, recType)
+		}
+	}
+	slices.Sort(types)
+	return slices.Compact(types)
+}
+
+//Returns the presentation format of the given record types, separated by whitespace.
+func typeListString(types []RecordType) string {
+	values := make([]string, 0)
+	for _, recType := range types {
+		values = append(values, recType.String())
+	}
+	return strings.Join(values, WHITESPACE)
+}
+
+//Parses a signature time, either in the "YYYYMMDDHHmmSS" format or as the number of seconds since the epoch (RFC 4034 - Section 3.2).
+func parseSignatureTime(value string) uint32 {
+	if len(value) == len(RRSIG_TIME_FORMAT) {
+		parsed, err := time.Parse(RRSIG_TIME_FORMAT, value)
+		if err == nil {
+			return uint32(parsed.Unix())
+		}
+	}
+	return uint32(parseUIntString(value, 32))
+}
+
+//Formats a signature time in the "YYYYMMDDHHmmSS" format, in UTC.
+func formatSignatureTime(value uint32) string {
+	return time.Unix(int64(value), 0).UTC().Format(RRSIG_TIME_FORMAT)
+}
+
+//Packs the given domain name in the canonical form of RFC 4034 - Section 6.2, which is in lowercase and never compressed.
+func packCanonicalName(name string) []byte {
+	canonical := DomainName{}
+	canonical.Initialize(name)
+	return canonical.Pack(make(CompressionMap), 0)
+}
+
+//Represents a single option carried by an OPT-type record.
+type EDNSOption struct {
+	//Code identifying the option.
+	Code uint16
+	//Value of the option in wire format.
+	Data []byte
+}
+
+//Represents an OPT-type pseudo Resource Record body, which extends the DNS message with EDNS(0) as per RFC 6891. The record has no
+//owner and its class and TTL hold the UDP payload size, the extended response code, the EDNS version and the DO bit instead.
+type OPTResource struct {
+	//Options carried by the record.
+	Options []EDNSOption
+}
+
+//Packs the OPT-type record value into a stream of bytes.
+func (opt *OPTResource) Pack() []byte {
+	buffer := make([]byte, 0)
+	for _, option := range opt.Options {
+		buffer = append(buffer, PackUInt16(option.Code)...)
+		buffer = append(buffer, PackUInt16(uint16(len(option.Data)))...)
+		buffer = append(buffer, option.Data...)
+	}
+	return buffer
+}
+
+//Unpacks a stream of bytes into an OPT-type resource record value.
+func (opt *OPTResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
+	endOffset := offset + dataLength
+	opt.Options = make([]EDNSOption, 0)
+	for offset < endOffset {
+		err := checkRecordData(buffer, TYPE_OPT, offset, 4, endOffset)
+		if err != nil {
+			return offset, err
+		}
+		option := EDNSOption{}
+		option.Code = UnpackUInt16(buffer[offset: offset + 2])
+		length := int(UnpackUInt16(buffer[offset + 2: offset + 4]))
+		err = checkRecordData(buffer, TYPE_OPT, offset + 4, length, endOffset)
+		if err != nil {
+			return offset, err
+		}
+		option.Data = append([]byte{}, buffer[offset + 4: offset + 4 + length]...)
+		opt.Options = append(opt.Options, option)
+		offset = offset + 4 + length
+	}
+	return endOffset, nil
+}
+
+//Returns the string representation of OPT-type record value, listing every option as "code:hex-value".
+func (opt *OPTResource) String() string {
+	values := make([]string, 0)
+	for _, option := range opt.Options {
+		values = append(values, fmt.Sprintf("%d:%s", option.Code, hex.EncodeToString(option.Data)))
+	}
+	return strings.Join(values, WHITESPACE)
+}
+
+//Represents the body of a Resource Record whose type is not supported by the resolver. The record data is kept as it is,
+//so that the record can be skipped over while parsing a message.
+type UnknownResource struct {
+	Data []byte
+}
+
+//Copies the record data from the stream of bytes.
+func (unknown *UnknownResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
+	unknown.Data = append([]byte{}, buffer[offset: offset + dataLength]...)
+	return offset + dataLength, nil
+}
+
+//Returns the record data in the generic format of RFC 3597.
+func (unknown *UnknownResource) String() string {
+	return fmt.Sprintf("\\# %d %s", len(unknown.Data), hex.EncodeToString(unknown.Data))
 }
\ No newline at end of file
@@ -0,0 +1,280 @@
+package dns
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+//Serves the queries of clients over DNS-over-TLS (RFC 7858) and DNS-over-HTTPS (RFC 8484), answering them with the resolver. Responses
+//to clients using EDNS are padded to a multiple of 468 octets (RFC 7830, RFC 8467), so that their length reveals little of the name
+//being resolved.
+type Server struct {
+	//Resolver the queries are answered with.
+	Resolver *Resolver
+	//TLS configuration, holding the certificate and key the server presents to clients.
+	TLSConfig *tls.Config
+	//Guards the listeners and connections.
+	mutex sync.Mutex
+	//Listeners the server accepts connections on.
+	listeners []net.Listener
+	//Open DNS-over-TLS connections.
+	connections map[net.Conn]struct{}
+	//HTTP servers of the DNS-over-HTTPS listeners.
+	httpServers []*http.Server
+	//Tracks the DNS-over-TLS connections still being served.
+	active sync.WaitGroup
+	//True once the server has been closed.
+	closed bool
+}
+
+//Creates a server answering queries with the resolver and presenting the certificate and private key held by the given PEM files.
+func NewServer(resolver *Resolver, certFile string, keyFile string) (*Server, error) {
+	certificate, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}
+	return &Server{Resolver: resolver, TLSConfig: &config, connections: make(map[net.Conn]struct{})}, nil
+}
+
+//Listens for DNS-over-TLS connections on the given TCP address and serves them until the server is closed.
+func (server *Server) ListenAndServeTLS(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return server.ServeTLS(listener)
+}
+
+//Accepts connections on the listener, performs the TLS handshake and serves the DNS-over-TLS queries sent over them until the server
+//is closed. Always returns a non-nil error, which is ErrServerClosed once the server has been closed.
+func (server *Server) ServeTLS(listener net.Listener) error {
+	listener = tls.NewListener(listener, server.TLSConfig)
+	if !server.track(listener) {
+		return ErrServerClosed
+	}
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if server.isClosed() {
+				return ErrServerClosed
+			}
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				continue
+			}
+			return err
+		}
+
+		server.mutex.Lock()
+		if server.closed {
+			server.mutex.Unlock()
+			conn.Close()
+			return ErrServerClosed
+		}
+		server.connections[conn] = struct{}{}
+		server.active.Add(1)
+		server.mutex.Unlock()
+		go server.serveConnection(conn)
+	}
+}
+
+//Listens for DNS-over-HTTPS requests on the given TCP address and serves them until the server is closed.
+func (server *Server) ListenAndServeHTTPS(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return server.ServeHTTPS(listener)
+}
+
+//Serves DNS-over-HTTPS requests, over HTTP/2 or HTTP/1.1, on the connections accepted by the listener until the server is closed.
+//Always returns a non-nil error, which is ErrServerClosed once the server has been closed.
+func (server *Server) ServeHTTPS(listener net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle(DOH_PATH, server)
+	httpServer := http.Server{Handler: mux, TLSConfig: server.TLSConfig.Clone(), ReadHeaderTimeout: SERVER_QUERY_TIMEOUT, IdleTimeout: SERVER_IDLE_TIMEOUT}
+	server.mutex.Lock()
+	if server.closed {
+		server.mutex.Unlock()
+		return ErrServerClosed
+	}
+	server.httpServers = append(server.httpServers, &httpServer)
+	server.mutex.Unlock()
+
+	err := httpServer.ServeTLS(listener, "", "")
+	if errors.Is(err, http.ErrServerClosed) {
+		return ErrServerClosed
+	}
+	return err
+}
+
+//Answers a DNS-over-HTTPS request, which carries the query in the "dns" parameter of a GET request or as the body of a POST request.
+func (server *Server) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	var buffer []byte
+	var err error
+	if request.Method == http.MethodGet {
+		buffer, err = base64.RawURLEncoding.DecodeString(request.URL.Query().Get("dns"))
+	} else if request.Method == http.MethodPost {
+		mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type"))
+		if mediaType != DOH_MEDIA_TYPE {
+			http.Error(writer, "content type must be " + DOH_MEDIA_TYPE, http.StatusUnsupportedMediaType)
+			return
+		}
+		buffer, err = io.ReadAll(io.LimitReader(request.Body, STREAM_MESSAGE_SIZE_LIMIT + 1))
+	} else {
+		writer.Header().Set("Allow", "GET, POST")
+		http.Error(writer, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if err != nil || len(buffer) > STREAM_MESSAGE_SIZE_LIMIT {
+		http.Error(writer, "malformed DNS query", http.StatusBadRequest)
+		return
+	}
+
+	response, ok := server.answer(buffer)
+	if !ok {
+		http.Error(writer, "malformed DNS query", http.StatusBadRequest)
+		return
+	}
+	writer.Header().Set("Content-Type", DOH_MEDIA_TYPE)
+	writer.Header().Set("Cache-Control", "max-age=" + strconv.FormatUint(uint64(response.ttl), 10))
+	writer.Write(response.buffer)
+}
+
+//Closes the listeners and the open connections of the server, and waits for the queries being answered over them.
+func (server *Server) Close() error {
+	server.mutex.Lock()
+	server.closed = true
+	for _, listener := range server.listeners {
+		listener.Close()
+	}
+	for conn := range server.connections {
+		conn.Close()
+	}
+	httpServers := server.httpServers
+	server.mutex.Unlock()
+
+	for _, httpServer := range httpServers {
+		httpServer.Close()
+	}
+	server.active.Wait()
+	return nil
+}
+
+//Serves the queries sent over a DNS-over-TLS connection, each prefixed with its length (RFC 1035 - Section 4.2.2). Queries are answered
+//concurrently and their responses sent in the order they complete (RFC 7766 - Section 6.2.1.1). At most SERVER_CONNECTION_QUERY_LIMIT
+//queries are answered at once, and the connection is not read from while the limit is reached, so that a client pipelining queries
+//is slowed down by TCP flow control instead of having the server answer all of them. The connection is closed once it has been idle
+//for SERVER_IDLE_TIMEOUT, or when the client sends a malformed message.
+func (server *Server) serveConnection(conn net.Conn) {
+	var writeMutex sync.Mutex
+	var queries sync.WaitGroup
+	pending := make(chan struct{}, SERVER_CONNECTION_QUERY_LIMIT)
+	defer func() {
+		queries.Wait()
+		conn.Close()
+		server.mutex.Lock()
+		delete(server.connections, conn)
+		server.mutex.Unlock()
+		server.active.Done()
+	}()
+
+	for {
+		pending <- struct{}{}
+		conn.SetReadDeadline(time.Now().Add(SERVER_IDLE_TIMEOUT))
+		header := make([]byte, 2)
+		_, err := io.ReadFull(conn, header)
+		if err != nil {
+			return
+		}
+
+		buffer := make([]byte, UnpackUInt16(header))
+		conn.SetReadDeadline(time.Now().Add(SERVER_QUERY_TIMEOUT))
+		_, err = io.ReadFull(conn, buffer)
+		if err != nil {
+			return
+		}
+
+		queries.Add(1)
+		go func() {
+			defer func() {
+				<-pending
+				queries.Done()
+			}()
+			response, ok := server.answer(buffer)
+			if !ok {
+				conn.Close()
+				return
+			}
+
+			writeMutex.Lock()
+			defer writeMutex.Unlock()
+			conn.SetWriteDeadline(time.Now().Add(SERVER_QUERY_TIMEOUT))
+			_, err := conn.Write(append(PackUInt16(uint16(len(response.buffer))), response.buffer...))
+			if err != nil {
+				conn.Close()
+			}
+		}()
+	}
+}
+
+//Response to a query, packed and ready to be sent.
+type packedResponse struct {
+	//Packed response message.
+	buffer []byte
+	//Lowest TTL of the records in the response, for which it may be cached.
+	ttl uint32
+}
+
+//Parses the packed query and answers it with the resolver. Returns false if the query could not be parsed as a DNS message.
+func (server *Server) answer(buffer []byte) (*packedResponse, bool) {
+	request := NewMessage(MSG_REQUEST, 0)
+	err := request.Unpack(buffer)
+	if err != nil {
+		server.Resolver.Log(fmt.Sprintf("Discarded a query that could not be parsed as a DNS message: %s", err.Error()))
+		return nil, false
+	}
+	server.Resolver.Log(fmt.Sprintf("Query received from a client:\n%s", request.String()))
+	message := server.Resolver.Answer(request)
+	message.Pad(RESPONSE_PADDING_BLOCK_SIZE)
+
+	response := &packedResponse{buffer: message.Pack()}
+	for index, rr := range append(message.Answers, message.Authoritative...) {
+		if index == 0 || rr.TTL < response.ttl {
+			response.ttl = rr.TTL
+		}
+	}
+	return response, true
+}
+
+//Adds the listener to those closed along with the server. Returns false, after closing the listener, if the server is already closed.
+func (server *Server) track(listener net.Listener) bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	if server.closed {
+		listener.Close()
+		return false
+	}
+	server.listeners = append(server.listeners, listener)
+	return true
+}
+
+//Returns true once the server has been closed.
+func (server *Server) isClosed() bool {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+	return server.closed
+}
@@ -0,0 +1,321 @@
+package dns
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+//Server answering queries from a simulated hierarchy over DNS-over-TLS and DNS-over-HTTPS on the loopback interface.
+type testServer struct {
+	*Server
+	//Address of the DNS-over-TLS listener.
+	TLSAddress string
+	//URL of the DNS-over-HTTPS endpoint.
+	HTTPSURL string
+	//TLS configuration of the clients, trusting the certificate of the server.
+	ClientConfig *tls.Config
+}
+
+//Starts a server answering queries with the resolver, presenting a certificate loaded from PEM files by NewServer.
+func startTestServer(t *testing.T, resolver *Resolver) *testServer {
+	t.Helper()
+	authority := newTestAuthority(t, "Test CA")
+	certificate := authority.Issue(t)
+	key, err := x509.MarshalECPrivateKey(certificate.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(t.TempDir(), "cert.pem"), filepath.Join(t.TempDir(), "key.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Certificate[0]})
+	if os.WriteFile(certFile, certPEM, 0644) != nil || os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key}), 0600) != nil {
+		t.Fatal("could not write the certificate and key files")
+	}
+
+	server, err := NewServer(resolver, certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tlsListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpsListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.ServeTLS(tlsListener)
+	go server.ServeHTTPS(httpsListener)
+	t.Cleanup(func() { server.Close() })
+
+	clientConfig := &tls.Config{RootCAs: authority.Pool(), ServerName: TEST_SERVER_NAME}
+	return &testServer{Server: server, TLSAddress: tlsListener.Addr().String(), HTTPSURL: "https://" + httpsListener.Addr().String() + DOH_PATH, ClientConfig: clientConfig}
+}
+
+//Opens a DNS-over-TLS connection to the server.
+func (server *testServer) DialTLS(t *testing.T) *tls.Conn {
+	t.Helper()
+	conn, err := tls.Dial("tcp", server.TLSAddress, server.ClientConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	return conn
+}
+
+//Sends the packed query over a new DNS-over-TLS connection and returns the packed response.
+func (server *testServer) ExchangeTLS(t *testing.T, query []byte) []byte {
+	t.Helper()
+	conn := server.DialTLS(t)
+	_, err := conn.Write(append(PackUInt16(uint16(len(query))), query...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readFramed(t, conn)
+}
+
+//Sends the packed query as the body of a POST request of the given content type and returns the HTTP response.
+func (server *testServer) Post(t *testing.T, contentType string, body []byte) *http.Response {
+	t.Helper()
+	response, err := server.httpClient().Post(server.HTTPSURL, contentType, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+	return response
+}
+
+//Sends a GET request with the given value of the "dns" parameter and returns the HTTP response.
+func (server *testServer) Get(t *testing.T, value string) *http.Response {
+	t.Helper()
+	response, err := server.httpClient().Get(server.HTTPSURL + "?dns=" + value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { response.Body.Close() })
+	return response
+}
+
+//Returns an HTTP client trusting the certificate of the server.
+func (server *testServer) httpClient() *http.Client {
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: server.ClientConfig}, Timeout: 10 * time.Second}
+}
+
+//Reads a message prefixed with its length from the connection.
+func readFramed(t *testing.T, conn net.Conn) []byte {
+	t.Helper()
+	header := make([]byte, 2)
+	_, err := io.ReadFull(conn, header)
+	if err != nil {
+		t.Fatalf("reading the response: %s", err.Error())
+	}
+	buffer := make([]byte, UnpackUInt16(header))
+	_, err = io.ReadFull(conn, buffer)
+	if err != nil {
+		t.Fatalf("reading the response: %s", err.Error())
+	}
+	return buffer
+}
+
+//Returns the packed query for the records of the given type of the domain name, with an OPT record if 'edns' is true.
+func packedQuery(name string, recType RecordType, edns bool) []byte {
+	request := newQuery(Id(), name, recType)
+	request.Header.SetRecursionDesired(true)
+	if edns {
+		request.SetEDNS(UDP_MESSAGE_SIZE_LIMIT, false)
+	}
+	return request.Pack()
+}
+
+//Unpacks the response, failing the test if it cannot be parsed.
+func unpackResponse(t *testing.T, buffer []byte) *Message {
+	t.Helper()
+	response := NewMessage(MSG_RESPONSE, 0)
+	err := response.Unpack(buffer)
+	if err != nil {
+		t.Fatalf("unpacking the response: %s", err.Error())
+	}
+	return response
+}
+
+func TestServerPadsResponses(t *testing.T) {
+	server := startTestServer(t, newSimulatedHierarchy(t, hierarchyFixtures()...).newResolver(t))
+	testCases := []struct {
+		name string
+		qname string
+		qtype RecordType
+		edns bool
+		//Length of the padded response, in blocks of RESPONSE_PADDING_BLOCK_SIZE octets.
+		blocks int
+	}{
+		{name: "short answer", qname: "www.example.com.", qtype: TYPE_A, edns: true, blocks: 1},
+		{name: "negative answer", qname: "missing.example.com.", qtype: TYPE_A, edns: true, blocks: 1},
+		{name: "answer longer than a block", qname: "big.example.com.", qtype: TYPE_TXT, edns: true, blocks: 2},
+		{name: "query without EDNS", qname: "www.example.com.", qtype: TYPE_A},
+	}
+
+	for _, testCase := range testCases {
+		query := packedQuery(testCase.qname, testCase.qtype, testCase.edns)
+		exchanges := map[string]func(t *testing.T) []byte{
+			"TLS": func(t *testing.T) []byte { return server.ExchangeTLS(t, query) },
+			"HTTPS": func(t *testing.T) []byte {
+				response := server.Post(t, DOH_MEDIA_TYPE, query)
+				body, err := io.ReadAll(response.Body)
+				if err != nil || response.StatusCode != http.StatusOK {
+					t.Fatalf("HTTP status %s: %v", response.Status, err)
+				}
+				return body
+			},
+		}
+
+		for transport, exchange := range exchanges {
+			t.Run(testCase.name + " over " + transport, func(t *testing.T) {
+				buffer := exchange(t)
+				response := unpackResponse(t, buffer)
+				opt, hasEDNS := response.GetEDNS()
+				if !testCase.edns {
+					if hasEDNS {
+						t.Error("response to a query without EDNS carries an OPT record")
+					}
+					return
+				}
+
+				padded := slices.ContainsFunc(opt.Rdata.(*OPTResource).Options, func(option EDNSOption) bool { return option.Code == EDNS_PADDING_OPTION })
+				if !padded || len(buffer) != testCase.blocks * RESPONSE_PADDING_BLOCK_SIZE {
+					t.Errorf("response of %d octets (padded: %t), expected %d octets", len(buffer), padded, testCase.blocks * RESPONSE_PADDING_BLOCK_SIZE)
+				}
+			})
+		}
+	}
+}
+
+func TestServerHonoursDNSSECBits(t *testing.T) {
+	hierarchy := newSimulatedHierarchy(t, hierarchyFixtures()...)
+	exampleKey := hierarchy.SignZone(t, "example.com.", time.Now().Add(24 * time.Hour))
+	comKey := hierarchy.SignZone(t, "com.", time.Now().Add(24 * time.Hour), exampleKey)
+	rootKey := hierarchy.SignZone(t, ".", time.Now().Add(24 * time.Hour), comKey)
+	//The address of www.example.com. no longer matches its signature, while ipv4.example.com. is left as signed.
+	forgeAddress(t, hierarchy.zone("example.com."))
+
+	resolver := hierarchy.newResolver(t)
+	resolver.SetDNSSECValidation(true)
+	err := resolver.SetTrustAnchors([]Resource{rootKey.DS()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := startTestServer(t, resolver)
+
+	testCases := []struct {
+		name string
+		qname string
+		do bool
+		ad bool
+		cd bool
+		rcode ResponseCode
+		authenticated bool
+		signatures bool
+	}{
+		{name: "DO bit set", qname: "ipv4.example.com.", do: true, rcode: RC_NOERROR, authenticated: true, signatures: true},
+		{name: "AD bit set without the DO bit", qname: "ipv4.example.com.", ad: true, rcode: RC_NOERROR, authenticated: true},
+		{name: "neither DO nor AD bit set", qname: "ipv4.example.com.", rcode: RC_NOERROR},
+		{name: "bogus answer", qname: "www.example.com.", do: true, rcode: RC_SERVFAIL},
+		{name: "bogus answer with the CD bit set", qname: "www.example.com.", do: true, cd: true, rcode: RC_NOERROR, signatures: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			request := newQuery(Id(), testCase.qname, TYPE_A)
+			request.Header.SetRecursionDesired(true)
+			request.Header.Authenticated = testCase.ad
+			request.Header.CheckingDisabled = testCase.cd
+			request.SetEDNS(UDP_MESSAGE_SIZE_LIMIT, testCase.do)
+			response := unpackResponse(t, server.ExchangeTLS(t, request.Pack()))
+
+			_, signatures := response.FindAnswerRecords(TYPE_RRSIG)
+			if response.Header.Rcode != testCase.rcode || response.Header.Authenticated != testCase.authenticated || signatures != testCase.signatures {
+				t.Errorf("response code %s, AD bit %t and signatures %t, expected %s, %t and %t", response.Header.Rcode.String(), response.Header.Authenticated, signatures,
+					testCase.rcode.String(), testCase.authenticated, testCase.signatures)
+			}
+			if testCase.cd && !response.Header.CheckingDisabled {
+				t.Error("CD bit of the query is not copied to the response")
+			}
+		})
+	}
+}
+
+func TestServerRejectsMalformedQueries(t *testing.T) {
+	server := startTestServer(t, newSimulatedHierarchy(t, hierarchyFixtures()...).newResolver(t))
+	valid := packedQuery("www.example.com.", TYPE_A, true)
+	pointerLoop := []byte{0x12, 0x34, 0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x0C, 0x00, 0x01, 0x00, 0x01}
+
+	t.Run("malformed query over TLS", func(t *testing.T) {
+		for _, query := range [][]byte{pointerLoop, valid[:MESSAGE_HEADER_LENGTH - 2], valid[:len(valid) - 3]} {
+			conn := server.DialTLS(t)
+			conn.Write(append(PackUInt16(uint16(len(query))), query...))
+			_, err := io.ReadFull(conn, make([]byte, 2))
+			if !errors.Is(err, io.EOF) {
+				t.Errorf("reading after a malformed query returned %v, expected the connection to be closed", err)
+			}
+		}
+	})
+
+	testCases := []struct {
+		name string
+		send func(t *testing.T) *http.Response
+		status int
+	}{
+		{name: "POST with a pointer loop", send: func(t *testing.T) *http.Response { return server.Post(t, DOH_MEDIA_TYPE, pointerLoop) }, status: http.StatusBadRequest},
+		{name: "POST with a truncated query", send: func(t *testing.T) *http.Response { return server.Post(t, DOH_MEDIA_TYPE, valid[:len(valid) - 3]) }, status: http.StatusBadRequest},
+		{name: "POST of another content type", send: func(t *testing.T) *http.Response { return server.Post(t, "application/json", valid) }, status: http.StatusUnsupportedMediaType},
+		{
+			name: "POST longer than a DNS message",
+			send: func(t *testing.T) *http.Response { return server.Post(t, DOH_MEDIA_TYPE, bytes.Repeat([]byte{0}, STREAM_MESSAGE_SIZE_LIMIT + 1)) },
+			status: http.StatusBadRequest,
+		},
+		{name: "GET with a value that is not base64url", send: func(t *testing.T) *http.Response { return server.Get(t, "not+base64url/") }, status: http.StatusBadRequest},
+		{name: "GET with a pointer loop", send: func(t *testing.T) *http.Response { return server.Get(t, base64.RawURLEncoding.EncodeToString(pointerLoop)) }, status: http.StatusBadRequest},
+		{name: "GET with a valid query", send: func(t *testing.T) *http.Response { return server.Get(t, base64.RawURLEncoding.EncodeToString(valid)) }, status: http.StatusOK},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name + " over HTTPS", func(t *testing.T) {
+			response := testCase.send(t)
+			if response.StatusCode != testCase.status {
+				t.Errorf("HTTP status %d, expected %d", response.StatusCode, testCase.status)
+			}
+		})
+	}
+}
+
+func TestServerAnswersPipelinedQueriesBeyondTheLimit(t *testing.T) {
+	server := startTestServer(t, newSimulatedHierarchy(t, hierarchyFixtures()...).newResolver(t))
+	conn := server.DialTLS(t)
+	count := 3 * SERVER_CONNECTION_QUERY_LIMIT
+	var queries bytes.Buffer
+	for index := 0; index < count; index++ {
+		query := packedQuery("www.example.com.", TYPE_A, false)
+		queries.Write(append(PackUInt16(uint16(len(query))), query...))
+	}
+	go conn.Write(queries.Bytes())
+
+	for index := 0; index < count; index++ {
+		response := unpackResponse(t, readFramed(t, conn))
+		if response.Header.Rcode != RC_NOERROR || !strings.Contains(response.String(), "203.0.113.10") {
+			t.Fatalf("response %d does not answer the query:\n%s", index, response.String())
+		}
+	}
+}
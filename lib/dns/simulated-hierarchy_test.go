@@ -0,0 +1,493 @@
+package dns
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//Zone served by simulated name servers, declared in master file format.
+type zoneFixture struct {
+	//Origin of the zone.
+	Origin string
+	//IPv4 and IPv6 addresses of the name servers authoritative for the zone, which listen on port 53 of the in-memory transport.
+	Servers []string
+	//Records of the zone in master file format, along with the delegations to its child zones and their glue records.
+	Records string
+}
+
+//Way in which a simulated name server fails to answer the queries sent to it.
+type serverFault int
+
+const (
+	//The server answers normally.
+	FAULT_NONE serverFault = iota
+	//The server never responds.
+	FAULT_TIMEOUT
+	//The server responds with SERVFAIL.
+	FAULT_SERVER_FAILURE
+	//The server responds with REFUSED.
+	FAULT_REFUSED
+)
+
+//Zone loaded from a fixture, along with the name servers serving it.
+type simulatedZone struct {
+	//Origin of the zone.
+	origin string
+	//Addresses of the name servers serving the zone, given as "ip:port".
+	servers []string
+	//Records of the zone.
+	records []Resource
+}
+
+//Hierarchy of root, TLD and authoritative name servers answering from zone fixtures over an in-memory transport, so that the iterative
+//resolution can be exercised without network access. Each server answers authoritatively for the zones it serves, referring queries
+//for names below a zone cut to the child zone along with the glue records found in the parent zone.
+type simulatedHierarchy struct {
+	//Exchanger reaching the name servers over an emulated datagram transport, which truncates large responses.
+	Exchanger *MemoryExchanger
+	//Number of queries sent over the emulated stream transport.
+	StreamQueries atomic.Int32
+	//Zones served by the name servers.
+	zones []*simulatedZone
+	//Guards the faults and the queries received.
+	mutex sync.Mutex
+	//Faults of the name servers, keyed by their address.
+	faults map[string]serverFault
+	//Questions received by the name servers, as "name TYPE", keyed by their address.
+	queries map[string][]string
+}
+
+//Loads the zone fixtures and registers their name servers with a new in-memory exchanger. The fixture of the root zone gives the root
+//servers the resolvers start from.
+func newSimulatedHierarchy(t *testing.T, fixtures ...zoneFixture) *simulatedHierarchy {
+	t.Helper()
+	hierarchy := &simulatedHierarchy{Exchanger: NewMemoryExchanger(), faults: make(map[string]serverFault), queries: make(map[string][]string)}
+	for _, fixture := range fixtures {
+		zoneFile := ZoneFile{Origin: Canonicalize(fixture.Origin), Records: make([]Resource, 0)}
+		err := zoneFile.Parse(strings.NewReader(fixture.Records))
+		if err != nil {
+			t.Fatalf("fixture of zone %s: %s", fixture.Origin, err.Error())
+		}
+
+		zone := &simulatedZone{origin: zoneFile.Origin, records: zoneFile.Records}
+		for _, server := range fixture.Servers {
+			address := simulatedAddress(server)
+			zone.servers = append(zone.servers, address)
+			hierarchy.Exchanger.Handle(address, hierarchy.handler(address))
+		}
+		hierarchy.zones = append(hierarchy.zones, zone)
+	}
+	return hierarchy
+}
+
+//Creates a resolver starting from the root servers of the hierarchy, with an empty cache, and querying the servers over the emulated
+//datagram transport with a fallback to the emulated stream transport for truncated responses.
+func (hierarchy *simulatedHierarchy) newResolver(t *testing.T) *Resolver {
+	t.Helper()
+	var rootServers strings.Builder
+	for _, zone := range hierarchy.zones {
+		if zone.origin != DOMAIN_LABEL_SEPERATOR {
+			continue
+		}
+		for index, address := range zone.servers {
+			ip, _, _ := net.SplitHostPort(address)
+			recType := TYPE_A
+			if isIPv6(ip) {
+				recType = TYPE_AAAA
+			}
+			rootServers.WriteString(fmt.Sprintf("%c.root-servers.net. 3600000 IN %s %s 2024-05-17T00:00:23Z\n", 'a' + index, recType.String(), ip))
+		}
+	}
+
+	rootServersPath := filepath.Join(t.TempDir(), "root-servers.conf")
+	err := os.WriteFile(rootServersPath, []byte(rootServers.String()), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exchanger := &FallbackExchanger{Datagram: hierarchy.Exchanger, Stream: &countingExchanger{Exchanger: hierarchy.Exchanger.StreamExchanger(), count: &hierarchy.StreamQueries}}
+	resolver, err := NewResolverWithExchanger(rootServersPath, NewMemoryStore(), exchanger, testing.Verbose())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolver.Logger = log.New(os.Stderr, "", 0)
+	t.Cleanup(resolver.Close)
+	return resolver
+}
+
+//Makes the name server with the given IP address fail to answer in the given way.
+func (hierarchy *simulatedHierarchy) SetFault(server string, fault serverFault) {
+	hierarchy.mutex.Lock()
+	defer hierarchy.mutex.Unlock()
+	hierarchy.faults[simulatedAddress(server)] = fault
+}
+
+//Returns the questions received by the name server with the given IP address, as "name TYPE", in the order they were received.
+func (hierarchy *simulatedHierarchy) Queries(server string) []string {
+	hierarchy.mutex.Lock()
+	defer hierarchy.mutex.Unlock()
+	return append([]string{}, hierarchy.queries[simulatedAddress(server)]...)
+}
+
+//Returns true if any name server received the given question, given as "name TYPE".
+func (hierarchy *simulatedHierarchy) Received(question string) bool {
+	hierarchy.mutex.Lock()
+	defer hierarchy.mutex.Unlock()
+	for _, queries := range hierarchy.queries {
+		for _, query := range queries {
+			if query == question {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//Signs the zone with the given origin with a new ECDSA P-256 key and an NSEC chain, with signatures valid until the given expiration
+//time, and returns the key. The DS records of the keys of the child zones given are added to their delegations before signing.
+func (hierarchy *simulatedHierarchy) SignZone(t *testing.T, origin string, expiration time.Time, children ...*ZoneKey) *ZoneKey {
+	t.Helper()
+	return hierarchy.SignZoneWith(t, origin, ALGORITHM_ECDSAP256SHA256, nil, expiration, children...)
+}
+
+//Signs the zone with the given origin with a new key of the given algorithm, with signatures valid until the given expiration time,
+//and returns the key. The zone is given an NSEC3 chain with the given parameters, or an NSEC chain if they are nil. The DS records of
+//the keys of the child zones given are added to their delegations before signing.
+func (hierarchy *simulatedHierarchy) SignZoneWith(t *testing.T, origin string, algorithm uint8, NSEC3 *NSEC3Resource, expiration time.Time, children ...*ZoneKey) *ZoneKey {
+	t.Helper()
+	key, err := GenerateZoneKey(origin, algorithm, true, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zone := hierarchy.zone(origin)
+	records := append([]Resource{}, zone.records...)
+	for _, child := range children {
+		records = append(records, child.DS())
+	}
+	signer := ZoneSigner{Zone: origin, Keys: []*ZoneKey{key}, Inception: expiration.Add(-48 * time.Hour), Expiration: expiration, NSEC3: NSEC3}
+	zone.records, err = signer.Sign(records)
+	if err != nil {
+		t.Fatalf("signing zone %s: %s", origin, err.Error())
+	}
+	return key
+}
+
+//Returns the zone with the given origin.
+func (hierarchy *simulatedHierarchy) zone(origin string) *simulatedZone {
+	for _, zone := range hierarchy.zones {
+		if zone.origin == Canonicalize(origin) {
+			return zone
+		}
+	}
+	return nil
+}
+
+//Returns the handler answering the queries sent to the name server at the given address.
+func (hierarchy *simulatedHierarchy) handler(address string) MemoryHandler {
+	return func(request *Message) *Message {
+		question := request.Questions[0]
+		hierarchy.mutex.Lock()
+		hierarchy.queries[address] = append(hierarchy.queries[address], question.Name.Value + WHITESPACE + question.Type.String())
+		fault := hierarchy.faults[address]
+		hierarchy.mutex.Unlock()
+		if fault == FAULT_TIMEOUT {
+			return nil
+		}
+
+		response := NewMessage(MSG_RESPONSE, request.Header.Identifier)
+		response.Header.SetRecursionDesired(request.Header.RecursionDesired)
+		response.Questions = append(response.Questions, request.Questions...)
+		response.Header.SetQuestionCount(uint16(len(response.Questions)))
+		zone := hierarchy.zoneFor(address, question.Name.Value)
+		if fault == FAULT_SERVER_FAILURE {
+			response.Header.SetResponseCode(RC_SERVFAIL)
+		} else if fault == FAULT_REFUSED || zone == nil {
+			response.Header.SetResponseCode(RC_REFUSED)
+		} else {
+			zone.answer(response, question.Name.Value, question.Type, request.IsDNSSECOK())
+		}
+		return response
+	}
+}
+
+//Returns the closest enclosing zone of the domain name among those served by the name server at the given address, or nil if it
+//serves none of them.
+func (hierarchy *simulatedHierarchy) zoneFor(address string, name string) *simulatedZone {
+	var closest *simulatedZone
+	for _, zone := range hierarchy.zones {
+		if !IsSubDomain(name, zone.origin) || (closest != nil && CountLabels(zone.origin) <= CountLabels(closest.origin)) {
+			continue
+		}
+		for _, server := range zone.servers {
+			if server == address {
+				closest = zone
+			}
+		}
+	}
+	return closest
+}
+
+//Fills in the response to the query for the records of the given type of the domain name: a referral if the name lies below a zone
+//cut, and otherwise an authoritative answer, chasing CNAME records within the zone and expanding the wildcard at the closest encloser
+//of a name that does not exist, or a negative answer carrying the SOA record. The DS records of a zone cut are answered by the parent
+//zone. When the query sets the DO bit, the records of a signed zone are sent along with their RRSIG records, answers expanded from a
+//wildcard along with the NSEC or NSEC3 record covering the name, and negative answers along with the NSEC or NSEC3 records proving them.
+func (zone *simulatedZone) answer(response *Message, name string, recType RecordType, dnssec bool) {
+	addRRSet := func(add func([]Resource), RRs []Resource) {
+		add(RRs)
+		if dnssec && len(RRs) > 0 {
+			add(zone.signatures(RRs[0].Name.Value, RRs[0].Type))
+		}
+	}
+
+	cut := zone.delegationFor(name)
+	if cut != "" && !(recType == TYPE_DS && cut == Canonicalize(name)) {
+		NS_RRs := zone.find(cut, TYPE_NS)
+		response.AddAuthorities(NS_RRs)
+		for _, NS_RR := range NS_RRs {
+			response.Additional = append(response.Additional, zone.find(NS_RR.GetData(), TYPE_A)...)
+			response.Additional = append(response.Additional, zone.find(NS_RR.GetData(), TYPE_AAAA)...)
+		}
+		response.Header.SetAdditionalRecordCount(uint16(len(response.Additional)))
+		return
+	}
+
+	response.Header.Authoritative = true
+	visited := make(map[string]bool)
+	for {
+		if visited[Canonicalize(name)] {
+			//The CNAME records of the zone point at each other, which is left for the resolver to detect.
+			return
+		}
+		visited[Canonicalize(name)] = true
+		RRs := zone.find(name, recType)
+		if len(RRs) > 0 {
+			addRRSet(response.AddAnswers, RRs)
+			return
+		}
+
+		wildcard := "*." + zone.closestEncloser(name)
+		if !zone.exists(name) && len(zone.find(wildcard, recType)) > 0 {
+			expanded := zone.find(wildcard, recType)
+			if dnssec {
+				expanded = append(expanded, zone.signatures(wildcard, recType)...)
+			}
+			for _, rr := range expanded {
+				rr.Name = DomainName{}
+				rr.Name.Initialize(name)
+				response.AddAnswers([]Resource{rr})
+			}
+			if dnssec {
+				for _, rr := range zone.records {
+					if rr.Type == TYPE_NSEC && zone.covers(rr, name) {
+						addRRSet(response.AddAuthorities, []Resource{rr})
+					}
+				}
+				nextCloser := LastLabels(name, CountLabels(zone.closestEncloser(name)) + 1)
+				if NSEC3_RR, ok := zone.coveringNSEC3(nextCloser); ok {
+					addRRSet(response.AddAuthorities, []Resource{NSEC3_RR})
+				}
+			}
+			return
+		}
+
+		CNAME_RRs := zone.find(name, TYPE_CNAME)
+		if recType == TYPE_CNAME || len(CNAME_RRs) == 0 {
+			break
+		}
+		addRRSet(response.AddAnswers, CNAME_RRs)
+		name = CNAME_RRs[0].GetData()
+		if !IsSubDomain(name, zone.origin) || zone.delegationFor(name) != "" {
+			return
+		}
+	}
+
+	if !zone.exists(name) {
+		response.Header.SetResponseCode(RC_NXDOMAIN)
+	}
+	addRRSet(response.AddAuthorities, zone.find(zone.origin, TYPE_SOA))
+	if dnssec {
+		for _, NSEC_RR := range zone.denial(name) {
+			addRRSet(response.AddAuthorities, []Resource{NSEC_RR})
+		}
+	}
+}
+
+//Returns the RRSIG records of the zone covering the records of the given type owned by the domain name.
+func (zone *simulatedZone) signatures(name string, recType RecordType) []Resource {
+	signatures := make([]Resource, 0)
+	for _, sig := range zone.find(name, TYPE_RRSIG) {
+		if sig.Rdata.(*RRSIGResource).TypeCovered == recType {
+			signatures = append(signatures, sig)
+		}
+	}
+	return signatures
+}
+
+//Returns the NSEC records of the zone proving that the domain name has no records of the type asked for, or does not exist: the
+//NSEC record owned by the name, or else those covering the name and the wildcard of its closest encloser. A zone signed with NSEC3
+//records gives the NSEC3 record matching the name, or else the closest encloser proof.
+func (zone *simulatedZone) denial(name string) []Resource {
+	if len(zone.find(zone.origin, TYPE_NSEC3PARAM)) > 0 {
+		return zone.nsec3Denial(name)
+	}
+
+	NSEC_RRs := zone.find(name, TYPE_NSEC)
+	if len(NSEC_RRs) > 0 {
+		return NSEC_RRs
+	}
+
+	encloser := zone.closestEncloser(name)
+	proof := make([]Resource, 0)
+	for _, rr := range zone.records {
+		if rr.Type == TYPE_NSEC && (zone.covers(rr, name) || zone.covers(rr, "*." + encloser)) && !slices.ContainsFunc(proof, func(added Resource) bool { return added.Name.Value == rr.Name.Value }) {
+			proof = append(proof, rr)
+		}
+	}
+	return proof
+}
+
+//Returns the NSEC3 records of the zone proving that the domain name has no records of the type asked for, or does not exist: the
+//NSEC3 record matching the name, or else the NSEC3 records matching its closest provable encloser and covering the next closer name,
+//along with the NSEC3 record matching or covering the wildcard at the closest encloser (RFC 5155 - Section 7.2).
+func (zone *simulatedZone) nsec3Denial(name string) []Resource {
+	match, ok := zone.matchingNSEC3(name)
+	if ok {
+		return []Resource{match}
+	}
+
+	proof := make([]Resource, 0)
+	addProof := func(rr Resource, ok bool) {
+		if ok && !slices.ContainsFunc(proof, func(added Resource) bool { return added.Name.Value == rr.Name.Value }) {
+			proof = append(proof, rr)
+		}
+	}
+	//Names left out of an opt-out chain have no NSEC3 record, so the closest encloser is the closest ancestor that has one.
+	for labels := CountLabels(name) - 1; labels >= CountLabels(zone.origin); labels-- {
+		encloser, ok := zone.matchingNSEC3(LastLabels(name, labels))
+		if !ok {
+			continue
+		}
+		addProof(encloser, true)
+		addProof(zone.coveringNSEC3(LastLabels(name, labels + 1)))
+		wildcard, ok := zone.matchingNSEC3(wildcardOf(LastLabels(name, labels)))
+		if !ok {
+			wildcard, ok = zone.coveringNSEC3(wildcardOf(LastLabels(name, labels)))
+		}
+		addProof(wildcard, ok)
+		break
+	}
+	return proof
+}
+
+//Returns the NSEC3 record of the zone whose owner name is the hash of the domain name.
+func (zone *simulatedZone) matchingNSEC3(name string) (Resource, bool) {
+	for _, rr := range zone.records {
+		if rr.Type == TYPE_NSEC3 && bytes.Equal(zone.hashOf(rr, name), nsec3OwnerHash(rr)) {
+			return rr, true
+		}
+	}
+	return Resource{}, false
+}
+
+//Returns the NSEC3 record of the zone whose owner name hashes before the hash of the domain name and whose next hashed owner name
+//hashes after it, wrapping around at the end of the chain.
+func (zone *simulatedZone) coveringNSEC3(name string) (Resource, bool) {
+	for _, rr := range zone.records {
+		if rr.Type != TYPE_NSEC3 {
+			continue
+		}
+		hash, owner, next := zone.hashOf(rr, name), nsec3OwnerHash(rr), rr.Rdata.(*NSEC3Resource).NextHashedOwner
+		if bytes.Compare(next, owner) > 0 && bytes.Compare(owner, hash) < 0 && bytes.Compare(hash, next) < 0 {
+			return rr, true
+		} else if bytes.Compare(next, owner) <= 0 && (bytes.Compare(owner, hash) < 0 || bytes.Compare(hash, next) < 0) {
+			return rr, true
+		}
+	}
+	return Resource{}, false
+}
+
+//Returns the hash of the domain name with the parameters of the NSEC3 record.
+func (zone *simulatedZone) hashOf(NSEC3_RR Resource, name string) []byte {
+	nsec3 := NSEC3_RR.Rdata.(*NSEC3Resource)
+	return HashNSEC3Name(name, nsec3.Iterations, nsec3.Salt)
+}
+
+//Returns the closest ancestor of the domain name, or the name itself, that exists in the zone.
+func (zone *simulatedZone) closestEncloser(name string) string {
+	encloser := Canonicalize(name)
+	for !zone.exists(encloser) && encloser != zone.origin {
+		encloser = LastLabels(encloser, CountLabels(encloser) - 1)
+	}
+	return encloser
+}
+
+//Returns true if the NSEC record lies before the domain name in canonical order and points to a name after it, or back to the apex.
+func (zone *simulatedZone) covers(NSEC_RR Resource, name string) bool {
+	next := NSEC_RR.Rdata.(*NSECResource).NextDomain.Value
+	return CompareNames(NSEC_RR.Name.Value, name) < 0 && (CompareNames(name, next) < 0 || next == zone.origin)
+}
+
+//Returns the zone cut closest to the domain name within the zone, or an empty string if the name is not below a zone cut.
+func (zone *simulatedZone) delegationFor(name string) string {
+	cut := ""
+	for _, record := range zone.records {
+		owner := record.Name.Value
+		if record.Type == TYPE_NS && owner != zone.origin && IsSubDomain(name, owner) && CountLabels(owner) > CountLabels(cut) {
+			cut = owner
+		}
+	}
+	return cut
+}
+
+//Returns the records of the given type owned by the domain name.
+func (zone *simulatedZone) find(name string, recType RecordType) []Resource {
+	records := make([]Resource, 0)
+	for _, record := range zone.records {
+		if record.Type == recType && record.Name.Value == Canonicalize(name) {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+//Returns true if the domain name owns records, or is an empty non-terminal with records below it.
+func (zone *simulatedZone) exists(name string) bool {
+	for _, record := range zone.records {
+		if IsSubDomain(record.Name.Value, name) {
+			return true
+		}
+	}
+	return false
+}
+
+//Exchanger counting the queries sent through it.
+type countingExchanger struct {
+	//Exchanger the queries are sent through.
+	Exchanger Exchanger
+	//Number of queries sent.
+	count *atomic.Int32
+}
+
+//Counts the query and sends it through the wrapped exchanger.
+func (ce *countingExchanger) Exchange(ctx context.Context, request *Message, address string) (*Message, error) {
+	ce.count.Add(1)
+	return ce.Exchanger.Exchange(ctx, request, address)
+}
+
+//Returns the address of the simulated name server with the given IP address.
+func simulatedAddress(server string) string {
+	return net.JoinHostPort(server, "53")
+}
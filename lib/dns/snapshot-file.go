@@ -0,0 +1,167 @@
+package dns
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+)
+
+//Compact binary snapshot of the cached resource records. Each record is stored as a length-prefixed
+//domain name, type, class, TTL, last modified time (unix seconds) and a length-prefixed record value.
+type SnapshotFile struct {
+	//In-memory store holding the resource records present in the snapshot.
+	MemoryStore
+	//Local file path of the snapshot file.
+	LocalFilePath string
+}
+
+//Initialize the attributes of SnapshotFile instance. A missing snapshot file is treated as an empty cache.
+func (sf *SnapshotFile) Initialize(filePath string) error {
+	sf.rrsets = make(map[string]map[RecordType][]LocalResource)
+	sf.lastSweep = time.Now().UTC()
+	sf.LocalFilePath = filePath
+	err := sf.Load()
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+//Load the RRs from the snapshot file into memory. A snapshot that cannot be read is reported with an error wrapping ErrInvalidSnapshot
+//that says which part of the file is missing or unsupported.
+func (sf *SnapshotFile) Load() error {
+	fileHandler, err := os.Open(sf.LocalFilePath)
+	if err != nil {
+		return err
+	}
+	defer fileHandler.Close()
+	reader := bufio.NewReader(fileHandler)
+
+	magic := make([]byte, len(SNAPSHOT_MAGIC))
+	_, err = io.ReadFull(reader, magic)
+	if err != nil {
+		return fmt.Errorf("%w: reading the header of %s: %s", ErrInvalidSnapshot, sf.LocalFilePath, err.Error())
+	}
+	if string(magic) != SNAPSHOT_MAGIC {
+		return fmt.Errorf("%w: %s is not a cache snapshot", ErrInvalidSnapshot, sf.LocalFilePath)
+	}
+
+	var version uint8
+	var count uint32
+	err = binary.Read(reader, binary.BigEndian, &version)
+	if err != nil {
+		return fmt.Errorf("%w: reading the version of %s: %s", ErrInvalidSnapshot, sf.LocalFilePath, err.Error())
+	}
+	if version != SNAPSHOT_VERSION {
+		return fmt.Errorf("%w: %s has version %d, expected version %d", ErrInvalidSnapshot, sf.LocalFilePath, version, SNAPSHOT_VERSION)
+	}
+	err = binary.Read(reader, binary.BigEndian, &count)
+	if err != nil {
+		return fmt.Errorf("%w: reading the record count of %s: %s", ErrInvalidSnapshot, sf.LocalFilePath, err.Error())
+	}
+
+	//The record count is not trusted to size the records up front, since a corrupt snapshot could claim billions of records.
+	records := make([]LocalResource, 0)
+	for index := uint32(0); index < count; index++ {
+		name, err := readSnapshotString(reader, 1)
+		if err != nil {
+			return fmt.Errorf("%w: reading the name of record %d of %d in %s: %s", ErrInvalidSnapshot, index + 1, count, sf.LocalFilePath, err.Error())
+		}
+		var fields struct {
+			Type RecordType
+			Class ClassType
+			TTL uint32
+			LastModified int64
+		}
+		err = binary.Read(reader, binary.BigEndian, &fields)
+		if err != nil {
+			return fmt.Errorf("%w: reading record %d of %d in %s: %s", ErrInvalidSnapshot, index + 1, count, sf.LocalFilePath, err.Error())
+		}
+		data, err := readSnapshotString(reader, 2)
+		if err != nil {
+			return fmt.Errorf("%w: reading the data of record %d of %d in %s: %s", ErrInvalidSnapshot, index + 1, count, sf.LocalFilePath, err.Error())
+		}
+
+		localResource := LocalResource{}
+		localResource.resource = NewResourceRecord(name, fields.TTL, fields.Class.String(), fields.Type.String(), data)
+		localResource.LastModified = time.Unix(fields.LastModified, 0).UTC()
+		records = append(records, localResource)
+	}
+
+	sf.mutex.Lock()
+	sf.rrsets = make(map[string]map[RecordType][]LocalResource)
+	for _, record := range records {
+		sf.insert(record)
+	}
+	sf.mutex.Unlock()
+	return nil
+}
+
+//Persists the unexpired in-memory RRs to the snapshot file. The snapshot is replaced atomically, so a failed write leaves the previous
+//snapshot intact.
+func (sf *SnapshotFile) Sync() error {
+	records := make([]LocalResource, 0)
+	for _, rr := range sf.Records() {
+		if !rr.HasExpired() {
+			records = append(records, rr)
+		}
+	}
+
+	return writeFileAtomically(sf.LocalFilePath, func(writer *bufio.Writer) error {
+		writer.WriteString(SNAPSHOT_MAGIC)
+		writer.WriteByte(SNAPSHOT_VERSION)
+		writer.Write(PackUInt32(uint32(len(records))))
+		for _, rr := range records {
+			writeSnapshotString(writer, rr.resource.Name.Value, 1)
+			writer.Write(PackUInt16(uint16(rr.resource.Type)))
+			writer.Write(PackUInt16(uint16(rr.resource.Class)))
+			writer.Write(PackUInt32(rr.resource.TTL))
+			binary.Write(writer, binary.BigEndian, rr.LastModified.Unix())
+			writeSnapshotString(writer, rr.resource.GetData(), 2)
+		}
+		//The writer keeps the first error it runs into and reports it when flushed.
+		return nil
+	})
+}
+
+//Persists the in-memory RR changes to the snapshot file.
+func (sf *SnapshotFile) Flush() error {
+	return sf.Sync()
+}
+
+//Reads a string prefixed with its length, where the length occupies 'prefixSize' bytes (1 or 2).
+func readSnapshotString(reader io.Reader, prefixSize int) (string, error) {
+	prefix := make([]byte, prefixSize)
+	_, err := io.ReadFull(reader, prefix)
+	if err != nil {
+		return "", err
+	}
+
+	length := int(prefix[0])
+	if prefixSize == 2 {
+		length = int(UnpackUInt16(prefix))
+	}
+
+	value := make([]byte, length)
+	_, err = io.ReadFull(reader, value)
+	if err != nil {
+		return "", err
+	}
+	return string(value), nil
+}
+
+//Writes the string prefixed with its length, where the length occupies 'prefixSize' bytes (1 or 2).
+func writeSnapshotString(writer *bufio.Writer, value string, prefixSize int) {
+	if prefixSize == 2 {
+		writer.Write(PackUInt16(uint16(len(value))))
+	} else {
+		writer.WriteByte(byte(len(value)))
+	}
+	writer.WriteString(value)
+}
@@ -0,0 +1,355 @@
+package dns
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//Represents a SVCB-type Resource Record body (RFC 9460). A record with priority 0 is in alias mode and points clients to another
+//domain name providing the service, while any other priority is in service mode and carries the parameters of an endpoint.
+type SVCBResource struct {
+	//Priority of the endpoint, 0 for alias mode.
+	Priority uint16
+	//Domain name of the endpoint, or of the alias in alias mode. The root domain name stands for the owner of the record.
+	Target DomainName
+	//Service parameters of the endpoint, sorted by key.
+	Params []SvcParam
+}
+
+//HTTPS-type records share the format of SVCB-type records (RFC 9460 - Section 9).
+type HTTPSResource = SVCBResource
+
+//Represents a single service parameter of a SVCB or HTTPS record, kept in its wire format.
+type SvcParam struct {
+	//Key identifying the parameter.
+	Key uint16
+	//Value of the parameter in wire format.
+	Value []byte
+}
+
+//Initializes the SVCB-type record value from its presentation format - "priority target key=value ...".
+func (svcb *SVCBResource) Initialize(data string) {
+	tokens := splitPresentation(data)
+	for len(tokens) < 2 {
+		tokens = append(tokens, "")
+	}
+	priority, _ := strconv.ParseUint(tokens[0], 10, 16)
+	svcb.Priority = uint16(priority)
+	svcb.Target = DomainName{}
+	svcb.Target.Initialize(tokens[1])
+	svcb.Params = make([]SvcParam, 0)
+	for _, token := range tokens[2:] {
+		param, ok := parseSvcParam(token)
+		if ok {
+			svcb.Params = append(svcb.Params, param)
+		}
+	}
+	sort.SliceStable(svcb.Params, func(i int, j int) bool {
+		return svcb.Params[i].Key < svcb.Params[j].Key
+	})
+}
+
+//Packs the SVCB-type record value into a stream of bytes. The target name is never compressed, as per RFC 9460 - Section 2.2.
+func (svcb *SVCBResource) Pack() []byte {
+	buffer := make([]byte, 0)
+	buffer = append(buffer, PackUInt16(svcb.Priority)...)
+	buffer = append(buffer, svcb.Target.Pack(make(CompressionMap), 0)...)
+	for _, param := range svcb.Params {
+		buffer = append(buffer, PackUInt16(param.Key)...)
+		buffer = append(buffer, PackUInt16(uint16(len(param.Value)))...)
+		buffer = append(buffer, param.Value...)
+	}
+	return buffer
+}
+
+//Unpacks a stream of bytes into a SVCB-type resource record value.
+func (svcb *SVCBResource) UnpackBody(buffer []byte, offset int, dataLength int) (int, error) {
+	endOffset := offset + dataLength
+	err := checkRecordData(buffer, TYPE_SVCB, offset, 2, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	svcb.Priority = UnpackUInt16(buffer[offset: offset + 2])
+	svcb.Target = DomainName{}
+	offset, err = svcb.Target.Unpack(buffer, offset + 2)
+	if err != nil {
+		return offset, err
+	}
+	err = checkRecordData(buffer, TYPE_SVCB, offset, 0, endOffset)
+	if err != nil {
+		return offset, err
+	}
+	svcb.Params = make([]SvcParam, 0)
+	for offset < endOffset {
+		err = checkRecordData(buffer, TYPE_SVCB, offset, 4, endOffset)
+		if err != nil {
+			return offset, err
+		}
+		param := SvcParam{}
+		param.Key = UnpackUInt16(buffer[offset: offset + 2])
+		length := int(UnpackUInt16(buffer[offset + 2: offset + 4]))
+		err = checkRecordData(buffer, TYPE_SVCB, offset + 4, length, endOffset)
+		if err != nil {
+			return offset, err
+		}
+		param.Value = append([]byte{}, buffer[offset + 4: offset + 4 + length]...)
+		svcb.Params = append(svcb.Params, param)
+		offset = offset + 4 + length
+	}
+	return endOffset, nil
+}
+
+//Returns true if the record is in alias mode.
+func (svcb *SVCBResource) IsAlias() bool {
+	return svcb.Priority == 0
+}
+
+//Returns the service parameter with the given key, if the record carries it.
+func (svcb *SVCBResource) GetParam(key uint16) (SvcParam, bool) {
+	for _, param := range svcb.Params {
+		if param.Key == key {
+			return param, true
+		}
+	}
+	return SvcParam{}, false
+}
+
+//Returns the string representation of SVCB-type record value.
+func (svcb *SVCBResource) String() string {
+	values := make([]string, 0)
+	values = append(values, strconv.Itoa(int(svcb.Priority)))
+	values = append(values, svcb.Target.String())
+	for _, param := range svcb.Params {
+		values = append(values, param.String())
+	}
+	return strings.Join(values, WHITESPACE)
+}
+
+//Returns the presentation format of the service parameter - "key=value", or just "key" for parameters without a value.
+func (param SvcParam) String() string {
+	name := SvcParamKeyName(param.Key)
+	value := param.Value
+	list := make([]string, 0)
+	switch param.Key {
+	case SVC_PARAM_MANDATORY:
+		for index := 0; index + 2 <= len(value); index += 2 {
+			list = append(list, SvcParamKeyName(UnpackUInt16(value[index: index + 2])))
+		}
+	case SVC_PARAM_ALPN:
+		for index := 0; index < len(value); {
+			length := int(value[index])
+			end := min(index + 1 + length, len(value))
+			list = append(list, escapeSvcValue(value[index + 1: end], true))
+			index = end
+		}
+	case SVC_PARAM_NO_DEFAULT_ALPN:
+		return name
+	case SVC_PARAM_PORT:
+		if len(value) != 2 {
+			return name + "=" + escapeSvcValue(value, false)
+		}
+		list = append(list, strconv.Itoa(int(UnpackUInt16(value))))
+	case SVC_PARAM_IPV4HINT:
+		for index := 0; index + 4 <= len(value); index += 4 {
+			list = append(list, getIPAddress(value[index: index + 4]))
+		}
+	case SVC_PARAM_IPV6HINT:
+		for index := 0; index + 16 <= len(value); index += 16 {
+			list = append(list, getIPAddress(value[index: index + 16]))
+		}
+	case SVC_PARAM_ECH:
+		list = append(list, base64.StdEncoding.EncodeToString(value))
+	default:
+		if len(value) == 0 {
+			return name
+		}
+		list = append(list, escapeSvcValue(value, false))
+	}
+	return name + "=" + strings.Join(list, ",")
+}
+
+//Returns the presentation name of the given service parameter key, as "keyNNNNN" for keys without a name.
+func SvcParamKeyName(key uint16) string {
+	for name, value := range SvcParamKeys {
+		if value == key {
+			return name
+		}
+	}
+	return fmt.Sprintf("key%d", key)
+}
+
+//Returns the service parameter key with the given presentation name, accepting "keyNNNNN" for any key.
+func SvcParamKeyNumber(name string) (uint16, bool) {
+	name = strings.ToLower(name)
+	key, ok := SvcParamKeys[name]
+	if ok {
+		return key, true
+	}
+
+	if strings.HasPrefix(name, "key") {
+		value, err := strconv.ParseUint(strings.TrimPrefix(name, "key"), 10, 16)
+		if err == nil {
+			return uint16(value), true
+		}
+	}
+	return 0, false
+}
+
+//Parses a service parameter from its presentation format into its wire format. Returns false if the parameter is invalid.
+func parseSvcParam(token string) (SvcParam, bool) {
+	name, value, _ := strings.Cut(token, "=")
+	key, ok := SvcParamKeyNumber(name)
+	if !ok {
+		return SvcParam{}, false
+	}
+
+	param := SvcParam{Key: key, Value: make([]byte, 0)}
+	switch key {
+	case SVC_PARAM_MANDATORY:
+		for _, item := range splitSvcValueList(value) {
+			mandatoryKey, ok := SvcParamKeyNumber(item)
+			if !ok {
+				return SvcParam{}, false
+			}
+			param.Value = append(param.Value, PackUInt16(mandatoryKey)...)
+		}
+	case SVC_PARAM_ALPN:
+		for _, item := range splitSvcValueList(value) {
+			param.Value = append(param.Value, byte(len(item)))
+			param.Value = append(param.Value, []byte(item)...)
+		}
+	case SVC_PARAM_NO_DEFAULT_ALPN:
+	case SVC_PARAM_PORT:
+		port, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return SvcParam{}, false
+		}
+		param.Value = PackUInt16(uint16(port))
+	case SVC_PARAM_IPV4HINT, SVC_PARAM_IPV6HINT:
+		for _, item := range splitSvcValueList(value) {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return SvcParam{}, false
+			} else if key == SVC_PARAM_IPV4HINT {
+				ip = ip.To4()
+			} else {
+				ip = ip.To16()
+			}
+			param.Value = append(param.Value, ip...)
+		}
+	case SVC_PARAM_ECH:
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return SvcParam{}, false
+		}
+		param.Value = decoded
+	default:
+		param.Value = []byte(unescapeSvcValue(value))
+	}
+	return param, true
+}
+
+//Splits the presentation format of a record value into tokens separated by whitespace. Quoted text is kept within a single token
+//with the quotes removed, while backslash escapes are left in place to be resolved by the parser of each token.
+func splitPresentation(data string) []string {
+	tokens := make([]string, 0)
+	current := strings.Builder{}
+	quoted, escaped, started := false, false, false
+	for _, ch := range data {
+		if escaped {
+			current.WriteRune(ch)
+			escaped = false
+		} else if ch == '\\' {
+			current.WriteRune(ch)
+			escaped, started = true, true
+		} else if ch == '"' {
+			quoted, started = !quoted, true
+		} else if unicode.IsSpace(ch) && !quoted {
+			if started {
+				tokens = append(tokens, current.String())
+				current.Reset()
+				started = false
+			}
+		} else {
+			current.WriteRune(ch)
+			started = true
+		}
+	}
+
+	if started {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+//Splits a comma-separated value list (RFC 9460 - Appendix A.1) into its unescaped items. Commas escaped with a backslash are kept.
+func splitSvcValueList(value string) []string {
+	items := make([]string, 0)
+	if value == "" {
+		return items
+	}
+
+	start := 0
+	for index := 0; index < len(value); index++ {
+		if value[index] == '\\' {
+			index++
+		} else if value[index] == ',' {
+			items = append(items, unescapeSvcValue(value[start:index]))
+			start = index + 1
+		}
+	}
+	return append(items, unescapeSvcValue(value[start:]))
+}
+
+//Resolves the backslash escapes of a character string, both "\X" and the decimal "\DDD" form.
+func unescapeSvcValue(value string) string {
+	buffer := make([]byte, 0, len(value))
+	for index := 0; index < len(value); index++ {
+		if value[index] != '\\' || index + 1 == len(value) {
+			buffer = append(buffer, value[index])
+			continue
+		}
+
+		if index + 3 < len(value) && isDigits(value[index + 1: index + 4]) {
+			code, _ := strconv.Atoi(value[index + 1: index + 4])
+			buffer = append(buffer, byte(code))
+			index += 3
+		} else {
+			buffer = append(buffer, value[index + 1])
+			index++
+		}
+	}
+	return string(buffer)
+}
+
+//Returns the presentation format of a character string, escaping non-printable bytes as "\DDD" along with quotes, backslashes
+//and, for items of a value list, commas.
+func escapeSvcValue(value []byte, listItem bool) string {
+	builder := strings.Builder{}
+	for _, ch := range value {
+		if ch < 0x21 || ch > 0x7e {
+			builder.WriteString(fmt.Sprintf("\\%03d", ch))
+		} else if ch == '"' || ch == '\\' || (listItem && ch == ',') {
+			builder.WriteByte('\\')
+			builder.WriteByte(ch)
+		} else {
+			builder.WriteByte(ch)
+		}
+	}
+	return builder.String()
+}
+
+//Returns true if the string is made up of decimal digits only.
+func isDigits(value string) bool {
+	for _, ch := range value {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
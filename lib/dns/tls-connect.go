@@ -0,0 +1,149 @@
+package dns
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+//Structure to manage a single DNS-over-TLS connection (RFC 7858), which is kept open and reused for many queries. Queries are
+//pipelined: several may be outstanding at once, and each response is handed to the query with the same message ID, in whatever
+//order the server sends them.
+type TlsConnect struct {
+	Connection *tls.Conn
+	//Address and port of the remote server the messages are exchanged with.
+	RemoteAddress string
+	//Guards the queries waiting for a response and the writes to the connection.
+	mutex sync.Mutex
+	//Channels of the queries waiting for a response, keyed by their message ID.
+	pending map[uint16]chan []byte
+	//Closed once the connection can no longer be used.
+	closed chan struct{}
+	//Error that ended the connection.
+	err error
+}
+
+//Opens a TLS connection to the remote server with the given TLS configuration, giving up at the deadline.
+func (tc *TlsConnect) ConnectTo(RemoteAddress string, config *tls.Config, deadline time.Time) error {
+	dialer := net.Dialer{Deadline: deadline}
+	conn, err := tls.DialWithDialer(&dialer, "tcp", RemoteAddress, config)
+	if err != nil {
+		return err
+	}
+
+	tc.Connection = conn
+	tc.RemoteAddress = RemoteAddress
+	tc.pending = make(map[uint16]chan []byte)
+	tc.closed = make(chan struct{})
+	go tc.receive()
+	return nil
+}
+
+//Sends the message, prefixed with its length as every DNS message sent over a stream is (RFC 1035 - Section 4.2.2), and waits
+//until the response carrying the same message ID arrives or the deadline passes.
+func (tc *TlsConnect) Exchange(buffer []byte, deadline time.Time) ([]byte, error) {
+	if len(buffer) < MESSAGE_HEADER_LENGTH {
+		return nil, ErrParametersMissing
+	}
+
+	id := UnpackUInt16(buffer[:2])
+	waiting := make(chan []byte, 1)
+	tc.mutex.Lock()
+	if tc.IsClosed() {
+		tc.mutex.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrConnectionClosed, tc.RemoteAddress)
+	} else if _, exists := tc.pending[id]; exists {
+		tc.mutex.Unlock()
+		return nil, fmt.Errorf("%w: message ID %d is already in use", ErrParametersMissing, id)
+	}
+	tc.pending[id] = waiting
+	tc.Connection.SetWriteDeadline(deadline)
+	_, err := tc.Connection.Write(append(PackUInt16(uint16(len(buffer))), buffer...))
+	tc.mutex.Unlock()
+	defer tc.forget(id)
+	if err != nil {
+		tc.Close()
+		return nil, err
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	select {
+	case response := <-waiting:
+		return response, nil
+	case <-tc.closed:
+		return nil, fmt.Errorf("%w: %s: %s", ErrConnectionClosed, tc.RemoteAddress, tc.err.Error())
+	case <-timer.C:
+		return nil, fmt.Errorf("%w: %s did not respond in time", ErrNoResponse, tc.RemoteAddress)
+	}
+}
+
+//Returns true if the connection has been closed, by either end, and can no longer be used.
+func (tc *TlsConnect) IsClosed() bool {
+	select {
+	case <-tc.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+//Close the TLS connection, failing the queries still waiting for a response.
+func (tc *TlsConnect) Close() error {
+	return tc.end(io.EOF)
+}
+
+//Reads the responses sent by the server and hands each to the query waiting for it. Responses nobody is waiting for, such as
+//those arriving after their query timed out, are dropped. Stops when the connection fails or is closed.
+func (tc *TlsConnect) receive() {
+	for {
+		header := make([]byte, 2)
+		_, err := io.ReadFull(tc.Connection, header)
+		if err != nil {
+			tc.end(err)
+			return
+		}
+
+		buffer := make([]byte, UnpackUInt16(header))
+		_, err = io.ReadFull(tc.Connection, buffer)
+		if err != nil {
+			tc.end(err)
+			return
+		} else if len(buffer) < MESSAGE_HEADER_LENGTH {
+			continue
+		}
+
+		tc.mutex.Lock()
+		waiting, exists := tc.pending[UnpackUInt16(buffer[:2])]
+		tc.mutex.Unlock()
+		if exists {
+			select {
+			case waiting <- buffer:
+			default:
+			}
+		}
+	}
+}
+
+//Removes the query with the given message ID from the queries waiting for a response.
+func (tc *TlsConnect) forget(id uint16) {
+	tc.mutex.Lock()
+	delete(tc.pending, id)
+	tc.mutex.Unlock()
+}
+
+//Marks the connection as closed with the given error, unless it already is, and closes the underlying connection.
+func (tc *TlsConnect) end(err error) error {
+	tc.mutex.Lock()
+	defer tc.mutex.Unlock()
+	if tc.IsClosed() {
+		return nil
+	}
+
+	tc.err = err
+	close(tc.closed)
+	return tc.Connection.Close()
+}
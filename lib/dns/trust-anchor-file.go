@@ -0,0 +1,372 @@
+package dns
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Represents the state of a trusted key in the key rollover state machine of RFC 5011 - Section 4.
+type AnchorState uint8
+
+//Returns the string representation of the anchor state.
+func (state AnchorState) String() string {
+	switch state {
+	case ANCHOR_ADD_PENDING:
+		return "ADDPEND"
+	case ANCHOR_VALID:
+		return "VALID"
+	case ANCHOR_MISSING:
+		return "MISSING"
+	case ANCHOR_REVOKED:
+		return "REVOKED"
+	default:
+		return ""
+	}
+}
+
+//Returns the anchor state with the given name.
+func ParseAnchorState(value string) (AnchorState, error) {
+	for _, state := range []AnchorState{ANCHOR_ADD_PENDING, ANCHOR_VALID, ANCHOR_MISSING, ANCHOR_REVOKED} {
+		if strings.EqualFold(value, state.String()) {
+			return state, nil
+		}
+	}
+	return 0, ErrInvalidAnchorState
+}
+
+//A trust anchor held by the trust anchor file, along with the state of its key.
+type TrustAnchor struct {
+	//DS or DNSKEY record of the key.
+	Record Resource
+	//State of the key.
+	State AnchorState
+	//Time the key entered its current state, or the zero time for anchors added to the file by hand.
+	LastChange time.Time
+}
+
+//Returns true if the anchor can be used to validate the keys of its zone, which is the case in the VALID and MISSING states.
+func (anchor *TrustAnchor) IsTrusted() bool {
+	return anchor.State == ANCHOR_VALID || anchor.State == ANCHOR_MISSING
+}
+
+//Returns the line representing the anchor in the trust anchor file - the record, followed by a comment holding its state.
+func (anchor *TrustAnchor) String() string {
+	line := fmt.Sprintf("%s%s%s state=%s", anchor.Record.CacheString(), WHITESPACE, ANCHOR_COMMENT, anchor.State.String())
+	if !anchor.LastChange.IsZero() {
+		line += " changed=" + anchor.LastChange.UTC().Format(time.RFC3339)
+	}
+	return line
+}
+
+//File holding the trust anchors DNSSEC validation starts from, as DS or DNSKEY records in presentation format, one record per line.
+//The state of each key is kept in a comment following its record, so that key rollovers (RFC 5011) are tracked across restarts.
+//Lines without a state are trusted. It is safe for concurrent use.
+type TrustAnchorFile struct {
+	//Guards the trust anchors against concurrent access.
+	mutex sync.Mutex
+	//Trust anchors present in the file.
+	Anchors []TrustAnchor
+	//Local file path of the trust anchor file.
+	LocalFilePath string
+}
+
+//Initialize the attributes of TrustAnchorFile instance. A missing trust anchor file is treated as holding the root zone keys built into the resolver.
+func (taf *TrustAnchorFile) Initialize(filePath string) error {
+	taf.Anchors = make([]TrustAnchor, 0)
+	taf.LocalFilePath = filePath
+	err := taf.Load()
+	if errors.Is(err, fs.ErrNotExist) {
+		for _, anchor := range rootTrustAnchors() {
+			taf.Anchors = append(taf.Anchors, TrustAnchor{Record: anchor, State: ANCHOR_VALID})
+		}
+		return nil
+	}
+
+	return err
+}
+
+//Load the trust anchors from the trust anchor file into memory. Lines starting with a comment are skipped.
+func (taf *TrustAnchorFile) Load() error {
+	fileHandler, err := os.Open(taf.LocalFilePath)
+	if err != nil {
+		return err
+	}
+	defer fileHandler.Close()
+
+	anchors := make([]TrustAnchor, 0)
+	scanner := bufio.NewScanner(fileHandler)
+	for scanner.Scan() {
+		record, comment, _ := strings.Cut(scanner.Text(), ANCHOR_COMMENT)
+		values := strings.Fields(record)
+		if len(values) == 0 {
+			continue
+		} else if len(values) < 5 {
+			return ErrParametersMissing
+		}
+
+		typeString := strings.ToUpper(values[3])
+		if typeString != TYPE_DS.String() && typeString != TYPE_DNSKEY.String() {
+			return ErrInvalidTrustAnchor
+		}
+
+		ttlValue := uint32(parseUIntString(values[1], 32))
+		anchor := TrustAnchor{State: ANCHOR_VALID}
+		anchor.Record = *NewResourceRecord(values[0], ttlValue, values[2], typeString, strings.Join(values[4:], WHITESPACE))
+		for _, field := range strings.Fields(comment) {
+			key, value, _ := strings.Cut(field, "=")
+			if key == "state" {
+				anchor.State, err = ParseAnchorState(value)
+				if err != nil {
+					return err
+				}
+			} else if key == "changed" {
+				anchor.LastChange, _ = time.Parse(time.RFC3339, value)
+			}
+		}
+		anchors = append(anchors, anchor)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return err
+	}
+
+	taf.mutex.Lock()
+	taf.Anchors = anchors
+	taf.mutex.Unlock()
+	return nil
+}
+
+//Persists the trust anchors, along with the state of their keys, to the trust anchor file. The file is replaced atomically, so that
+//a failed write never leaves the resolver without its trust anchors.
+func (taf *TrustAnchorFile) Flush() error {
+	return writeFileAtomically(taf.LocalFilePath, func(writer *bufio.Writer) error {
+		for _, anchor := range taf.List() {
+			_, err := writer.WriteString(anchor.String() + NEWLINE_SEPERATOR)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+//Returns a snapshot of the trust anchors in the file.
+func (taf *TrustAnchorFile) List() []TrustAnchor {
+	taf.mutex.Lock()
+	defer taf.mutex.Unlock()
+	anchors := make([]TrustAnchor, len(taf.Anchors))
+	copy(anchors, taf.Anchors)
+	return anchors
+}
+
+//Returns the zones that have trust anchors in the file.
+func (taf *TrustAnchorFile) Zones() []string {
+	zones := make([]string, 0)
+	for _, anchor := range taf.List() {
+		zone := Canonicalize(anchor.Record.Name.Value)
+		if !slices.Contains(zones, zone) {
+			zones = append(zones, zone)
+		}
+	}
+	return zones
+}
+
+//Returns true if the file holds anchors of the given zone, whether they are trusted or not.
+func (taf *TrustAnchorFile) HasAnchors(zone string) bool {
+	for _, anchor := range taf.List() {
+		if strings.EqualFold(anchor.Record.Name.Value, Canonicalize(zone)) {
+			return true
+		}
+	}
+	return false
+}
+
+//Returns the trusted anchors of the given zone as DS records, DNSKEY anchors being turned into DS records.
+func (taf *TrustAnchorFile) Trusted(zone string) []Resource {
+	records := make([]Resource, 0)
+	for _, anchor := range taf.List() {
+		if anchor.IsTrusted() && strings.EqualFold(anchor.Record.Name.Value, Canonicalize(zone)) {
+			record, err := trustAnchorDS(anchor.Record)
+			if err == nil {
+				records = append(records, record)
+			}
+		}
+	}
+	return records
+}
+
+//Applies the key rollover rules of RFC 5011 - Section 4 to the anchors of the zone, given its DNSKEY RRset and the RRSIG records covering
+//it, which must have been validated with a trusted key. New secure entry point keys are added in the ADDPEND state and trusted once
+//they have been seen for the add hold-down time, trusted keys missing from the RRset are kept in the MISSING state, and keys that
+//revoke themselves are no longer trusted and are dropped after the remove hold-down time. Returns true if the anchors changed.
+func (taf *TrustAnchorFile) Update(zone string, DNSKEY_RRs []Resource, signatures []Resource, now time.Time) bool {
+	zone = Canonicalize(zone)
+	taf.mutex.Lock()
+	defer taf.mutex.Unlock()
+
+	changed := false
+	seen := make([]bool, len(taf.Anchors))
+	for _, rr := range DNSKEY_RRs {
+		dnskey, ok := rr.Rdata.(*DNSKEYResource)
+		if !ok || !dnskey.IsSecureEntryPoint() {
+			continue
+		}
+
+		if dnskey.IsRevoked() {
+			if !isSelfSigned(DNSKEY_RRs, signatures, rr, now) {
+				continue
+			}
+			for index := range taf.Anchors {
+				anchor := &taf.Anchors[index]
+				if strings.EqualFold(anchor.Record.Name.Value, zone) && anchorMatchesKey(anchor.Record, rr) {
+					seen[index] = true
+					if anchor.State != ANCHOR_REVOKED {
+						anchor.State, anchor.LastChange, changed = ANCHOR_REVOKED, now, true
+					}
+				}
+			}
+			continue
+		}
+
+		index := taf.findKey(zone, rr)
+		if index < 0 {
+			anchor := TrustAnchor{Record: rr, State: ANCHOR_ADD_PENDING, LastChange: now}
+			if taf.trustsKey(zone, rr) {
+				//The key is already trusted through a DS anchor, so it is tracked from now on without waiting for the hold-down time.
+				anchor.State = ANCHOR_VALID
+			}
+			taf.Anchors = append(taf.Anchors, anchor)
+			seen = append(seen, true)
+			changed = true
+			continue
+		}
+
+		seen[index] = true
+		anchor := &taf.Anchors[index]
+		holdDown := max(ANCHOR_ADD_HOLD_DOWN, time.Duration(rr.TTL) * time.Second)
+		if (anchor.State == ANCHOR_ADD_PENDING && now.Sub(anchor.LastChange) >= holdDown) || anchor.State == ANCHOR_MISSING {
+			anchor.State, anchor.LastChange, changed = ANCHOR_VALID, now, true
+		}
+	}
+
+	retained := make([]TrustAnchor, 0, len(taf.Anchors))
+	for index, anchor := range taf.Anchors {
+		if !strings.EqualFold(anchor.Record.Name.Value, zone) {
+			retained = append(retained, anchor)
+			continue
+		}
+
+		if anchor.State == ANCHOR_REVOKED && now.Sub(anchor.LastChange) >= ANCHOR_REMOVE_HOLD_DOWN && taf.holdsUnrevokedKeys(zone) {
+			changed = true
+			continue
+		} else if !seen[index] && anchor.Record.Type == TYPE_DNSKEY {
+			if anchor.State == ANCHOR_ADD_PENDING {
+				//A key that disappears before its hold-down time is over is forgotten.
+				changed = true
+				continue
+			} else if anchor.State == ANCHOR_VALID {
+				anchor.State, anchor.LastChange, changed = ANCHOR_MISSING, now, true
+			}
+		}
+		retained = append(retained, anchor)
+	}
+
+	taf.Anchors = retained
+	return changed
+}
+
+//Returns the index of the DNSKEY anchor of the zone for the same key as the DNSKEY record, or -1 if there is none.
+func (taf *TrustAnchorFile) findKey(zone string, DNSKEY_RR Resource) int {
+	for index, anchor := range taf.Anchors {
+		if anchor.Record.Type == TYPE_DNSKEY && strings.EqualFold(anchor.Record.Name.Value, zone) && anchorMatchesKey(anchor.Record, DNSKEY_RR) {
+			return index
+		}
+	}
+	return -1
+}
+
+//Returns true if the zone has anchors that are not revoked. The revoked anchors of a zone are kept past the remove hold-down time
+//otherwise, so that the zone does not pass for unsigned once the last of them is dropped.
+func (taf *TrustAnchorFile) holdsUnrevokedKeys(zone string) bool {
+	for _, anchor := range taf.Anchors {
+		if anchor.State != ANCHOR_REVOKED && strings.EqualFold(anchor.Record.Name.Value, zone) {
+			return true
+		}
+	}
+	return false
+}
+
+//Returns true if a trusted anchor of the zone is for the same key as the DNSKEY record.
+func (taf *TrustAnchorFile) trustsKey(zone string, DNSKEY_RR Resource) bool {
+	for _, anchor := range taf.Anchors {
+		if anchor.IsTrusted() && strings.EqualFold(anchor.Record.Name.Value, zone) && anchorMatchesKey(anchor.Record, DNSKEY_RR) {
+			return true
+		}
+	}
+	return false
+}
+
+//Follows the key rollover of a zone with trust anchors in the trust anchor file, given its DNSKEY RRset and the RRSIG records covering
+//it that have just been authenticated, and saves the trust anchor file if the state of a key changed.
+func (resolver *Resolver) trackKeyRollover(zone string, DNSKEY_RRs []Resource, signatures []Resource) {
+	if resolver.anchorFile == nil || !resolver.anchorFile.Update(zone, DNSKEY_RRs, signatures, time.Now()) {
+		return
+	}
+
+	resolver.Log(fmt.Sprintf("Trust anchors of %s changed by a key rollover.", Canonicalize(zone)))
+	err := resolver.anchorFile.Flush()
+	if err != nil {
+		resolver.Log(err.Error())
+	}
+}
+
+// Authenticates the DNSKEY records of every zone with trust anchors in the trust anchor file right away, which moves their keys along
+// the RFC 5011 key rollover, and saves the trust anchor file. DNSSEC validation must be enabled so that the signatures are fetched.
+// Returns an error if the keys of a zone could not be authenticated.
+func (resolver *Resolver) RefreshTrustAnchors() error {
+	if resolver.anchorFile == nil {
+		return ErrParametersMissing
+	}
+
+	for _, zone := range resolver.anchorFile.Zones() {
+		resolver.startQuery(zone, TYPE_DNSKEY)
+		resolver.zoneKeyCache.Delete(zone)
+		entry := resolver.zoneKeys(zone)
+		if entry.status == SECURITY_BOGUS {
+			return fmt.Errorf("%w: DNSKEY records of %s: %s", ErrBogus, zone, entry.err.Error())
+		}
+	}
+	return resolver.anchorFile.Flush()
+}
+
+//Returns true if the anchor is for the same key as the DNSKEY record, leaving the revoke flag of the record aside.
+func anchorMatchesKey(anchor Resource, DNSKEY_RR Resource) bool {
+	dnskey := DNSKEY_RR.Rdata.(*DNSKEYResource)
+	if key, ok := anchor.Rdata.(*DNSKEYResource); ok {
+		return key.Algorithm == dnskey.Algorithm && bytes.Equal(key.PublicKey, dnskey.PublicKey)
+	}
+
+	unrevoked := *dnskey
+	unrevoked.Flags &^= DNSKEY_REVOKE_FLAG
+	DNSKEY_RR.Rdata = &unrevoked
+	return matchesDS(DNSKEY_RR, []Resource{anchor})
+}
+
+//Returns true if one of the RRSIG records over the DNSKEY RRset was made with the given key, as a key revoking itself must do.
+func isSelfSigned(DNSKEY_RRs []Resource, signatures []Resource, DNSKEY_RR Resource, now time.Time) bool {
+	for _, sig := range signatures {
+		if _, err := verifyRRSIG(DNSKEY_RRs, sig, DNSKEY_RR, now); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,183 @@
+package dns
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+//Returns the key with the revoke flag set on its DNSKEY record.
+func revokedKey(key *ZoneKey) *ZoneKey {
+	dnskey := *key.Record.Rdata.(*DNSKEYResource)
+	dnskey.Flags |= DNSKEY_REVOKE_FLAG
+	record := NewResourceRecord(key.Record.Name.Value, key.Record.TTL, CLASS_IN.String(), TYPE_DNSKEY.String(), dnskey.String())
+	return &ZoneKey{Record: *record, PrivateKey: key.PrivateKey}
+}
+
+//Returns the DNSKEY RRset made of the published keys, along with the RRSIG records made over it with each of the signing keys.
+func signedKeySet(t *testing.T, zone string, published []*ZoneKey, signing []*ZoneKey, now time.Time) ([]Resource, []Resource) {
+	DNSKEY_RRs := make([]Resource, 0, len(published))
+	for _, key := range published {
+		DNSKEY_RRs = append(DNSKEY_RRs, key.Record)
+	}
+
+	signer := ZoneSigner{Zone: zone, Keys: signing, Inception: now.Add(-time.Hour), Expiration: now.Add(24 * time.Hour)}
+	signatures := make([]Resource, 0, len(signing))
+	for _, key := range signing {
+		sig, err := signer.signRRSet(zone, DNSKEY_RRs, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		signatures = append(signatures, sig)
+	}
+	return DNSKEY_RRs, signatures
+}
+
+//Returns the state of the anchor held by the file for the key, or an empty string if the file holds none.
+func anchorState(taf *TrustAnchorFile, key *ZoneKey) string {
+	for _, anchor := range taf.List() {
+		if anchorMatchesKey(anchor.Record, key.Record) {
+			return anchor.State.String()
+		}
+	}
+	return ""
+}
+
+func TestTrustAnchorFileUpdate(t *testing.T) {
+	zone := "example."
+	keys := make([]*ZoneKey, 2)
+	for index := range keys {
+		key, err := GenerateZoneKey(zone, ALGORITHM_ECDSAP256SHA256, true, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[index] = key
+	}
+	current, next := keys[0], keys[1]
+	taf := &TrustAnchorFile{Anchors: []TrustAnchor{{Record: current.Record, State: ANCHOR_VALID}}}
+
+	steps := []struct {
+		name string
+		//Time passed since the previous step.
+		elapsed time.Duration
+		published []*ZoneKey
+		signing []*ZoneKey
+		//Key whose anchor is checked, and the state expected for it.
+		key *ZoneKey
+		state string
+		trusted int
+	}{
+		{
+			name: "new key published", published: []*ZoneKey{current, next}, signing: []*ZoneKey{current},
+			key: next, state: "ADDPEND", trusted: 1,
+		},
+		{
+			name: "new key seen before the add hold-down time", elapsed: 10 * 24 * time.Hour, published: []*ZoneKey{current, next}, signing: []*ZoneKey{current},
+			key: next, state: "ADDPEND", trusted: 1,
+		},
+		{
+			name: "new key seen after the add hold-down time", elapsed: 21 * 24 * time.Hour, published: []*ZoneKey{current, next}, signing: []*ZoneKey{current},
+			key: next, state: "VALID", trusted: 2,
+		},
+		{
+			name: "trusted key withdrawn", elapsed: time.Hour, published: []*ZoneKey{current}, signing: []*ZoneKey{current},
+			key: next, state: "MISSING", trusted: 2,
+		},
+		{
+			name: "key revoking itself", elapsed: time.Hour, published: []*ZoneKey{current, revokedKey(next)}, signing: []*ZoneKey{current, revokedKey(next)},
+			key: next, state: "REVOKED", trusted: 1,
+		},
+		{
+			name: "revoked key after the remove hold-down time", elapsed: 31 * 24 * time.Hour, published: []*ZoneKey{current}, signing: []*ZoneKey{current},
+			key: next, state: "", trusted: 1,
+		},
+		{
+			name: "last key revoking itself", elapsed: time.Hour, published: []*ZoneKey{revokedKey(current)}, signing: []*ZoneKey{revokedKey(current)},
+			key: current, state: "REVOKED", trusted: 0,
+		},
+		{
+			name: "last revoked key after the remove hold-down time", elapsed: 31 * 24 * time.Hour, published: []*ZoneKey{revokedKey(current)}, signing: []*ZoneKey{revokedKey(current)},
+			key: current, state: "REVOKED", trusted: 0,
+		},
+	}
+
+	now := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for _, step := range steps {
+		now = now.Add(step.elapsed)
+		DNSKEY_RRs, signatures := signedKeySet(t, zone, step.published, step.signing, now)
+		taf.Update(zone, DNSKEY_RRs, signatures, now)
+		if state := anchorState(taf, step.key); state != step.state {
+			t.Errorf("%s: anchor in the %q state, expected %q", step.name, state, step.state)
+		}
+		if trusted := len(taf.Trusted(zone)); trusted != step.trusted {
+			t.Errorf("%s: %d trusted anchors, expected %d", step.name, trusted, step.trusted)
+		}
+	}
+}
+
+func TestResolverDistrustsZonesWithoutTrustedAnchors(t *testing.T) {
+	testCases := []struct {
+		name string
+		state AnchorState
+		rcode ResponseCode
+		authenticated bool
+	}{
+		{name: "trusted anchor", state: ANCHOR_VALID, rcode: RC_NOERROR, authenticated: true},
+		{name: "anchor pending addition", state: ANCHOR_ADD_PENDING, rcode: RC_SERVFAIL},
+		{name: "revoked anchor", state: ANCHOR_REVOKED, rcode: RC_SERVFAIL},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			hierarchy := newSimulatedHierarchy(t, hierarchyFixtures()...)
+			exampleKey := hierarchy.SignZone(t, "example.com.", time.Now().Add(24 * time.Hour))
+			comKey := hierarchy.SignZone(t, "com.", time.Now().Add(24 * time.Hour), exampleKey)
+			rootKey := hierarchy.SignZone(t, ".", time.Now().Add(24 * time.Hour), comKey)
+
+			resolver := hierarchy.newResolver(t)
+			resolver.SetDNSSECValidation(true)
+			anchorFile := &TrustAnchorFile{LocalFilePath: filepath.Join(t.TempDir(), "root-anchors.conf")}
+			anchorFile.Anchors = []TrustAnchor{{Record: rootKey.DS(), State: testCase.state, LastChange: time.Now()}}
+			resolver.SetTrustAnchorFile(anchorFile)
+
+			response := resolver.Query("www.example.com.", TYPE_A)
+			if response.Header.Rcode != testCase.rcode || response.Header.Authenticated != testCase.authenticated {
+				t.Errorf("response code %s with AD bit %t, expected %s with AD bit %t", response.Header.Rcode.String(), response.Header.Authenticated,
+					testCase.rcode.String(), testCase.authenticated)
+			}
+		})
+	}
+}
+
+func TestTrustAnchorFileFlush(t *testing.T) {
+	key, err := GenerateZoneKey("example.", ALGORITHM_ED25519, true, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed := time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+	anchors := []TrustAnchor{{Record: key.DS(), State: ANCHOR_VALID}, {Record: key.Record, State: ANCHOR_ADD_PENDING, LastChange: changed}}
+
+	taf := &TrustAnchorFile{Anchors: anchors, LocalFilePath: filepath.Join(t.TempDir(), "anchors.conf")}
+	err = taf.Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded := &TrustAnchorFile{LocalFilePath: taf.LocalFilePath}
+	err = loaded.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Anchors) != len(anchors) {
+		t.Fatalf("%d anchors loaded, expected %d", len(loaded.Anchors), len(anchors))
+	}
+	for index := range anchors {
+		if loaded.Anchors[index].String() != anchors[index].String() {
+			t.Errorf("anchor %q loaded, expected %q", loaded.Anchors[index].String(), anchors[index].String())
+		}
+	}
+
+	taf.LocalFilePath = filepath.Join(t.TempDir(), "missing", "anchors.conf")
+	if taf.Flush() == nil {
+		t.Error("trust anchors flushed to a directory that does not exist")
+	}
+}
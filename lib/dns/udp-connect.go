@@ -3,36 +3,46 @@ package dns
 import (
 	"net"
 	"strconv"
+	"time"
 )
 
 //Structure to manage a single UDP connection.
 type UdpConnect struct {
 	Connection *net.UDPConn
+	//Address and port of the remote server the messages are exchanged with.
+	RemoteAddress *net.UDPAddr
 }
 
-//Uses User Datagram Protocol (UDP) to connect to the remote server and port number and return the connection object.
+//Opens a UDP socket on a randomly chosen local port to exchange messages with the remote server and port number.
 func (uc *UdpConnect) ConnectTo(RemoteAddress string, PortNumber int) error {
-	address_string := RemoteAddress + ":" + strconv.Itoa(PortNumber)
+	address_string := net.JoinHostPort(RemoteAddress, strconv.Itoa(PortNumber))
 	udpAddr, err := net.ResolveUDPAddr(MESSAGE_PROTOCOL, address_string)
 	if err != nil {
 		return err
 	}
-	conn, err := net.DialUDP(MESSAGE_PROTOCOL, nil, udpAddr)
-	if err != nil {
-		return err
-	}
 
-	uc.Connection = conn
-	return nil
+	for attempt := 1; ; attempt++ {
+		localAddr := net.UDPAddr{Port: RandomPort()}
+		conn, err := net.ListenUDP(MESSAGE_PROTOCOL, &localAddr)
+		if err == nil {
+			uc.Connection = conn
+			uc.RemoteAddress = udpAddr
+			return nil
+		}
+
+		if attempt == SOURCE_PORT_ATTEMPTS {
+			return err
+		}
+	}
 }
 
-//Sends the given byte stream across the UDP connection.
+//Sends the given byte stream to the remote server.
 func (uc *UdpConnect) Send(buffer []byte) error {
 	if len(buffer) > UDP_MESSAGE_SIZE_LIMIT {
 		return ErrMessageTooLong
 	}
-	
-	_, err := uc.Connection.Write(buffer)
+
+	_, err := uc.Connection.WriteToUDP(buffer, uc.RemoteAddress)
 	if err != nil {
 		return err
 	}
@@ -40,14 +50,23 @@ func (uc *UdpConnect) Send(buffer []byte) error {
 	return nil
 }
 
-//Receives a stream of bytes from the UDP connection.
+//Receives a stream of bytes from the UDP socket. Datagrams that did not come from the address and port of the remote server are returned along with ErrUnexpectedSource.
 func (uc *UdpConnect) Receive() ([]byte, error) {
 	buffer := make([]byte, UDP_MESSAGE_SIZE_LIMIT)
-	byteCount, err := uc.Connection.Read(buffer)
+	byteCount, sourceAddr, err := uc.Connection.ReadFromUDP(buffer)
 	if err != nil {
 		return nil, err
 	}
-	return buffer[:byteCount], nil
+
+	if !sourceAddr.IP.Equal(uc.RemoteAddress.IP) || sourceAddr.Port != uc.RemoteAddress.Port {
+		return buffer[:byteCount:byteCount], ErrUnexpectedSource
+	}
+	return buffer[:byteCount:byteCount], nil
+}
+
+//Sets the time after which pending and future Receive calls fail with a timeout.
+func (uc *UdpConnect) SetDeadline(deadline time.Time) error {
+	return uc.Connection.SetReadDeadline(deadline)
 }
 
 //Close the given UDP connection.
@@ -58,4 +77,4 @@ func (uc *UdpConnect) Close() error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
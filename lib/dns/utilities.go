@@ -1,40 +1,77 @@
 package dns
 
 import (
+	"bufio"
 	"crypto/rand"
 	"encoding/binary"
+	"fmt"
+	"io/fs"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 )
 
-//Returns a new instance of Resolver. In case of any errors, it returns nil instead.
+//Returns a new instance of Resolver that caches RRs in the given BIND file. In case of any errors, it returns nil instead.
 func NewResolver(RootServersPath string, CacheFilePath string, traceLogs bool) (*Resolver, error) {
-	isRootServerAbs := filepath.IsAbs(RootServersPath)
 	isCacheFilePathAbs := filepath.IsAbs(CacheFilePath)
-	if !isRootServerAbs {
+	if !isCacheFilePathAbs {
 		return nil, ErrNotAbsolutePath
 	}
+	cache := BindFile{}
+	err := cache.Initialize(CacheFilePath)
+	if err != nil {
+		return nil, err
+	}
+	return NewResolverWithCache(RootServersPath, &cache, traceLogs)
+}
 
-	if !isCacheFilePathAbs {
+//Returns a new instance of Resolver that caches RRs in the given cache store. In case of any errors, it returns nil instead.
+func NewResolverWithCache(RootServersPath string, cache CacheStore, traceLogs bool) (*Resolver, error) {
+	return NewResolverWithExchanger(RootServersPath, cache, nil, traceLogs)
+}
+
+//Returns a new instance of Resolver that caches RRs in the given cache store and exchanges messages with the name servers through the
+//given exchanger. A nil exchanger stands for the default one, which queries over UDP and falls back to TCP for truncated responses.
+//In case of any errors, it returns nil instead.
+func NewResolverWithExchanger(RootServersPath string, cache CacheStore, exchanger Exchanger, traceLogs bool) (*Resolver, error) {
+	isRootServerAbs := filepath.IsAbs(RootServersPath)
+	if !isRootServerAbs {
 		return nil, ErrNotAbsolutePath
 	}
+
+	if cache == nil {
+		return nil, ErrParametersMissing
+	}
 	resolver := Resolver{}
-	resolver.RootServers = BindFile{}
+	resolver.RootServers = &BindFile{}
 	err := resolver.RootServers.Initialize(RootServersPath)
 	if err != nil {
 		return nil, err
 	}
-	resolver.Cache = BindFile{}
-	err = resolver.Cache.Initialize(CacheFilePath)
-	if err != nil {
-		return nil, err
-	}
+	resolver.Cache = cache
 	resolver.Logger = log.New(os.Stdout, "", log.Ldate | log.Ltime)
 	resolver.traceLogs = traceLogs
+	resolver.Discarded = &DiscardCounters{}
+	resolver.caseInsensitiveServers = &sync.Map{}
+	resolver.pending = &sync.WaitGroup{}
+	resolver.qnameMinimisation = true
+	resolver.Infra = NewInfraCache()
+	resolver.Delegations = NewMemoryStore()
+	resolver.transport = TRANSPORT_IPv4
+	resolver.port = DNS_PORT_NUMBER
+	resolver.serverPorts = &sync.Map{}
+	resolver.exchanger = exchanger
+	if exchanger == nil {
+		resolver.exchanger = DefaultExchanger(resolver.Discarded)
+	}
+	resolver.limits = newQueryLimits()
+	resolver.trustAnchors = rootTrustAnchors()
+	resolver.zoneKeyCache = &sync.Map{}
+	resolver.denialCache = &sync.Map{}
 	resolver.response = nil
 	return &resolver, nil
 }
@@ -46,6 +83,16 @@ func Id() uint16 {
 	return Identifier
 }
 
+//Returns a cryptographically random port number from the unprivileged port range, to be used as the source port of a query.
+func RandomPort() int {
+	for {
+		port := int(Id())
+		if port >= MIN_SOURCE_PORT {
+			return port
+		}
+	}
+}
+
 //Packs a 16-bit unsigned integer into a stream of octets (or bytes) and returns them as an array of byte values.
 func PackUInt32(number uint32) []byte {
 	buffer := make([]byte, 4)
@@ -84,6 +131,30 @@ func NewResourceRecord(dname string, ttl uint32, class string, recType string, d
 	return &resource
 }
 
+//Synthesizes the CNAME record that aliases 'name' into the subtree the given DNAME record points at, as per RFC 6672 - Section 3.3.
+//The owner of the DNAME record is replaced by its target at the end of 'name', and the CNAME record carries the TTL of the DNAME record.
+func SynthesizeCNAME(name string, DNAME_RR Resource) (Resource, error) {
+	prefix := strings.TrimSuffix(Canonicalize(name), DNAME_RR.Name.Value)
+	target := prefix + DNAME_RR.GetData()
+	if DNAME_RR.GetData() == DOMAIN_LABEL_SEPERATOR {
+		target = prefix
+	}
+
+	if len(target) > MAX_DOMAIN_NAME_LENGTH {
+		return Resource{}, fmt.Errorf("%w: %s", ErrNameTooLong, target)
+	}
+	return *NewResourceRecord(name, DNAME_RR.TTL, DNAME_RR.Class.String(), TYPE_CNAME.String(), target), nil
+}
+
+//Returns the DS records of the root zone keys used as the default trust anchors for DNSSEC validation.
+func rootTrustAnchors() []Resource {
+	anchors := make([]Resource, 0, len(RootTrustAnchors))
+	for _, data := range RootTrustAnchors {
+		anchors = append(anchors, *NewResourceRecord(DOMAIN_LABEL_SEPERATOR, 0, CLASS_IN.String(), TYPE_DS.String(), data))
+	}
+	return anchors
+}
+
 //Parses the given string and returns its uint64 equivalent.
 func parseUIntString(value string, bitsize int) uint64 {
 	conv_value, _ := strconv.ParseUint(value, 10, bitsize)
@@ -96,6 +167,23 @@ func getIPAddress(buffer []byte) string {
 	return IP.String()
 }
 
+//Returns the IP addresses held by the given address (A or AAAA) records, skipping records of any other type.
+func getAddresses(resources []Resource) []string {
+	addresses := make([]string, 0, len(resources))
+	for _, rr := range resources {
+		if rr.Type == TYPE_A || rr.Type == TYPE_AAAA {
+			addresses = append(addresses, rr.GetData())
+		}
+	}
+	return addresses
+}
+
+//Returns true if the given IP address string is an IPv6 address.
+func isIPv6(IpAddress string) bool {
+	ip := net.ParseIP(IpAddress)
+	return ip != nil && ip.To4() == nil
+}
+
 //Converts the given IP address string to a stream of bytes.
 func convertToBytes(IpAddress string, IpType string) []byte {
 	emptyResponse := make([]byte, 0)
@@ -127,4 +215,98 @@ func Canonicalize(domainName string) string {
 	domainName = strings.ToLower(domainName)
 	domainName += DOMAIN_LABEL_SEPERATOR
 	return domainName
-}
\ No newline at end of file
+}
+
+//Returns true if 'child' is the same domain name as 'parent' or is a subdomain of it.
+func IsSubDomain(child string, parent string) bool {
+	child = Canonicalize(child)
+	parent = Canonicalize(parent)
+	if parent == DOMAIN_LABEL_SEPERATOR || child == parent {
+		return true
+	}
+	return strings.HasSuffix(child, DOMAIN_LABEL_SEPERATOR + parent)
+}
+
+//Returns the given domain name with the case of each letter flipped at random using a cryptographically random bit.
+func RandomizeCase(domainName string) string {
+	randomBits := make([]byte, len(domainName))
+	rand.Read(randomBits)
+	mixedCase := []byte(domainName)
+	for index, char := range mixedCase {
+		if randomBits[index] & 1 == 0 {
+			continue
+		}
+		if char >= 'a' && char <= 'z' {
+			mixedCase[index] = char - 'a' + 'A'
+		} else if char >= 'A' && char <= 'Z' {
+			mixedCase[index] = char - 'A' + 'a'
+		}
+	}
+	return string(mixedCase)
+}
+
+//Returns the number of labels in the given domain name. The root domain has no labels.
+func CountLabels(domainName string) int {
+	domainName = strings.Trim(domainName, DOMAIN_LABEL_SEPERATOR)
+	if domainName == "" {
+		return 0
+	}
+	return len(strings.Split(domainName, DOMAIN_LABEL_SEPERATOR))
+}
+
+//Returns the domain name made up of the last 'count' labels of the given domain name.
+func LastLabels(domainName string, count int) string {
+	labels := strings.Split(strings.Trim(domainName, DOMAIN_LABEL_SEPERATOR), DOMAIN_LABEL_SEPERATOR)
+	if count >= len(labels) {
+		return Canonicalize(domainName)
+	}
+	if count <= 0 {
+		return DOMAIN_LABEL_SEPERATOR
+	}
+	return Canonicalize(strings.Join(labels[len(labels) - count:], DOMAIN_LABEL_SEPERATOR))
+}
+
+//Replaces the contents of the file at the given path with the data written by 'write', without leaving a partially written file behind.
+//The data is written to a temporary file in the same directory, flushed to the disk and then renamed over the file, so readers either
+//see the old contents or the new ones. The file keeps its permissions, a new file is created readable by everyone.
+func writeFileAtomically(filePath string, write func(*bufio.Writer) error) (err error) {
+	mode := fs.FileMode(0644)
+	info, statErr := os.Stat(filePath)
+	if statErr == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(filePath), "." + filepath.Base(filePath) + ".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tempFile.Close()
+			os.Remove(tempFile.Name())
+		}
+	}()
+
+	writer := bufio.NewWriter(tempFile)
+	err = write(writer)
+	if err != nil {
+		return err
+	}
+	err = writer.Flush()
+	if err != nil {
+		return err
+	}
+	err = tempFile.Chmod(mode)
+	if err != nil {
+		return err
+	}
+	err = tempFile.Sync()
+	if err != nil {
+		return err
+	}
+	err = tempFile.Close()
+	if err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), filePath)
+}
@@ -0,0 +1,340 @@
+package dns
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+//In-Memory representation of a zone in the master file format of RFC 1035 - Section 5.
+type ZoneFile struct {
+	//Origin of the zone, which relative domain names are completed with.
+	Origin string
+	//TTL given to records without one, as set by the $TTL directive (RFC 2308 - Section 4).
+	DefaultTTL uint32
+	//Resource records of the zone, in the order they appear in the file.
+	Records []Resource
+	//Local file path of the zone file.
+	LocalFilePath string
+}
+
+//Initialize the attributes of ZoneFile instance and load the records of the zone file. The origin may be left empty if the file sets
+//it with a $ORIGIN directive or holds absolute domain names only, in which case it is taken from the owner of the SOA record.
+func (zf *ZoneFile) Initialize(filePath string, origin string) error {
+	zf.Records = make([]Resource, 0)
+	zf.LocalFilePath = filePath
+	zf.Origin = ""
+	if origin != "" {
+		zf.Origin = Canonicalize(origin)
+	}
+	return zf.Load()
+}
+
+//Load the records of the zone file into memory.
+func (zf *ZoneFile) Load() error {
+	fileHandler, err := os.Open(zf.LocalFilePath)
+	if err != nil {
+		return err
+	}
+	defer fileHandler.Close()
+	return zf.Parse(fileHandler)
+}
+
+//Parses the zone in master file format read from the reader. Comments, entries spanning several lines within parentheses, blank owner
+//names, the '@' shorthand for the origin, relative domain names, TTLs with units and the $ORIGIN and $TTL directives are supported.
+func (zf *ZoneFile) Parse(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64 * 1024), 1024 * 1024)
+	lineNumber, owner, lastTTL, hasTTL := 0, "", uint32(0), false
+	for {
+		entry, startLine, err := readZoneEntry(scanner, &lineNumber)
+		if err != nil {
+			return err
+		} else if entry == nil {
+			break
+		}
+
+		tokens := entry.tokens
+		if len(tokens) == 0 {
+			continue
+		}
+
+		if strings.EqualFold(tokens[0], "$ORIGIN") {
+			if len(tokens) < 2 {
+				return fmt.Errorf("%w: line %d: $ORIGIN needs a domain name", ErrZoneSyntax, startLine)
+			}
+			zf.Origin, err = zf.qualify(tokens[1])
+			if err != nil {
+				return fmt.Errorf("%w: line %d", err, startLine)
+			}
+			continue
+		} else if strings.EqualFold(tokens[0], "$TTL") {
+			if len(tokens) < 2 {
+				return fmt.Errorf("%w: line %d: $TTL needs a TTL value", ErrZoneSyntax, startLine)
+			}
+			ttl, ok := parseTTL(tokens[1])
+			if !ok {
+				return fmt.Errorf("%w: line %d: invalid TTL %s", ErrZoneSyntax, startLine, tokens[1])
+			}
+			zf.DefaultTTL, lastTTL, hasTTL = ttl, ttl, true
+			continue
+		} else if strings.HasPrefix(tokens[0], "$") {
+			return fmt.Errorf("%w: line %d: directive %s is not supported", ErrZoneSyntax, startLine, tokens[0])
+		}
+
+		if !entry.blankOwner {
+			owner, err = zf.qualify(tokens[0])
+			if err != nil {
+				return fmt.Errorf("%w: line %d", err, startLine)
+			}
+			tokens = tokens[1:]
+		} else if owner == "" {
+			return fmt.Errorf("%w: line %d: record has no owner name", ErrZoneSyntax, startLine)
+		}
+
+		classString := CLASS_IN.String()
+		ttl, explicitTTL := lastTTL, false
+		for range 2 {
+			if len(tokens) == 0 {
+				break
+			}
+			if value, ok := parseTTL(tokens[0]); ok {
+				ttl, explicitTTL = value, true
+			} else if _, ok := AllowedClassTypes[strings.ToUpper(tokens[0])]; ok {
+				classString = strings.ToUpper(tokens[0])
+			} else {
+				break
+			}
+			tokens = tokens[1:]
+		}
+
+		if len(tokens) == 0 {
+			return fmt.Errorf("%w: line %d: record has no type", ErrZoneSyntax, startLine)
+		}
+		typeString := strings.ToUpper(tokens[0])
+		recType, ok := AllowedRRTypes[typeString]
+		if !ok {
+			return fmt.Errorf("%w: line %d: %s", ErrInvalidRecordType, startLine, tokens[0])
+		}
+
+		if explicitTTL {
+			lastTTL, hasTTL = ttl, true
+		} else if !hasTTL {
+			return fmt.Errorf("%w: line %d: record has no TTL and no $TTL directive precedes it", ErrZoneSyntax, startLine)
+		}
+
+		data, err := zf.qualifyData(recType, tokens[1:])
+		if err != nil {
+			return fmt.Errorf("%w: line %d", err, startLine)
+		}
+		zf.Records = append(zf.Records, *NewResourceRecord(owner, ttl, classString, typeString, data))
+	}
+
+	if zf.Origin == "" {
+		for _, rr := range zf.Records {
+			if rr.Type == TYPE_SOA {
+				zf.Origin = rr.Name.Value
+				break
+			}
+		}
+	}
+	return nil
+}
+
+//Writes the records of the zone to the writer in master file format, one record per line with absolute domain names.
+func (zf *ZoneFile) Write(writer io.Writer) error {
+	buffered := bufio.NewWriter(writer)
+	if zf.Origin != "" {
+		buffered.WriteString(fmt.Sprintf("$ORIGIN %s%s", zf.Origin, NEWLINE_SEPERATOR))
+	}
+	for _, rr := range zf.Records {
+		buffered.WriteString(rr.CacheString() + NEWLINE_SEPERATOR)
+	}
+	return buffered.Flush()
+}
+
+//Persists the records of the zone to the given file path in master file format.
+func (zf *ZoneFile) Save(filePath string) error {
+	fileHandler, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer fileHandler.Close()
+	return zf.Write(fileHandler)
+}
+
+//Returns the absolute form of a domain name found in the zone file, completing relative names with the origin.
+func (zf *ZoneFile) qualify(name string) (string, error) {
+	if name == "@" {
+		name = zf.Origin
+	} else if !strings.HasSuffix(name, DOMAIN_LABEL_SEPERATOR) {
+		if zf.Origin == "" {
+			return "", fmt.Errorf("%w: relative domain name %s found before the origin is known", ErrZoneSyntax, name)
+		}
+		name = name + DOMAIN_LABEL_SEPERATOR + strings.TrimPrefix(zf.Origin, DOMAIN_LABEL_SEPERATOR)
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("%w: '@' used before the origin is known", ErrZoneSyntax)
+	}
+	return Canonicalize(name), nil
+}
+
+//Returns the record data in presentation format, with the domain names it holds made absolute and the SOA timers given in seconds.
+func (zf *ZoneFile) qualifyData(recType RecordType, fields []string) (string, error) {
+	nameFields := make([]int, 0)
+	if recType == TYPE_NS || recType == TYPE_CNAME || recType == TYPE_DNAME || recType == TYPE_NSEC {
+		nameFields = append(nameFields, 0)
+	} else if recType == TYPE_SOA {
+		nameFields = append(nameFields, 0, 1)
+		for index := 2; index < len(fields); index++ {
+			if value, ok := parseTTL(fields[index]); ok {
+				fields[index] = strconv.FormatUint(uint64(value), 10)
+			}
+		}
+	} else if recType == TYPE_SVCB || recType == TYPE_HTTPS {
+		nameFields = append(nameFields, 1)
+	} else if recType == TYPE_RRSIG {
+		nameFields = append(nameFields, 7)
+	}
+
+	for _, index := range nameFields {
+		if index >= len(fields) {
+			return "", fmt.Errorf("%w: record data is incomplete", ErrZoneSyntax)
+		}
+		if fields[index] == DOMAIN_LABEL_SEPERATOR {
+			continue
+		}
+		name, err := zf.qualify(fields[index])
+		if err != nil {
+			return "", err
+		}
+		fields[index] = name
+	}
+	return strings.Join(fields, WHITESPACE), nil
+}
+
+//A single entry of a zone file, which may span several lines within parentheses.
+type zoneEntry struct {
+	//Fields of the entry, with comments and parentheses removed. Quoted strings are kept as one field along with their quotes.
+	tokens []string
+	//True if the entry starts with a blank, in which case it belongs to the owner name of the previous record.
+	blankOwner bool
+}
+
+//Reads the next entry from the zone file, counting the lines read. Returns a nil entry at the end of the file.
+func readZoneEntry(scanner *bufio.Scanner, lineNumber *int) (*zoneEntry, int, error) {
+	var entry *zoneEntry
+	startLine, depth := 0, 0
+	for scanner.Scan() {
+		*lineNumber++
+		line := scanner.Text()
+		if entry == nil {
+			entry = &zoneEntry{tokens: make([]string, 0)}
+			entry.blankOwner = len(line) > 0 && unicode.IsSpace(rune(line[0]))
+			startLine = *lineNumber
+		}
+
+		tokens, change, err := tokenizeZoneLine(line)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: line %d", err, *lineNumber)
+		}
+		entry.tokens = append(entry.tokens, tokens...)
+		depth += change
+		if depth < 0 {
+			return nil, 0, fmt.Errorf("%w: line %d: unbalanced parentheses", ErrZoneSyntax, *lineNumber)
+		} else if depth == 0 {
+			return entry, startLine, nil
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, 0, err
+	} else if depth > 0 {
+		return nil, 0, fmt.Errorf("%w: line %d: unbalanced parentheses", ErrZoneSyntax, startLine)
+	}
+	return entry, startLine, nil
+}
+
+//Splits a line of a zone file into its fields, dropping the comment that ends it. Returns the fields and the number of parentheses
+//opened less the number closed on the line.
+func tokenizeZoneLine(line string) ([]string, int, error) {
+	tokens := make([]string, 0)
+	current := strings.Builder{}
+	quoted, escaped, depth := false, false, 0
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, char := range line {
+		if escaped {
+			current.WriteRune(char)
+			escaped = false
+		} else if char == '\\' {
+			current.WriteRune(char)
+			escaped = true
+		} else if char == '"' {
+			current.WriteRune(char)
+			quoted = !quoted
+		} else if quoted {
+			current.WriteRune(char)
+		} else if char == ';' {
+			break
+		} else if char == '(' || char == ')' {
+			flush()
+			if char == '(' {
+				depth++
+			} else {
+				depth--
+			}
+		} else if unicode.IsSpace(char) {
+			flush()
+		} else {
+			current.WriteRune(char)
+		}
+	}
+
+	if quoted {
+		return nil, 0, fmt.Errorf("%w: unterminated quoted string", ErrZoneSyntax)
+	}
+	flush()
+	return tokens, depth, nil
+}
+
+//Parses a TTL value, given in seconds or as a sequence of numbers with the units s, m, h, d or w (such as "1h30m").
+//Returns false if the value is not a TTL.
+func parseTTL(value string) (uint32, bool) {
+	if value == "" {
+		return 0, false
+	} else if number, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return uint32(number), true
+	}
+
+	units := map[byte]uint64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+	total, number, digits := uint64(0), uint64(0), 0
+	for index := 0; index < len(value); index++ {
+		char := value[index] | 0x20
+		if value[index] >= '0' && value[index] <= '9' {
+			number = number * 10 + uint64(value[index] - '0')
+			digits++
+		} else if multiplier, ok := units[char]; ok && digits > 0 {
+			total += number * multiplier
+			number, digits = 0, 0
+		} else {
+			return 0, false
+		}
+	}
+
+	if digits > 0 || total > uint64(^uint32(0)) {
+		return 0, false
+	}
+	return uint32(total), true
+}
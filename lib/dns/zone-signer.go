@@ -0,0 +1,580 @@
+package dns
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+//A key used to sign a zone, made of its DNSKEY record and the matching private key.
+type ZoneKey struct {
+	//DNSKEY record of the key, owned by the apex of the zone.
+	Record Resource
+	//Private key, which is an *ecdsa.PrivateKey for ECDSA P-256 keys and an ed25519.PrivateKey for Ed25519 keys.
+	PrivateKey crypto.Signer
+}
+
+//Generates a new key for the given zone with the given algorithm, which must be ECDSA P-256 (13) or Ed25519 (15). Key signing keys
+//have the secure entry point flag set, and the DNSKEY record is given the TTL passed in.
+func GenerateZoneKey(zone string, algorithm uint8, keySigning bool, ttl uint32) (*ZoneKey, error) {
+	dnskey := DNSKEYResource{Flags: DNSKEY_ZONE_KEY_FLAG, Protocol: DNSKEY_PROTOCOL, Algorithm: algorithm}
+	if keySigning {
+		dnskey.Flags |= DNSKEY_SEP_FLAG
+	}
+
+	var privateKey crypto.Signer
+	if algorithm == ALGORITHM_ECDSAP256SHA256 {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		ecdhKey, err := key.ECDH()
+		if err != nil {
+			return nil, err
+		}
+		dnskey.PublicKey = ecdhKey.PublicKey().Bytes()[1:]
+		privateKey = key
+	} else if algorithm == ALGORITHM_ED25519 {
+		publicKey, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		dnskey.PublicKey = publicKey
+		privateKey = key
+	} else {
+		return nil, fmt.Errorf("%w: algorithm %d cannot be used for signing", ErrUnsupportedAlgorithm, algorithm)
+	}
+
+	record := NewResourceRecord(zone, ttl, CLASS_IN.String(), TYPE_DNSKEY.String(), dnskey.String())
+	return &ZoneKey{Record: *record, PrivateKey: privateKey}, nil
+}
+
+//Loads the key stored in the given public key file (".key") and the private key file next to it (".private"), in the format
+//used by BIND's dnssec-keygen.
+func LoadZoneKey(keyFilePath string) (*ZoneKey, error) {
+	keyFile := ZoneFile{}
+	err := keyFile.Initialize(keyFilePath, DOMAIN_LABEL_SEPERATOR)
+	if err != nil {
+		return nil, err
+	} else if len(keyFile.Records) != 1 || keyFile.Records[0].Type != TYPE_DNSKEY {
+		return nil, fmt.Errorf("%w: %s must hold a single DNSKEY record", ErrInvalidPrivateKey, keyFilePath)
+	}
+
+	key := ZoneKey{Record: keyFile.Records[0]}
+	dnskey := key.Record.Rdata.(*DNSKEYResource)
+	fileHandler, err := os.Open(strings.TrimSuffix(keyFilePath, ".key") + ".private")
+	if err != nil {
+		return nil, err
+	}
+	defer fileHandler.Close()
+
+	var secret []byte
+	scanner := bufio.NewScanner(fileHandler)
+	for scanner.Scan() {
+		field, value, _ := strings.Cut(scanner.Text(), ":")
+		if strings.TrimSpace(field) == "PrivateKey" {
+			secret, err = base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidPrivateKey, err.Error())
+			}
+		}
+	}
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	if dnskey.Algorithm == ALGORITHM_ECDSAP256SHA256 {
+		ecdhKey, err := ecdh.P256().NewPrivateKey(secret)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPrivateKey, err.Error())
+		}
+		point := ecdhKey.PublicKey().Bytes()[1:]
+		publicKey := ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(point[:32]), Y: new(big.Int).SetBytes(point[32:])}
+		key.PrivateKey = &ecdsa.PrivateKey{PublicKey: publicKey, D: new(big.Int).SetBytes(secret)}
+		if !bytes.Equal(point, dnskey.PublicKey) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPrivateKey, keyFilePath)
+		}
+	} else if dnskey.Algorithm == ALGORITHM_ED25519 {
+		if len(secret) != ed25519.SeedSize {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPrivateKey, keyFilePath)
+		}
+		privateKey := ed25519.NewKeyFromSeed(secret)
+		key.PrivateKey = privateKey
+		if !bytes.Equal(privateKey.Public().(ed25519.PublicKey), dnskey.PublicKey) {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidPrivateKey, keyFilePath)
+		}
+	} else {
+		return nil, fmt.Errorf("%w: algorithm %d cannot be used for signing", ErrUnsupportedAlgorithm, dnskey.Algorithm)
+	}
+	return &key, nil
+}
+
+//Loads every key of the zone stored in the directory, as found by the "K<zone>+<algorithm>+<key tag>.key" file names.
+func LoadZoneKeys(directory string, zone string) ([]*ZoneKey, error) {
+	pattern := filepath.Join(directory, fmt.Sprintf("K%s+*.key", keyFileZone(zone)))
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*ZoneKey, 0, len(paths))
+	for _, path := range paths {
+		key, err := LoadZoneKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+//Returns true if the key is a key signing key, which signs the DNSKEY RRset of the zone.
+func (key *ZoneKey) IsKeySigningKey() bool {
+	return key.Record.Rdata.(*DNSKEYResource).IsSecureEntryPoint()
+}
+
+//Returns the key tag of the key.
+func (key *ZoneKey) KeyTag() uint16 {
+	return key.Record.Rdata.(*DNSKEYResource).KeyTag()
+}
+
+//Returns the name of the files holding the key, without extension - "K<zone>+<algorithm>+<key tag>".
+func (key *ZoneKey) FileName() string {
+	return fmt.Sprintf("K%s+%03d+%05d", keyFileZone(key.Record.Name.Value), key.Record.Rdata.(*DNSKEYResource).Algorithm, key.KeyTag())
+}
+
+//Returns the DS record pointing at the key, to be published by the parent zone.
+func (key *ZoneKey) DS() Resource {
+	record, _ := trustAnchorDS(key.Record)
+	return record
+}
+
+//Saves the key in the directory as a public key file (".key") holding the DNSKEY record, and a private key file (".private")
+//readable by the owner only.
+func (key *ZoneKey) Save(directory string) error {
+	role := "zone-signing"
+	if key.IsKeySigningKey() {
+		role = "key-signing"
+	}
+	public := fmt.Sprintf("; This is a %s key, keyid %d, for %s%s%s%s", role, key.KeyTag(), key.Record.Name.Value, NEWLINE_SEPERATOR,
+		key.Record.CacheString(), NEWLINE_SEPERATOR)
+
+	algorithm := key.Record.Rdata.(*DNSKEYResource).Algorithm
+	secret, algorithmName := make([]byte, 0), ""
+	if privateKey, ok := key.PrivateKey.(*ecdsa.PrivateKey); ok {
+		ecdhKey, err := privateKey.ECDH()
+		if err != nil {
+			return err
+		}
+		secret, algorithmName = ecdhKey.Bytes(), "ECDSAP256SHA256"
+	} else if privateKey, ok := key.PrivateKey.(ed25519.PrivateKey); ok {
+		secret, algorithmName = privateKey.Seed(), "ED25519"
+	}
+	private := fmt.Sprintf("Private-key-format: v1.3%sAlgorithm: %d (%s)%sPrivateKey: %s%s", NEWLINE_SEPERATOR, algorithm, algorithmName,
+		NEWLINE_SEPERATOR, base64.StdEncoding.EncodeToString(secret), NEWLINE_SEPERATOR)
+
+	path := filepath.Join(directory, key.FileName())
+	err := os.WriteFile(path + ".key", []byte(public), 0644)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path + ".private", []byte(private), 0600)
+}
+
+//Signs the data with the private key, returning the signature in the format of the DNSKEY algorithm.
+func (key *ZoneKey) sign(data []byte) ([]byte, error) {
+	if privateKey, ok := key.PrivateKey.(*ecdsa.PrivateKey); ok {
+		digest := sha256.Sum256(data)
+		r, s, err := ecdsa.Sign(rand.Reader, privateKey, digest[:])
+		if err != nil {
+			return nil, err
+		}
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+		return signature, nil
+	} else if privateKey, ok := key.PrivateKey.(ed25519.PrivateKey); ok {
+		return ed25519.Sign(privateKey, data), nil
+	}
+	return nil, ErrInvalidPrivateKey
+}
+
+//Returns the zone name as it appears in key file names, which is without the trailing dot except for the root zone.
+func keyFileZone(zone string) string {
+	zone = Canonicalize(zone)
+	if zone == DOMAIN_LABEL_SEPERATOR {
+		return zone
+	}
+	return strings.TrimSuffix(zone, DOMAIN_LABEL_SEPERATOR)
+}
+
+//Signs the records of a zone: adds the DNSKEY records of the keys, builds the NSEC or NSEC3 chain proving which names and types
+//exist, and signs every authoritative RRset.
+type ZoneSigner struct {
+	//Apex of the zone being signed.
+	Zone string
+	//Keys signing the zone. Key signing keys sign the DNSKEY RRset and zone signing keys sign the rest of the zone. If only one
+	//kind of key is given, those keys sign everything.
+	Keys []*ZoneKey
+	//Time from which the signatures are valid.
+	Inception time.Time
+	//Time after which the signatures are no longer valid.
+	Expiration time.Time
+	//Parameters of the NSEC3 chain (hash algorithm, iterations, salt and the opt-out flag). The zone is signed with NSEC records if nil.
+	NSEC3 *NSEC3Resource
+}
+
+//Returns the signed zone, which holds the given records with the DNSKEY, RRSIG and NSEC or NSEC3 records added, along with the
+//NSEC3PARAM record at the apex of a zone signed with NSEC3 records, sorted in the canonical order of their owner names. Any DNSKEY,
+//NSEC3PARAM, RRSIG, NSEC or NSEC3 records already in the zone are replaced. Records below
+//delegations (glue) are kept but neither signed nor covered by the chain, and the NS records of delegations are not signed.
+func (signer *ZoneSigner) Sign(records []Resource) ([]Resource, error) {
+	zone := Canonicalize(signer.Zone)
+	if len(signer.Keys) == 0 {
+		return nil, ErrNoSigningKeys
+	}
+
+	rrsets := make(map[string]map[RecordType][]Resource)
+	addRecord := func(rr Resource) {
+		owner := Canonicalize(rr.Name.Value)
+		if rrsets[owner] == nil {
+			rrsets[owner] = make(map[RecordType][]Resource)
+		}
+		rrsets[owner][rr.Type] = append(rrsets[owner][rr.Type], rr)
+	}
+
+	for _, rr := range records {
+		if !IsSubDomain(rr.Name.Value, zone) {
+			return nil, fmt.Errorf("%w: %s is not within %s", ErrOutOfZone, rr.Name.Value, zone)
+		} else if rr.Type == TYPE_RRSIG || rr.Type == TYPE_NSEC || rr.Type == TYPE_NSEC3 || ((rr.Type == TYPE_DNSKEY || rr.Type == TYPE_NSEC3PARAM) && strings.EqualFold(rr.Name.Value, zone)) {
+			continue
+		}
+		addRecord(rr)
+	}
+	for _, key := range signer.Keys {
+		if !strings.EqualFold(key.Record.Name.Value, zone) {
+			return nil, fmt.Errorf("%w: key %d belongs to %s", ErrOutOfZone, key.KeyTag(), key.Record.Name.Value)
+		}
+		addRecord(key.Record)
+	}
+
+	SOA_RRs := rrsets[zone][TYPE_SOA]
+	if len(SOA_RRs) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNoSOA, zone)
+	}
+	negativeTTL := min(SOA_RRs[0].TTL, SOA_RRs[0].Rdata.(*SOAResource).Minimum)
+	if signer.NSEC3 != nil {
+		//The NSEC3PARAM record tells the authoritative servers how the names of the zone are hashed (RFC 5155 - Section 4).
+		params := NSEC3PARAMResource{HashAlgorithm: NSEC3_HASH_SHA1, Flags: 0, Iterations: signer.NSEC3.Iterations, Salt: signer.NSEC3.Salt}
+		addRecord(*NewResourceRecord(zone, negativeTTL, CLASS_IN.String(), TYPE_NSEC3PARAM.String(), params.String()))
+	}
+
+	owners := make([]string, 0, len(rrsets))
+	for owner := range rrsets {
+		owners = append(owners, owner)
+	}
+	slices.SortFunc(owners, CompareNames)
+
+	//Names below a delegation are glue, and only the NS and DS records of a delegation belong to the zone.
+	delegations := make([]string, 0)
+	authoritative := make([]string, 0, len(owners))
+	for _, owner := range owners {
+		if slices.ContainsFunc(delegations, func(cut string) bool { return IsSubDomain(owner, cut) }) {
+			continue
+		}
+		authoritative = append(authoritative, owner)
+		if owner != zone && len(rrsets[owner][TYPE_NS]) > 0 {
+			delegations = append(delegations, owner)
+		}
+	}
+
+	var chain []Resource
+	if signer.NSEC3 == nil {
+		chain = signer.nsecChain(zone, authoritative, delegations, rrsets, negativeTTL)
+	} else {
+		chain = signer.nsec3Chain(zone, authoritative, delegations, rrsets, negativeTTL)
+	}
+
+	signed := make([]Resource, 0, len(records) * 2)
+	addSigned := func(RRs []Resource) error {
+		ttl := RRs[0].TTL
+		for _, rr := range RRs {
+			ttl = min(ttl, rr.TTL)
+		}
+		for index := range RRs {
+			RRs[index].TTL = ttl
+		}
+		signed = append(signed, RRs...)
+		for _, key := range signer.signingKeys(RRs[0].Type == TYPE_DNSKEY) {
+			RRSIG_RR, err := signer.signRRSet(zone, RRs, key)
+			if err != nil {
+				return err
+			}
+			signed = append(signed, RRSIG_RR)
+		}
+		return nil
+	}
+
+	chainIndex := 0
+	for _, owner := range owners {
+		isAuthoritative := slices.Contains(authoritative, owner)
+		isDelegation := slices.Contains(delegations, owner)
+		types := make([]RecordType, 0, len(rrsets[owner]))
+		for recType := range rrsets[owner] {
+			types = append(types, recType)
+		}
+		slices.Sort(types)
+
+		for _, recType := range types {
+			RRs := rrsets[owner][recType]
+			if !isAuthoritative || (isDelegation && recType != TYPE_DS) {
+				signed = append(signed, RRs...)
+				continue
+			}
+			err := addSigned(RRs)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		//The NSEC records are owned by the names of the zone, and are written next to them.
+		for signer.NSEC3 == nil && chainIndex < len(chain) && chain[chainIndex].Name.Value == owner {
+			err := addSigned([]Resource{chain[chainIndex]})
+			if err != nil {
+				return nil, err
+			}
+			chainIndex++
+		}
+	}
+
+	for ; chainIndex < len(chain); chainIndex++ {
+		err := addSigned([]Resource{chain[chainIndex]})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return signed, nil
+}
+
+//Returns the NSEC chain of the zone, which links its authoritative names in canonical order and lists the types at each of them.
+func (signer *ZoneSigner) nsecChain(zone string, names []string, delegations []string, rrsets map[string]map[RecordType][]Resource, ttl uint32) []Resource {
+	chain := make([]Resource, 0, len(names))
+	for index, owner := range names {
+		types := []RecordType{TYPE_NSEC, TYPE_RRSIG}
+		for recType := range rrsets[owner] {
+			if !slices.Contains(delegations, owner) || recType == TYPE_NS || recType == TYPE_DS {
+				types = append(types, recType)
+			}
+		}
+		slices.Sort(types)
+
+		nsec := NSECResource{NextDomain: DomainName{}, Types: types}
+		nsec.NextDomain.Initialize(names[(index + 1) % len(names)])
+		chain = append(chain, *NewResourceRecord(owner, ttl, CLASS_IN.String(), TYPE_NSEC.String(), nsec.String()))
+	}
+	return chain
+}
+
+//Returns the NSEC3 chain of the zone, which links the hashes of its authoritative names and of the empty non-terminals above them
+//in hash order and lists the types at each of them. With the opt-out flag set, delegations without DS records are left out of the
+//chain, along with the empty non-terminals that only lead to them, and every NSEC3 record is flagged as possibly covering unsigned
+//delegations (RFC 5155 - Section 6).
+func (signer *ZoneSigner) nsec3Chain(zone string, names []string, delegations []string, rrsets map[string]map[RecordType][]Resource, ttl uint32) []Resource {
+	params := signer.NSEC3
+	flags := params.Flags & NSEC3_OPT_OUT_FLAG
+	typesOf := make(map[string][]RecordType)
+	for _, owner := range names {
+		types := make([]RecordType, 0)
+		isDelegation := slices.Contains(delegations, owner)
+		if flags != 0 && isDelegation && len(rrsets[owner][TYPE_DS]) == 0 {
+			continue
+		}
+		for recType := range rrsets[owner] {
+			if !isDelegation || recType == TYPE_NS || recType == TYPE_DS {
+				types = append(types, recType)
+			}
+		}
+		if !isDelegation || len(rrsets[owner][TYPE_DS]) > 0 {
+			types = append(types, TYPE_RRSIG)
+		}
+		slices.Sort(types)
+		typesOf[owner] = types
+
+		for parent := owner; parent != zone; {
+			parent = parentOf(parent)
+			if _, ok := typesOf[parent]; !ok && parent != zone {
+				typesOf[parent] = make([]RecordType, 0)
+			}
+		}
+	}
+
+	hashes := make([][]byte, 0, len(typesOf))
+	hashedTypes := make(map[string][]RecordType)
+	for owner, types := range typesOf {
+		hash := HashNSEC3Name(owner, params.Iterations, params.Salt)
+		hashes = append(hashes, hash)
+		hashedTypes[string(hash)] = types
+	}
+	slices.SortFunc(hashes, bytes.Compare)
+
+	encoding := base32.HexEncoding.WithPadding(base32.NoPadding)
+	chain := make([]Resource, 0, len(hashes))
+	for index, hash := range hashes {
+		nsec3 := NSEC3Resource{HashAlgorithm: NSEC3_HASH_SHA1, Flags: flags, Iterations: params.Iterations, Salt: params.Salt,
+			NextHashedOwner: hashes[(index + 1) % len(hashes)], Types: hashedTypes[string(hash)]}
+		owner := strings.ToLower(encoding.EncodeToString(hash)) + DOMAIN_LABEL_SEPERATOR + zone
+		chain = append(chain, *NewResourceRecord(owner, ttl, CLASS_IN.String(), TYPE_NSEC3.String(), nsec3.String()))
+	}
+	return chain
+}
+
+//Returns the keys that sign the DNSKEY RRset, or the rest of the zone.
+func (signer *ZoneSigner) signingKeys(DNSKEY bool) []*ZoneKey {
+	keys := make([]*ZoneKey, 0, len(signer.Keys))
+	for _, key := range signer.Keys {
+		if key.IsKeySigningKey() == DNSKEY {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return signer.Keys
+	}
+	return keys
+}
+
+//Returns the RRSIG record made with the key over the RRset, as per RFC 4034 - Section 3.1.8.1.
+func (signer *ZoneSigner) signRRSet(zone string, RRs []Resource, key *ZoneKey) (Resource, error) {
+	owner := RRs[0].Name.Value
+	labels := CountLabels(owner)
+	if strings.HasPrefix(owner, WILDCARD_LABEL + DOMAIN_LABEL_SEPERATOR) {
+		labels--
+	}
+
+	rrsig := RRSIGResource{TypeCovered: RRs[0].Type, Algorithm: key.Record.Rdata.(*DNSKEYResource).Algorithm, Labels: uint8(labels),
+		OriginalTTL: RRs[0].TTL, Expiration: uint32(signer.Expiration.Unix()), Inception: uint32(signer.Inception.Unix()), KeyTag: key.KeyTag()}
+	rrsig.SignerName = DomainName{}
+	rrsig.SignerName.Initialize(zone)
+	signature, err := key.sign(append(rrsig.PackHeader(), CanonicalRRSet(RRs, RRs[0].TTL)...))
+	if err != nil {
+		return Resource{}, err
+	}
+	rrsig.Signature = signature
+	return *NewResourceRecord(owner, RRs[0].TTL, RRs[0].Class.String(), TYPE_RRSIG.String(), rrsig.String()), nil
+}
+
+//Checks the signed zone the way the resolver validates answers from it: the DNSKEY RRset must be signed by one of its secure entry
+//point keys, every authoritative RRset must carry a signature that verifies with a key of the zone at the given time, an NSEC3 chain
+//must be announced by an NSEC3PARAM record at the apex, and the NSEC or NSEC3 chain must securely deny a name and a type that do not
+//exist in the zone. An opt-out NSEC3 chain cannot prove that a name does not exist securely, so an insecure proof is enough for it.
+func VerifySignedZone(zone string, records []Resource, now time.Time) error {
+	zone = Canonicalize(zone)
+	rrsets := make(map[string]map[RecordType][]Resource)
+	signatures := make(map[string]map[RecordType][]Resource)
+	chain := make([]Resource, 0)
+	for _, rr := range records {
+		owner := Canonicalize(rr.Name.Value)
+		target, recType := rrsets, rr.Type
+		if RRSIG, ok := rr.Rdata.(*RRSIGResource); ok {
+			target, recType = signatures, RRSIG.TypeCovered
+		} else if rr.Type == TYPE_NSEC || rr.Type == TYPE_NSEC3 {
+			chain = append(chain, rr)
+		}
+		if target[owner] == nil {
+			target[owner] = make(map[RecordType][]Resource)
+		}
+		target[owner][recType] = append(target[owner][recType], rr)
+	}
+
+	DNSKEY_RRs := rrsets[zone][TYPE_DNSKEY]
+	if len(DNSKEY_RRs) == 0 {
+		return fmt.Errorf("%w: %s has no DNSKEY records", ErrBogus, zone)
+	}
+	entryPoints := make([]Resource, 0)
+	for _, rr := range DNSKEY_RRs {
+		if rr.Rdata.(*DNSKEYResource).IsSecureEntryPoint() {
+			entryPoints = append(entryPoints, rr)
+		}
+	}
+	if len(entryPoints) == 0 {
+		entryPoints = DNSKEY_RRs
+	}
+
+	for owner, types := range rrsets {
+		delegation := owner != zone && len(types[TYPE_NS]) > 0
+		glue := false
+		for cut := owner; cut != zone && !glue; {
+			cut = parentOf(cut)
+			glue = cut != zone && len(rrsets[cut][TYPE_NS]) > 0
+		}
+
+		for recType, RRs := range types {
+			if glue || (delegation && recType != TYPE_DS) {
+				continue
+			}
+
+			keys := DNSKEY_RRs
+			if recType == TYPE_DNSKEY && owner == zone {
+				keys = entryPoints
+			}
+			if !verifiesWithAny(RRs, signatures[owner][recType], keys, now) {
+				return fmt.Errorf("%w: %s type records of %s have no valid signature", ErrBogus, recType.String(), owner)
+			}
+		}
+	}
+
+	optOut := false
+	for _, rr := range chain {
+		nsec3, ok := rr.Rdata.(*NSEC3Resource)
+		optOut = optOut || (ok && nsec3.IsOptOut())
+		if ok && !slices.ContainsFunc(rrsets[zone][TYPE_NSEC3PARAM], func(param Resource) bool { return param.Rdata.(*NSEC3PARAMResource).Matches(nsec3) }) {
+			return fmt.Errorf("%w: %s has no NSEC3PARAM record matching its NSEC3 records", ErrBogus, zone)
+		}
+	}
+
+	denials := map[string]RecordType{zone: TYPE_CAA}
+	if len(rrsets[WILDCARD_LABEL + DOMAIN_LABEL_SEPERATOR + zone]) == 0 {
+		missingName := "nonexistent" + DOMAIN_LABEL_SEPERATOR + zone
+		for len(rrsets[missingName]) > 0 {
+			missingName = "nonexistent-" + missingName
+		}
+		denials[missingName] = TYPE_A
+	}
+	for name, recType := range denials {
+		if len(rrsets[name][recType]) > 0 {
+			continue
+		}
+		proof, ok := proveDenial(name, recType, chain)
+		if !ok || (proof.status != SECURITY_SECURE && !(optOut && proof.status == SECURITY_INSECURE)) {
+			return fmt.Errorf("%w: NSEC records of %s do not deny %s type records of %s", ErrBogus, zone, recType.String(), name)
+		}
+	}
+	return nil
+}
+
+//Returns true if one of the signatures over the RRset verifies with one of the keys.
+func verifiesWithAny(RRs []Resource, signatures []Resource, keys []Resource, now time.Time) bool {
+	for _, sig := range signatures {
+		for _, key := range keys {
+			if _, err := verifyRRSIG(RRs, sig, key, now); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package dns
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestZoneSignerSignsVerifiableZones(t *testing.T) {
+	testCases := []struct {
+		name string
+		algorithm uint8
+		//Parameters of the NSEC3 chain, or nil for an NSEC chain.
+		NSEC3 *NSEC3Resource
+	}{
+		{name: "NSEC chain with ECDSA P-256", algorithm: ALGORITHM_ECDSAP256SHA256},
+		{name: "NSEC chain with Ed25519", algorithm: ALGORITHM_ED25519},
+		{name: "NSEC3 chain with ECDSA P-256", algorithm: ALGORITHM_ECDSAP256SHA256, NSEC3: &NSEC3Resource{HashAlgorithm: NSEC3_HASH_SHA1, Salt: []byte{}}},
+		{name: "NSEC3 chain with Ed25519 and a salt", algorithm: ALGORITHM_ED25519, NSEC3: &NSEC3Resource{HashAlgorithm: NSEC3_HASH_SHA1, Iterations: 5, Salt: []byte{0xAB, 0xCD}}},
+		{name: "opt-out NSEC3 chain", algorithm: ALGORITHM_ECDSAP256SHA256, NSEC3: &NSEC3Resource{HashAlgorithm: NSEC3_HASH_SHA1, Flags: NSEC3_OPT_OUT_FLAG, Salt: []byte{}}},
+	}
+
+	//Query sent to the resolver validating the signed zone, along with the response code, AD bit and number of answers expected.
+	type zoneQuery struct {
+		qname string
+		qtype RecordType
+		rcode ResponseCode
+		authenticated bool
+		answers int
+	}
+	signedQueries := []zoneQuery{
+		{qname: "www.example.com.", qtype: TYPE_A, rcode: RC_NOERROR, authenticated: true, answers: 1},
+		{qname: "host.wild.example.com.", qtype: TYPE_A, rcode: RC_NOERROR, authenticated: true, answers: 1},
+		{qname: "missing.example.com.", qtype: TYPE_A, rcode: RC_NXDOMAIN, authenticated: true},
+		{qname: "ipv4.example.com.", qtype: TYPE_TXT, rcode: RC_NOERROR, authenticated: true},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			hierarchy := newSimulatedHierarchy(t, hierarchyFixtures()...)
+			expiration := time.Now().Add(24 * time.Hour)
+			exampleKey := hierarchy.SignZoneWith(t, "example.com.", testCase.algorithm, testCase.NSEC3, expiration)
+			comKey := hierarchy.SignZone(t, "com.", expiration, exampleKey)
+			rootKey := hierarchy.SignZone(t, ".", expiration, comKey)
+
+			queries := slices.Clone(signedQueries)
+			zone := hierarchy.zone("example.com.")
+			err := VerifySignedZone("example.com.", zone.records, time.Now())
+			if err != nil {
+				t.Fatalf("signed zone does not verify: %s", err.Error())
+			}
+
+			if testCase.NSEC3 != nil {
+				if len(zone.find("example.com.", TYPE_NSEC3PARAM)) != 1 || len(zone.signatures("example.com.", TYPE_NSEC3PARAM)) == 0 {
+					t.Error("no signed NSEC3PARAM record at the apex")
+				}
+				apex, ok := zone.matchingNSEC3("example.com.")
+				if !ok || !slices.Contains(apex.Rdata.(*NSEC3Resource).Types, TYPE_NSEC3PARAM) {
+					t.Error("NSEC3PARAM type missing from the type bitmap of the apex")
+				}
+				queries = append(queries, zoneQuery{qname: "example.com.", qtype: TYPE_NSEC3PARAM, rcode: RC_NOERROR, authenticated: true, answers: 1})
+
+				//Only an opt-out chain leaves out the delegation of dept.example.com., which has no DS records.
+				_, hasDelegation := zone.matchingNSEC3("dept.example.com.")
+				optOut := !slices.ContainsFunc(zone.records, func(rr Resource) bool { return rr.Type == TYPE_NSEC3 && !rr.Rdata.(*NSEC3Resource).IsOptOut() })
+				if hasDelegation == testCase.NSEC3.IsOptOut() || optOut != testCase.NSEC3.IsOptOut() {
+					t.Errorf("NSEC3 chain with the unsigned delegation %t and every record opted out %t, expected opt-out %t", hasDelegation, optOut, testCase.NSEC3.IsOptOut())
+				}
+				if testCase.NSEC3.IsOptOut() {
+					//An opt-out NSEC3 record cannot securely prove that a name does not exist, nor that a wildcard answer was due.
+					queries[1].authenticated, queries[2].authenticated = false, false
+				}
+
+				withoutParams := slices.DeleteFunc(slices.Clone(zone.records), func(rr Resource) bool { return rr.Type == TYPE_NSEC3PARAM })
+				if VerifySignedZone("example.com.", withoutParams, time.Now()) == nil {
+					t.Error("NSEC3 signed zone without NSEC3PARAM record verifies")
+				}
+			}
+
+			resolver := hierarchy.newResolver(t)
+			resolver.SetDNSSECValidation(true)
+			err = resolver.SetTrustAnchors([]Resource{rootKey.DS()})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, query := range queries {
+				response := resolver.Query(query.qname, query.qtype)
+				answers := 0
+				for _, answer := range response.Answers {
+					if answer.Type == query.qtype {
+						answers++
+					}
+				}
+				if response.Header.Rcode != query.rcode || response.Header.Authenticated != query.authenticated || answers != query.answers {
+					t.Errorf("%s %s: response code %s with AD bit %t and %d answers, expected %s with AD bit %t and %d answers", query.qname,
+						query.qtype.String(), response.Header.Rcode.String(), response.Header.Authenticated, answers, query.rcode.String(),
+						query.authenticated, query.answers)
+				}
+			}
+		})
+	}
+}
@@ -1,22 +1,38 @@
 package main
 
 import (
+	"crypto/x509"
 	"fmt"
 	"flag"
 	"os"
+	"strings"
 	"github.com/mkbworks/ask-athena/lib/dns"
 	"github.com/mkbworks/ask-athena/lib/config"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "cache" {
+		os.Exit(runCacheCommand(os.Args[2:]))
+	} else if len(os.Args) > 1 && os.Args[1] == "trust-anchor" {
+		os.Exit(runTrustAnchorCommand(os.Args[2:]))
+	} else if len(os.Args) > 1 && os.Args[1] == "sign" {
+		os.Exit(runSignCommand(os.Args[2:]))
+	} else if len(os.Args) > 1 && os.Args[1] == "serve" {
+		os.Exit(runServeCommand(os.Args[2:]))
+	}
+
 	flag.Usage = func() {
 		fmt.Println("Usage: ./ask-athena [options] domain name(s)")
+		fmt.Println("       ./ask-athena cache <command> [options]")
+		fmt.Println("       ./ask-athena trust-anchor <command> [options]")
+		fmt.Println("       ./ask-athena sign [options] <zone file>")
+		fmt.Println("       ./ask-athena serve [options]")
 		fmt.Println("Options available:")
 		flag.PrintDefaults()
 	}
 
 	recType := flag.String("type", "A", "the record type to query for each domain name")
-	traceLogs := flag.Bool("trace", false, "Enable/Disable Trace Logs")
+	options := addResolverFlags(flag.CommandLine)
 	helpFlag := flag.Bool("help", false, "Show help message")
 	flag.Parse()
 
@@ -31,15 +47,9 @@ func main() {
 		os.Exit(1)
 	}
 
-	err := config.SetupConfig()
-	if err != nil {
-		fmt.Println("Error occurred while setting up DNS resolver configuration:", err.Error())
-		os.Exit(1)
-	}
-
-	resolver, err := dns.NewResolver(config.RootServerFilePath, config.CacheFilePath, *traceLogs)
+	resolver, forwarder, err := options.newResolver()
 	if err != nil {
-		fmt.Printf("Error occurred while fetching DNS Resolver Instance: %s\n", err.Error())
+		fmt.Println(err.Error())
 		os.Exit(1)
 	}
 
@@ -53,4 +63,127 @@ func main() {
 	}
 	
 	resolver.Close()
-}
\ No newline at end of file
+	if forwarder != nil {
+		forwarder.Close()
+	}
+}
+
+//Options of the resolver, set by command line flags shared by the commands that resolve domain names.
+type resolverFlags struct {
+	traceLogs *bool
+	randomizeCase *bool
+	qnameMin *bool
+	dnssec *bool
+	checkingDisabled *bool
+	transport *string
+	cacheStore *string
+	forward *string
+	caFile *string
+}
+
+//Defines the flags setting the options of the resolver on the flag set.
+func addResolverFlags(flags *flag.FlagSet) *resolverFlags {
+	options := resolverFlags{}
+	options.traceLogs = flags.Bool("trace", false, "Enable/Disable Trace Logs")
+	options.randomizeCase = flags.Bool("randomize-case", false, "Randomize the letter case of domain names queried upstream (DNS 0x20)")
+	options.qnameMin = flags.Bool("qname-min", true, "Enable/Disable QNAME minimisation (RFC 9156)")
+	options.dnssec = flags.Bool("dnssec", false, "Enable/Disable DNSSEC validation of the answers received")
+	options.checkingDisabled = flags.Bool("cd", false, "Set the CD bit, returning DNSSEC records without validating them")
+	options.transport = flags.String("transport", "ipv4", "IP version(s) used to reach name servers (ipv4, ipv6 or both)")
+	options.cacheStore = flags.String("cache-store", "bind", "storage backend for the resolver cache (bind, binary or memory)")
+	options.forward = flags.String("forward", "", "comma-separated upstreams to forward queries to, as address[:port][#server-name][?pin=base64-spki-sha256] for DNS-over-TLS or https://host[:port]/path[{?dns}][#bootstrap-ip;...] for DNS-over-HTTPS")
+	options.caFile = flags.String("ca-file", "", "PEM file of the certification authorities trusted to issue the certificates of the upstreams (defaults to the system roots)")
+	return &options
+}
+
+//Sets up the configuration and creates the resolver with the options given. Also returns the forwarder the resolver sends its queries
+//to, if forwarding is enabled, which is to be closed along with the resolver.
+func (options *resolverFlags) newResolver() (*dns.Resolver, *dns.Forwarder, error) {
+	err := config.SetupConfig()
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error occurred while setting up DNS resolver configuration: %s", err.Error())
+	}
+
+	cache, err := newCacheStore(*options.cacheStore)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error occurred while loading the resolver cache: %s", err.Error())
+	}
+
+	resolver, err := dns.NewResolverWithCache(config.RootServerFilePath, cache, *options.traceLogs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error occurred while fetching DNS Resolver Instance: %s", err.Error())
+	}
+
+	err = resolver.SetTransport(*options.transport)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error occurred while setting up the transport: %s", err.Error())
+	}
+
+	var forwarder *dns.Forwarder
+	if *options.forward != "" {
+		forwarder, err = newForwarder(*options.forward, *options.caFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error occurred while setting up forwarding: %s", err.Error())
+		}
+		resolver.SetForwarder(forwarder)
+	}
+
+	resolver.SetCaseRandomization(*options.randomizeCase)
+	resolver.SetQnameMinimisation(*options.qnameMin)
+	resolver.SetDNSSECValidation(*options.dnssec || *options.checkingDisabled)
+	resolver.SetCheckingDisabled(*options.checkingDisabled)
+	if *options.dnssec || *options.checkingDisabled {
+		anchors, err := config.LoadTrustAnchors()
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error occurred while loading the trust anchors: %s", err.Error())
+		}
+		resolver.SetTrustAnchorFile(anchors)
+	}
+	return resolver, forwarder, nil
+}
+
+//Creates the forwarder sending queries to the given comma-separated DNS-over-TLS and DNS-over-HTTPS upstreams, in the order they are
+//given. If a CA file is given, the certificates of the upstreams are verified against the certification authorities it holds instead
+//of the system roots.
+func newForwarder(upstreams string, caFile string) (*dns.Forwarder, error) {
+	var rootCAs *x509.CertPool
+	if caFile != "" {
+		contents, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(contents) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+
+	list := make([]*dns.Upstream, 0)
+	for _, value := range strings.Split(upstreams, ",") {
+		upstream, err := dns.ParseUpstream(value)
+		if err != nil {
+			return nil, err
+		}
+		upstream.RootCAs = rootCAs
+		list = append(list, upstream)
+	}
+	return dns.NewForwarder(list), nil
+}
+
+//Creates the cache store backend identified by the given name.
+func newCacheStore(name string) (dns.CacheStore, error) {
+	switch name {
+	case "bind":
+		store := dns.BindFile{}
+		err := store.Initialize(config.CacheFilePath)
+		return &store, err
+	case "binary":
+		store := dns.SnapshotFile{}
+		err := store.Initialize(config.CacheSnapshotPath)
+		return &store, err
+	case "memory":
+		return dns.NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown cache store %q", name)
+	}
+}
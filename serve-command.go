@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mkbworks/ask-athena/lib/dns"
+)
+
+//Prints the usage of the 'serve' subcommand.
+func serveUsage(flags *flag.FlagSet) {
+	fmt.Println("Usage: ./ask-athena serve [options]")
+	fmt.Println("Answers the queries of clients over DNS-over-TLS and DNS-over-HTTPS (at /dns-query) with the resolver, until interrupted.")
+	fmt.Println("Options available:")
+	flags.PrintDefaults()
+}
+
+//Runs the 'serve' subcommand with the given arguments and returns the exit status.
+func runServeCommand(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	tlsAddress := flags.String("tls-listen", ":853", "address to listen on for DNS-over-TLS connections, or empty to disable DNS-over-TLS")
+	httpsAddress := flags.String("https-listen", ":443", "address to listen on for DNS-over-HTTPS requests, or empty to disable DNS-over-HTTPS")
+	certFile := flags.String("cert", "", "PEM file holding the certificate chain presented to clients")
+	keyFile := flags.String("key", "", "PEM file holding the private key of the certificate")
+	options := addResolverFlags(flags)
+	helpFlag := flags.Bool("help", false, "Show help message")
+	flags.Usage = func() { serveUsage(flags) }
+	err := flags.Parse(args)
+	if err != nil {
+		return 1
+	}
+
+	if *helpFlag {
+		serveUsage(flags)
+		return 0
+	} else if *certFile == "" || *keyFile == "" {
+		fmt.Println("Not enough arguments, must pass in the certificate and key files with --cert and --key")
+		return 1
+	} else if *tlsAddress == "" && *httpsAddress == "" {
+		fmt.Println("Both listeners are disabled, must pass in --tls-listen or --https-listen")
+		return 1
+	}
+
+	resolver, forwarder, err := options.newResolver()
+	if err != nil {
+		fmt.Println(err.Error())
+		return 1
+	}
+	defer func() {
+		resolver.Close()
+		if forwarder != nil {
+			forwarder.Close()
+		}
+	}()
+
+	server, err := dns.NewServer(resolver, *certFile, *keyFile)
+	if err != nil {
+		fmt.Printf("Error occurred while loading the certificate: %s\n", err.Error())
+		return 1
+	}
+
+	failures := make(chan error, 2)
+	if *tlsAddress != "" {
+		go func() { failures <- server.ListenAndServeTLS(*tlsAddress) }()
+		fmt.Printf("Serving DNS-over-TLS on %s.\n", *tlsAddress)
+	}
+	if *httpsAddress != "" {
+		go func() { failures <- server.ListenAndServeHTTPS(*httpsAddress) }()
+		fmt.Printf("Serving DNS-over-HTTPS on https://%s%s.\n", *httpsAddress, dns.DOH_PATH)
+	}
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	status := 0
+	select {
+	case err = <-failures:
+		if !errors.Is(err, dns.ErrServerClosed) {
+			fmt.Printf("Error occurred while serving: %s\n", err.Error())
+			status = 1
+		}
+	case <-interrupts:
+		fmt.Println("Shutting down.")
+	}
+
+	signal.Stop(interrupts)
+	server.Close()
+	return status
+}
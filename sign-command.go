@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mkbworks/ask-athena/lib/dns"
+)
+
+//Prints the usage of the 'sign' subcommand.
+func signUsage(flags *flag.FlagSet) {
+	fmt.Println("Usage: ./ask-athena sign [options] <zone file>")
+	fmt.Println("Signs the zone held by the master file with the keys found in the key directory, generating a key signing key and a")
+	fmt.Println("zone signing key when there are none, and writes the signed zone to a new master file.")
+	fmt.Println("Options available:")
+	flags.PrintDefaults()
+}
+
+//Runs the 'sign' subcommand with the given arguments and returns the exit status.
+func runSignCommand(args []string) int {
+	flags := flag.NewFlagSet("sign", flag.ContinueOnError)
+	origin := flags.String("origin", "", "origin of the zone, if the zone file does not set it (defaults to the owner of the SOA record)")
+	output := flags.String("output", "", "path of the signed zone file (defaults to the zone file path followed by .signed)")
+	keyDirectory := flags.String("key-dir", "", "directory holding the keys of the zone (defaults to the directory of the zone file)")
+	algorithm := flags.String("algorithm", "ecdsap256sha256", "algorithm of the keys generated for the zone (ecdsap256sha256 or ed25519)")
+	useNSEC3 := flags.Bool("nsec3", false, "prove the non-existence of names with NSEC3 records instead of NSEC records")
+	iterations := flags.Uint("iterations", 0, "number of additional NSEC3 hash iterations")
+	salt := flags.String("salt", "-", "NSEC3 salt in hex, or - for no salt")
+	optOut := flags.Bool("opt-out", false, "leave delegations without DS records out of the NSEC3 chain")
+	validity := flags.Duration("validity", 30 * 24 * time.Hour, "time the signatures stay valid for")
+	helpFlag := flags.Bool("help", false, "Show help message")
+	flags.Usage = func() { signUsage(flags) }
+	err := flags.Parse(args)
+	if err != nil {
+		return 1
+	}
+
+	if *helpFlag {
+		signUsage(flags)
+		return 0
+	} else if flags.NArg() != 1 {
+		fmt.Println("Not enough arguments, must pass in the zone file to sign")
+		return 1
+	}
+
+	zonePath := flags.Arg(0)
+	zoneFile := dns.ZoneFile{}
+	err = zoneFile.Initialize(zonePath, *origin)
+	if err != nil {
+		fmt.Printf("Error occurred while reading the zone file: %s\n", err.Error())
+		return 1
+	} else if zoneFile.Origin == "" {
+		fmt.Println("Unable to tell the origin of the zone, must pass in --origin")
+		return 1
+	}
+
+	if *keyDirectory == "" {
+		*keyDirectory = filepath.Dir(zonePath)
+	}
+	if *output == "" {
+		*output = zonePath + ".signed"
+	}
+
+	keys, err := zoneKeys(zoneFile, *keyDirectory, *algorithm)
+	if err != nil {
+		fmt.Printf("Error occurred while loading the keys of the zone: %s\n", err.Error())
+		return 1
+	}
+
+	now := time.Now()
+	signer := dns.ZoneSigner{Zone: zoneFile.Origin, Keys: keys, Inception: now.Add(-time.Hour), Expiration: now.Add(*validity)}
+	if *useNSEC3 {
+		signer.NSEC3 = &dns.NSEC3Resource{HashAlgorithm: dns.NSEC3_HASH_SHA1, Iterations: uint16(*iterations), Salt: make([]byte, 0)}
+		if *salt != "-" {
+			signer.NSEC3.Salt, err = hex.DecodeString(*salt)
+			if err != nil {
+				fmt.Printf("Given NSEC3 salt is not valid hex: %s\n", err.Error())
+				return 1
+			}
+		}
+		if *optOut {
+			signer.NSEC3.Flags = dns.NSEC3_OPT_OUT_FLAG
+		}
+	}
+
+	records, err := signer.Sign(zoneFile.Records)
+	if err != nil {
+		fmt.Printf("Error occurred while signing the zone: %s\n", err.Error())
+		return 1
+	}
+
+	err = dns.VerifySignedZone(zoneFile.Origin, records, now)
+	if err != nil {
+		fmt.Printf("Signed zone does not validate: %s\n", err.Error())
+		return 1
+	}
+
+	signedZone := dns.ZoneFile{Origin: zoneFile.Origin, Records: records}
+	err = signedZone.Save(*output)
+	if err != nil {
+		fmt.Printf("Error occurred while writing the signed zone: %s\n", err.Error())
+		return 1
+	}
+
+	fmt.Printf("Signed %s with %d key(s), %d record(s) written to %s.\n", zoneFile.Origin, len(keys), len(records), *output)
+	fmt.Println("DS records to publish in the parent zone:")
+	for _, key := range keys {
+		if key.IsKeySigningKey() {
+			ds := key.DS()
+			fmt.Println(ds.CacheString())
+		}
+	}
+	return 0
+}
+
+//Returns the keys of the zone stored in the key directory. When there are none, a key signing key and a zone signing key are
+//generated with the given algorithm and saved in the directory.
+func zoneKeys(zoneFile dns.ZoneFile, directory string, algorithmName string) ([]*dns.ZoneKey, error) {
+	keys, err := dns.LoadZoneKeys(directory, zoneFile.Origin)
+	if err != nil || len(keys) > 0 {
+		return keys, err
+	}
+
+	var algorithm uint8
+	switch strings.ToLower(algorithmName) {
+	case "ecdsap256sha256":
+		algorithm = dns.ALGORITHM_ECDSAP256SHA256
+	case "ed25519":
+		algorithm = dns.ALGORITHM_ED25519
+	default:
+		return nil, fmt.Errorf("unknown key algorithm %q", algorithmName)
+	}
+
+	ttl := zoneFile.DefaultTTL
+	for _, rr := range zoneFile.Records {
+		if rr.Type == dns.TYPE_SOA {
+			ttl = rr.TTL
+		}
+	}
+
+	for _, keySigning := range []bool{true, false} {
+		key, err := dns.GenerateZoneKey(zoneFile.Origin, algorithm, keySigning, ttl)
+		if err != nil {
+			return nil, err
+		}
+		err = key.Save(directory)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("Generated key %s in %s.\n", key.FileName(), directory)
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
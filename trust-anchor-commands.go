@@ -0,0 +1,106 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/mkbworks/ask-athena/lib/config"
+	"github.com/mkbworks/ask-athena/lib/dns"
+)
+
+//Prints the usage of the 'trust-anchor' subcommand.
+func trustAnchorUsage() {
+	fmt.Println("Usage: ./ask-athena trust-anchor <command> [options]")
+	fmt.Println("Commands available:")
+	fmt.Println("  show                                           List the trust anchors and the state of their keys")
+	fmt.Println("  refresh [--trace] [--transport ipv4|ipv6|both] Fetch the DNSKEY records of the anchored zones and follow key rollovers")
+}
+
+//Runs the 'trust-anchor' subcommand with the given arguments and returns the exit status.
+func runTrustAnchorCommand(args []string) int {
+	if len(args) == 0 || args[0] == "-help" || args[0] == "--help" {
+		trustAnchorUsage()
+		return 0
+	}
+
+	command := args[0]
+	flags := flag.NewFlagSet("trust-anchor "+command, flag.ContinueOnError)
+	traceLogs := flags.Bool("trace", false, "Enable/Disable Trace Logs")
+	transport := flags.String("transport", "ipv4", "IP version(s) used to reach name servers (ipv4, ipv6 or both)")
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return 1
+	}
+
+	err = config.SetupConfig()
+	if err != nil {
+		fmt.Println("Error occurred while setting up DNS resolver configuration:", err.Error())
+		return 1
+	}
+
+	anchors, err := config.LoadTrustAnchors()
+	if err != nil {
+		fmt.Printf("Error occurred while loading the trust anchors: %s\n", err.Error())
+		return 1
+	}
+
+	switch command {
+	case "show":
+		return trustAnchorShow(anchors)
+	case "refresh":
+		return trustAnchorRefresh(anchors, *traceLogs, *transport)
+	default:
+		fmt.Printf("Unknown trust-anchor command %q.\n\n", command)
+		trustAnchorUsage()
+		return 1
+	}
+}
+
+//Prints the trust anchors along with the state of their keys.
+func trustAnchorShow(anchors *dns.TrustAnchorFile) int {
+	list := anchors.List()
+	for _, anchor := range list {
+		record := anchor.Record
+		keyTag, algorithm := uint16(0), uint8(0)
+		if ds, ok := record.Rdata.(*dns.DSResource); ok {
+			keyTag, algorithm = ds.KeyTag, ds.Algorithm
+		} else if dnskey, ok := record.Rdata.(*dns.DNSKEYResource); ok {
+			keyTag, algorithm = dnskey.KeyTag(), dnskey.Algorithm
+		}
+
+		changed := "-"
+		if !anchor.LastChange.IsZero() {
+			changed = anchor.LastChange.Format(time.RFC3339)
+		}
+		fmt.Printf("%s \t %s \t key tag %d \t algorithm %d \t %s \t (changed %s)\n", record.Name.String(), record.Type.String(), keyTag, algorithm, anchor.State.String(), changed)
+	}
+	fmt.Printf("\n%d trust anchor(s) listed from %s.\n", len(list), anchors.LocalFilePath)
+	return 0
+}
+
+//Authenticates the DNSKEY records of every zone with trust anchors, moving their keys along the RFC 5011 key rollover, and prints the
+//resulting trust anchors.
+func trustAnchorRefresh(anchors *dns.TrustAnchorFile, traceLogs bool, transport string) int {
+	resolver, err := dns.NewResolverWithCache(config.RootServerFilePath, dns.NewMemoryStore(), traceLogs)
+	if err != nil {
+		fmt.Printf("Error occurred while fetching DNS Resolver Instance: %s\n", err.Error())
+		return 1
+	}
+	defer resolver.Close()
+
+	err = resolver.SetTransport(transport)
+	if err != nil {
+		fmt.Printf("Error occurred while setting up the transport: %s\n", err.Error())
+		return 1
+	}
+
+	resolver.SetDNSSECValidation(true)
+	resolver.SetTrustAnchorFile(anchors)
+	err = resolver.RefreshTrustAnchors()
+	if err != nil {
+		fmt.Printf("Error occurred while refreshing the trust anchors: %s\n", err.Error())
+		return 1
+	}
+	return trustAnchorShow(anchors)
+}